@@ -1,17 +1,14 @@
 %{
 package asyncpi
 
-import (
-	"io"
-
-	"go.nickng.io/asyncpi/internal/name"
-)
+import "io"
 
 var proc Process
 %}
 
 %union {
 	strval string
+	pos    Pos
 	proc   Process
 	name   Name
 	names  []Name
@@ -20,8 +17,8 @@ var proc Process
 %token kLANGLE kRANGLE kLPAREN kRPAREN kPREFIX kSEMICOLON kCOLON kNIL kNAME kREPEAT kNEW kCOMMA
 %type <proc> proc simpleproc scope
 %type <strval> kNAME
-%type <name> scopename
-%type <names> names
+%type <name> scopename newname
+%type <names> names newnames
 %type <names> values
 
 %left kPAR
@@ -40,19 +37,27 @@ proc :           simpleproc { $$ = $1 }
      ;
 
 simpleproc : kNIL { $$ = NewNilProcess() }
-           | kNAME kLANGLE values kRANGLE { $$ = NewSend(name.New($1)); $$.(*Send).SetVals($3) }
-           | kNAME kLPAREN names kRPAREN kPREFIX         proc         { $$ = NewRecv(name.New($1), $6); $$.(*Recv).SetVars($3) }
-           | kNAME kLPAREN names kRPAREN kPREFIX kLPAREN proc kRPAREN { $$ = NewRecv(name.New($1), $7); $$.(*Recv).SetVars($3) }
-           | kLPAREN kNEW scopename kRPAREN scope { $$ = NewRestrict($3, $5) }
-           | kLPAREN kNEW scopename kCOMMA names kRPAREN scope { $$ = NewRestricts(append([]Name{$3}, $5...), $7) }
+           | kNAME kLANGLE values kRANGLE { $$ = NewSend(newName($1, $<pos>1)); $$.(*Send).SetVals($3) }
+           | kNAME kLPAREN names kRPAREN kPREFIX         proc         { $$ = NewRecv(newName($1, $<pos>1), $6); $$.(*Recv).SetVars($3) }
+           | kNAME kLPAREN names kRPAREN kPREFIX kLPAREN proc kRPAREN { $$ = NewRecv(newName($1, $<pos>1), $7); $$.(*Recv).SetVars($3) }
+           | kLPAREN kNEW newname kRPAREN scope { $$ = NewRestrict($3, $5) }
+           | kLPAREN kNEW newname kCOMMA newnames kRPAREN scope { $$ = NewRestricts(append([]Name{$3}, $5...), $7) }
            | kREPEAT         proc { $$ = NewRepeat($2) }
            | kREPEAT kLPAREN proc kRPAREN { $$ = NewRepeat($3) }
            ;
 
-scopename : kNAME              { $$ = name.New($1) }
-          | kNAME kCOLON kNAME { $$ = name.NewHinted($1, $3) }
+scopename : kNAME              { $$ = newName($1, $<pos>1) }
+          | kNAME kCOLON kNAME { $$ = newHintedName($1, $3, $<pos>1) }
           ;
 
+newname : scopename       { $$ = $1 }
+        | kNAME scopename { $$ = annotateName($2, $1, asyncpilex) }
+        ;
+
+newnames :                newname { $$ = []Name{$1} }
+         | newnames kCOMMA newname { $$ = append($1, $3) }
+         ;
+
 scope : simpleproc           { $$ = $1 }
       | kLPAREN proc kRPAREN { $$ = $2 }
       ;
@@ -63,8 +68,8 @@ names : /* empty */            { $$ = nil }
       ;
 
 values : /* empty */         { $$ = nil }
-       |               kNAME { $$ = []Name{name.New($1)} }
-       | values kCOMMA kNAME { $$ = append($1, name.New($3)) }
+       |               kNAME { $$ = []Name{newName($1, $<pos>1)} }
+       | values kCOMMA kNAME { $$ = append($1, newName($3, $<pos>3)) }
        ;
 
 %%
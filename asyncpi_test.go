@@ -200,6 +200,45 @@ func TestParseComment(t *testing.T) {
 	}
 }
 
+// Tests positions of parsed names.
+func TestParseNamePos(t *testing.T) {
+	proc, err := Parse(strings.NewReader("(new a:int)\n  a(x).b<x>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := proc.(*Restrict)
+	recv := res.Proc.(*Recv)
+	send := recv.Cont.(*Send)
+	for _, test := range []struct {
+		Name Name
+		Pos  string
+	}{
+		{res.Name, "1:6"}, {recv.Chan, "2:3"}, {recv.Vars[0], "2:5"}, {send.Chan, "2:8"}, {send.Vals[0], "2:10"},
+	} {
+		if want, got := test.Pos, NamePos(test.Name).String(); want != got {
+			t.Errorf("expects %s at %s but got %s", test.Name.Ident(), want, got)
+		}
+	}
+}
+
+// Tests linear annotation of restricted names.
+func TestParseLinear(t *testing.T) {
+	proc, err := Parse(strings.NewReader("(new lin a, b, lin c:T)0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resa := proc.(*Restrict)
+	resb := resa.Proc.(*Restrict)
+	resc := resb.Proc.(*Restrict)
+	if !IsAnnotatedLinear(resa.Name) || IsAnnotatedLinear(resb.Name) || !IsAnnotatedLinear(resc.Name) {
+		t.Errorf("Parse: expects a and c to be linear but got %t, %t, %t",
+			IsAnnotatedLinear(resa.Name), IsAnnotatedLinear(resb.Name), IsAnnotatedLinear(resc.Name))
+	}
+	if _, err := Parse(strings.NewReader("(new foo a)0")); err == nil {
+		t.Errorf("Parse: expects unknown annotation to fail")
+	}
+}
+
 // Tests syntax error.
 func TestParseFailed(t *testing.T) {
 	incomplete := `(new a`
@@ -8,81 +8,81 @@ import "go.nickng.io/asyncpi/internal/errors"
 // Bind takes a parsed process p and returned a process with valid binding.
 func Bind(p *Process) error {
 	var err error
-	*p, err = bind(*p, []Name{})
+	*p, err = bind(*p)
 	if err != nil {
 		return errors.Wrap(err, "bind failed")
 	}
 	return nil
 }
 
-// bind is a depth-first recursive traversal of a Process p
-// with boundNames to bind Names with the same Ident.
-func bind(p Process, boundNames []Name) (_ Process, err error) {
-	switch p := p.(type) {
-	case *NilProcess:
-		return p, nil
-	case *Repeat:
-		p.Proc, err = bind(p.Proc, boundNames)
-		return p, err
-	case *Par:
-		for i := range p.Procs {
-			p.Procs[i], err = bind(p.Procs[i], boundNames)
-		}
-		return p, err
-	case *Recv:
-		names := make([]Name, len(boundNames))
-		for i := range boundNames {
-			names[i] = boundNames[i]
-		}
-		names = append(names, p.Vars...)
-		for _, v := range p.Vars {
-			for j := 0; j < len(names)-len(p.Vars); j++ {
-				if IsSameName(v, names[j]) {
-					// Rebinding existing bound name.
-					names = append(names[:j], names[j+1:]...)
+// BindCopy returns a copy of the process p with valid binding.
+// Unlike Bind, the input process p is not modified.
+func BindCopy(p Process) (Process, error) {
+	p = Clone(p)
+	if err := Bind(&p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// bind traverses a Process p to bind Names with the same Ident
+// to the Name declared by the innermost binder in scope.
+//
+// The free channel Name of a Recv is also considered bound
+// in its continuation.
+func bind(p Process) (_ Process, err error) {
+	type freeChan struct {
+		recv  *Recv
+		name  Name
+		depth int // Scope depth of the continuation.
+	}
+	var frees []freeChan // free channels in scope, innermost last.
+	lookup := func(n Name, scope *Scope) Name {
+		bn, bs := scope.Lookup(n.Ident())
+		for i := len(frees) - 1; i >= 0; i-- {
+			if IsSameName(n, frees[i].name) {
+				if bn == nil || frees[i].depth > bs.Depth() {
+					return frees[i].name
 				}
+				break
 			}
 		}
-		var chanBound bool
-		for i, bn := range names {
-			if IsSameName(p.Chan, bn) { // Found bound Chan
-				p.Chan = names[i]
-				chanBound = true
-			}
-		}
-		if !chanBound {
-			names = append(names, p.Chan)
+		return bn
+	}
+	p = Apply(p, func(c *Cursor) bool {
+		if err != nil {
+			return false
 		}
-		p.Cont, err = bind(p.Cont, names)
-		return p, err
-	case *Send:
-		count := 0
-		for i, bn := range boundNames {
-			for j, v := range p.Vals {
-				if IsSameName(v, bn) { // Found bound name.
-					p.Vals[j] = boundNames[i]
-					count++
-				}
+		switch p := c.Node().(type) {
+		case *NilProcess, *Par, *Repeat, *Restrict:
+		case *Recv:
+			if bn := lookup(p.Chan, c.Scope()); bn != nil {
+				p.Chan = bn
+			} else {
+				frees = append(frees, freeChan{recv: p, name: p.Chan, depth: c.Scope().Depth() + 1})
 			}
-		}
-		for i, bn := range boundNames {
-			if IsSameName(p.Chan, bn) { // Found bound Chan.
-				p.Chan = boundNames[i]
-				count++
+		case *Send:
+			if bn := lookup(p.Chan, c.Scope()); bn != nil {
+				p.Chan = bn
 			}
-		}
-		return p, err
-	case *Restrict:
-		names := append(boundNames, p.Name)
-		for i := 0; i < len(names)-1; i++ {
-			if IsSameName(p.Name, names[i]) {
-				// Rebinding existing bound name.
-				names = append(names[:i], names[i+1:]...)
+			for i, v := range p.Vals {
+				if bn := lookup(v, c.Scope()); bn != nil {
+					p.Vals[i] = bn
+				}
 			}
+		default:
+			err = UnknownProcessError{Proc: p}
+			return false
+		}
+		return true
+	}, func(c *Cursor) bool {
+		if len(frees) > 0 && frees[len(frees)-1].recv == c.Node() {
+			frees = frees[:len(frees)-1]
 		}
-		p.Proc, err = bind(p.Proc, names)
-		return p, err
-	default:
-		return nil, UnknownProcessError{Proc: p}
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
+	return p, nil
 }
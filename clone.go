@@ -0,0 +1,115 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asyncpi
+
+import "go.nickng.io/asyncpi/internal/name"
+
+// Cloning.
+// This file contains functions for deep copying processes.
+
+// Clone returns a deep copy of Process p.
+//
+// Names that are shared in p (e.g. after Bind) are also shared in the copy,
+// so the binding structure of p is preserved.
+// See Cloner for how Names are copied.
+func Clone(p Process) Process {
+	return NewCloner().Process(p)
+}
+
+// nameCloner is an interface which Name should
+// provide to be deep copied by a Cloner.
+type nameCloner interface {
+	CloneName(c *Cloner) Name
+}
+
+// Cloner deep copies Processes and Names.
+//
+// A Cloner remembers the Names it has copied, so a Name copied
+// more than once by the same Cloner is copied to the same Name.
+//
+// A Name implementation is copied by calling its CloneName(*Cloner) Name
+// method. Name implementations that wrap other Names should call Set
+// before copying the wrapped Names with the Cloner.
+// Names without a CloneName method are assumed immutable and not copied.
+type Cloner struct {
+	names map[Name]Name
+}
+
+// NewCloner returns a new Cloner.
+func NewCloner() *Cloner {
+	return &Cloner{names: make(map[Name]Name)}
+}
+
+// Set records that Name n is copied to clone.
+func (c *Cloner) Set(n, clone Name) {
+	c.names[n] = clone
+}
+
+// Name returns a copy of Name n.
+func (c *Cloner) Name(n Name) Name {
+	if n == nil {
+		return nil
+	}
+	if clone, cloned := c.names[n]; cloned {
+		return clone
+	}
+	if nc, ok := n.(nameCloner); ok {
+		clone := nc.CloneName(c)
+		c.names[n] = clone
+		return clone
+	}
+	if clone, ok := name.Copy(n); ok {
+		c.names[n] = clone.(Name)
+		return clone.(Name)
+	}
+	return n
+}
+
+func (c *Cloner) nameSlice(names []Name) []Name {
+	if names == nil {
+		return nil
+	}
+	clones := make([]Name, len(names))
+	for i := range names {
+		clones[i] = c.Name(names[i])
+	}
+	return clones
+}
+
+// Process returns a copy of Process p.
+//
+// Unknown Process implementations are returned as-is.
+func (c *Cloner) Process(p Process) Process {
+	switch p := p.(type) {
+	case *NilProcess:
+		return NewNilProcess()
+	case *Par:
+		procs := make([]Process, len(p.Procs))
+		for i := range p.Procs {
+			procs[i] = c.Process(p.Procs[i])
+		}
+		return &Par{Procs: procs}
+	case *Recv:
+		return &Recv{Chan: c.Name(p.Chan), Vars: c.nameSlice(p.Vars), Cont: c.Process(p.Cont)}
+	case *Repeat:
+		return &Repeat{Proc: c.Process(p.Proc)}
+	case *Restrict:
+		return &Restrict{Name: c.Name(p.Name), Proc: c.Process(p.Proc)}
+	case *Send:
+		return &Send{Chan: c.Name(p.Chan), Vals: c.nameSlice(p.Vals)}
+	default:
+		return p
+	}
+}
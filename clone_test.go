@@ -0,0 +1,55 @@
+package asyncpi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCloneBinding(t *testing.T) {
+	const proc = `(new a)(a<> | a().0)`
+	p, err := Parse(strings.NewReader(proc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Bind(&p); err != nil {
+		t.Fatal(err)
+	}
+	c := Clone(p)
+	if want, got := p.Calculi(), c.Calculi(); want != got {
+		t.Fatalf("expects clone to be %s but got %s", want, got)
+	}
+	type setter interface {
+		SetName(string)
+	}
+	res := c.(*Restrict)
+	if res.Name == p.(*Restrict).Name {
+		t.Fatalf("expects cloned name %s to be a copy", res.Name.Ident())
+	}
+	if s, ok := res.Name.(setter); ok {
+		s.SetName("b")
+	}
+	if want, got := `(new b)(b<> | b().0)`, c.Calculi(); want != got {
+		t.Fatalf("expects clone to preserve binding and be %s but got %s", want, got)
+	}
+	if want, got := `(new a)(a<> | a().0)`, p.Calculi(); want != got {
+		t.Fatalf("expects original to be unchanged %s but got %s", want, got)
+	}
+}
+
+func TestBindCopy(t *testing.T) {
+	const proc = `(new a)(a<> | a().0)`
+	p, err := Parse(strings.NewReader(proc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := BindCopy(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.(*Restrict).Proc.(*Par).Procs[0].(*Send).Chan == p.(*Restrict).Name {
+		t.Fatalf("expects BindCopy to not bind the original process %s", p.Calculi())
+	}
+	if b.(*Restrict).Proc.(*Par).Procs[0].(*Send).Chan != b.(*Restrict).Name {
+		t.Fatalf("expects BindCopy to bind the copy of process %s", b.Calculi())
+	}
+}
@@ -18,9 +18,7 @@ import (
 	"bytes"
 	"go/format"
 
-	"go.nickng.io/asyncpi"
 	"go.nickng.io/asyncpi/codegen/golang"
-	"go.nickng.io/asyncpi/types"
 )
 
 type codegenCmd struct {
@@ -37,22 +35,8 @@ func (cmd *codegenCmd) Run() {
 		return
 	}
 	p := cmd.r.hist[len(cmd.r.hist)-1]
-	if err := asyncpi.Bind(&p); err != nil {
-		cmd.r.Done <- err
-		return
-	}
-	err := types.Infer(p)
-	if err != nil {
-		cmd.r.Done <- err
-		return
-	}
-	err = types.Unify(p)
-	if err != nil {
-		cmd.r.Done <- err
-		return
-	}
 	var output bytes.Buffer
-	err = golang.Generate(p, &output)
+	err := golang.Generate(p, &output)
 	if err != nil {
 		cmd.r.Done <- err
 		return
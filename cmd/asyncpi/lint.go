@@ -0,0 +1,50 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"go.nickng.io/asyncpi/lint"
+)
+
+type lintCmd struct {
+	r *REPL
+}
+
+func (cmd *lintCmd) Desc() string {
+	return "Report problems in the last parsed process."
+}
+
+func (cmd *lintCmd) Run() {
+	if len(cmd.r.hist) < 1 {
+		cmd.r.Errorf("No last process to lint.\n")
+		return
+	}
+	diags, err := lint.Lint(cmd.r.hist[len(cmd.r.hist)-1])
+	if err != nil {
+		cmd.r.Done <- err
+		return
+	}
+	if len(diags) == 0 {
+		cmd.r.Responsef("No problems found.\n")
+		return
+	}
+	for _, d := range diags {
+		if d.Severity == lint.Error {
+			cmd.r.Errorf("%s\n", d)
+			continue
+		}
+		cmd.r.Responsef("%s\n", d)
+	}
+}
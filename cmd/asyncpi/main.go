@@ -80,6 +80,9 @@ func NewREPL() *REPL {
 		"reduce":  &reduceCmd{r: &r},
 		"show":    &subprocCmd{r: &r},
 		"codegen": &codegenCmd{r: &r},
+		"lint":    &lintCmd{r: &r},
+		"sorting": &sortingCmd{r: &r},
+		"run":     &runCmd{r: &r},
 	}
 	return &r
 }
@@ -0,0 +1,55 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.nickng.io/asyncpi/interp"
+)
+
+// Budgets of the run command.
+const (
+	runMaxSteps = 10000
+	runTimeout  = 5 * time.Second
+)
+
+type runCmd struct {
+	r *REPL
+}
+
+func (cmd *runCmd) Desc() string {
+	return "Execute the last parsed process with the interpreter."
+}
+
+func (cmd *runCmd) Run() {
+	if len(cmd.r.hist) < 1 {
+		cmd.r.Errorf("No last process to run.\n")
+		return
+	}
+	p := cmd.r.hist[len(cmd.r.hist)-1]
+	in, err := interp.New(p, interp.Options{MaxSteps: runMaxSteps, Timeout: runTimeout})
+	if err != nil {
+		cmd.r.Done <- err
+		return
+	}
+	cmd.r.Responsef("Running: %s\n", p.Calculi())
+	if err := in.Run(context.Background()); err != nil {
+		cmd.r.Errorf("Stopped after %d step(s): %v\n", in.Steps(), err)
+		return
+	}
+	cmd.r.Responsef("Terminated after %d step(s).\n", in.Steps())
+}
@@ -0,0 +1,43 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"go.nickng.io/asyncpi/name/sortedname"
+)
+
+type sortingCmd struct {
+	r *REPL
+}
+
+func (cmd *sortingCmd) Desc() string {
+	return "Infer and display the sorting of the last parsed process."
+}
+
+func (cmd *sortingCmd) Run() {
+	if len(cmd.r.hist) < 1 {
+		cmd.r.Errorf("No last process to infer sorting.\n")
+		return
+	}
+	s, err := sortedname.InferSorting(cmd.r.hist[len(cmd.r.hist)-1])
+	if s == nil {
+		cmd.r.Done <- err
+		return
+	}
+	for _, c := range s.Conflicts {
+		cmd.r.Errorf("%s\n", c)
+	}
+	cmd.r.Responsef("%s\n", s)
+}
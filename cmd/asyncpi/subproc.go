@@ -34,8 +34,8 @@ func (cmd *subprocCmd) Run() {
 		cmd.r.Errorf("No last process to show.\n")
 		return
 	}
-	p := cmd.r.hist[len(cmd.r.hist)-1]
-	if err := sortedname.InferSortsByUsage(p); err != nil {
+	p, err := sortedname.InferSortsByUsageCopy(cmd.r.hist[len(cmd.r.hist)-1])
+	if err != nil {
 		cmd.r.Done <- err
 		return
 	}
@@ -43,24 +43,15 @@ func (cmd *subprocCmd) Run() {
 }
 
 func (cmd *subprocCmd) displaySubprocess(p asyncpi.Process) {
-	procs := []asyncpi.Process{p}
-	for len(procs) > 0 {
-		p, procs = procs[0], procs[1:]
-		cmd.r.Responsef("%s\n\tfn = %q\n\tfv = %q\n", p.Calculi(), p.FreeNames(), p.FreeVars())
+	asyncpi.Inspect(p, func(p asyncpi.Process) bool {
 		switch p := p.(type) {
-		case *asyncpi.NilProcess:
-		case *asyncpi.Par:
-			procs = append(procs, p.Procs...)
-		case *asyncpi.Recv:
-			procs = append(procs, p.Cont)
-		case *asyncpi.Repeat:
-			procs = append(procs, p.Proc)
-		case *asyncpi.Restrict:
-			procs = append(procs, p.Proc)
-		case *asyncpi.Send:
+		case nil:
+		case *asyncpi.NilProcess, *asyncpi.Par, *asyncpi.Recv, *asyncpi.Repeat, *asyncpi.Restrict, *asyncpi.Send:
+			cmd.r.Responsef("%s\n\tfn = %q\n\tfv = %q\n", p.Calculi(), p.FreeNames(), p.FreeVars())
 		default:
 			cmd.r.Done <- fmt.Errorf("unknown subprocess type: %s", p.Calculi())
-			return
+			return false
 		}
-	}
+		return true
+	})
 }
@@ -18,119 +18,535 @@ package golang
 import (
 	"bytes"
 	"fmt"
+	"go/token"
 	"io"
+	"strconv"
 
 	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/resolve"
 	"go.nickng.io/asyncpi/types"
 )
 
 // Generate writes Go code of the Process p to w.
+//
+// The server of a polymorphic name used at more than one type is
+// generated once for each type (see types.Monomorphise), so all
+// generated channels have a monomorphic Go type.
+//
+// The input Process p is not modified.
 func Generate(p asyncpi.Process, w io.Writer) error {
-	if err := asyncpi.Bind(&p); err != nil {
-		return err
-	}
-	types.Infer(p)
-	if err := types.Unify(p); err != nil {
+	return generate(p, FormatOptions{}, w)
+}
+
+// generate writes Go code of the Process p to w using options opt.
+func generate(p asyncpi.Process, opt FormatOptions, w io.Writer) error {
+	decls := declIdentsOf(p)
+	p, lin, pos, err := prepare(p)
+	if err != nil {
 		return err
 	}
-	if err := gen(p, w); err != nil {
+	if err := gen(p, lin, pos, decls, opt, w); err != nil {
 		return err
 	}
 	return nil
 }
 
-func gen(p asyncpi.Process, w io.Writer) error {
-	switch p := p.(type) {
-	case *asyncpi.NilProcess:
-		w.Write([]byte("/* end */"))
-		return nil
-	case *asyncpi.Par:
-		for i := 0; i < len(p.Procs)-1; i++ {
-			w.Write([]byte("go func(){ "))
-			if err := gen(p.Procs[i], w); err != nil {
+// prepare returns a typed copy of the Process p ready for code
+// generation, the linearity of its channels, and the source positions
+// of its inputs and outputs.
+func prepare(p asyncpi.Process) (asyncpi.Process, *types.LinearityInfo, positions, error) {
+	p = asyncpi.Clone(p)
+	pos := opPositions(p)
+	p = normaliseRepeat(p)
+	q, err := types.Monomorphise(p)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	p, pos = q, copyPositions(pos, p, q)
+	if err := types.Infer(p); err != nil {
+		return nil, nil, nil, err
+	}
+	lin, err := types.InferLinearity(p)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return p, lin, pos, nil
+}
+
+// gen writes Go code of the Process p to w, where decls are the π
+// identifiers of the names declared in the source of p.
+// Linear channels are buffered, so the only output never blocks.
+// Named types of recursive types are declared before the code.
+//
+// If opt.Main is set, the free names of p are declared as channels,
+// and the processes are tracked by the scheduler of the program.
+// If opt.AsyncSend is set, each output which is not replicated is sent
+// in its own goroutine, so the output never blocks.
+// If opt.Runtime is set, the code targets the runtime package instead.
+func gen(p asyncpi.Process, lin *types.LinearityInfo, pos positions, decls declIdents, opt FormatOptions, w io.Writer) error {
+	info, err := resolve.Resolve(p)
+	if err != nil {
+		return err
+	}
+	if opt.Runtime {
+		ids := newIdentNamer(info, decls, runtimePkg)
+		if opt.Main {
+			if err := genRuntimeFreeNames(info, ids, w); err != nil {
 				return err
 			}
-			w.Write([]byte(" }()\n"))
 		}
-		if err := gen(p.Procs[len(p.Procs)-1], w); err != nil {
+		return genRuntimeCode(p, info, pos, opt, ids, w)
+	}
+	ids := newIdentNamer(info, decls)
+	tn := newTypeNamer(ids.used)
+	nameFields(p, tn)
+	var code bytes.Buffer
+	if opt.Main {
+		if err := genFreeNames(info, ids, tn, &code); err != nil {
 			return err
 		}
-		return nil
-	case *asyncpi.Repeat:
-		w.Write([]byte("for { "))
-		if err := gen(p.Proc, w); err != nil {
-			return err
+	}
+	if err := genCode(p, info, lin, pos, opt, ids, tn, &code); err != nil {
+		return err
+	}
+	if _, err := w.Write(tn.Decls()); err != nil {
+		return err
+	}
+	_, err = io.Copy(w, &code)
+	return err
+}
+
+// genFreeNames writes the declarations of the free names in info,
+// where channels are created bidirectional.
+func genFreeNames(info *resolve.Info, ids *identNamer, tn *typeNamer, w io.Writer) error {
+	for _, obj := range info.FreeNames() {
+		n, ok := info.UsesOf(obj)[0].Name().(types.TypedName)
+		if !ok {
+			return types.InferUntypedError{Name: obj.Ident}
 		}
-		w.Write([]byte(" };"))
-		return nil
-	case *asyncpi.Restrict:
-		if chType, ok := p.Name.(types.TypedName).Type().(*types.Chan); ok { // channel is treated differently.
-			w.Write([]byte(fmt.Sprintf("%s := make(%s); ", p.Name.(types.TypedName).Ident(), chType.String())))
-			if err := gen(p.Proc, w); err != nil {
+		if chType, ok := underlying(n.Type()).(*types.Chan); ok {
+			t, err := tn.ChanString(types.SendRecv, chType.Elem())
+			if err != nil {
 				return err
 			}
-			return nil
+			fmt.Fprintf(w, "%s := make(%s);%s ", ids.Ident(obj), t, ids.Comment(obj))
+			continue
 		}
-		w.Write([]byte(fmt.Sprintf("var %s %s; ", p.Name.Ident(), p.Name.(types.TypedName).Type())))
-		if err := gen(p.Proc, w); err != nil {
+		t, err := tn.TypeString(n.Type())
+		if err != nil {
 			return err
 		}
-		return nil
-	case *asyncpi.Recv:
-		var buf bytes.Buffer
-		switch len(p.Vars) {
-		case 0:
-			buf.WriteString(fmt.Sprintf("<-%s;", p.Chan.Ident()))
-		case 1:
-			buf.WriteString(fmt.Sprintf("%s := <-%s;", p.Vars[0].Ident(), p.Chan.Ident()))
-		default:
-			buf.WriteString(fmt.Sprintf("rcvd := <-%s;", p.Chan.Ident()))
-			for i, v := range p.Vars {
-				if i != 0 {
-					buf.WriteRune(',')
-				}
-				buf.WriteString(fmt.Sprintf("%s", v.Ident()))
+		fmt.Fprintf(w, "var %s %s;%s ", ids.Ident(obj), t, ids.Comment(obj))
+	}
+	return nil
+}
+
+// genCode writes Go code of the Process p resolved as info to w,
+// where names are written by the identNamer ids and types are written
+// by the typeNamer tn. The names renamed by ids are written in comments
+// where they are declared.
+//
+// Names which are declared but not used are assigned to _,
+// as Go does not allow unused variables.
+//
+// If opt.Trace is set, the code emits the events of the trace package,
+// where pos are the source positions of the inputs and outputs of p.
+func genCode(p asyncpi.Process, info *resolve.Info, lin *types.LinearityInfo, pos positions, opt FormatOptions, ids *identNamer, tn *typeNamer, w io.Writer) error {
+	var err error
+	if opt.Trace {
+		w.Write([]byte(traceGo(p, "_pitrace.Go()")))
+	}
+	// ident returns the Go identifier of the i-th Name of Process p
+	// (see resolve.Occurrence).
+	ident := func(p asyncpi.Process, i int) string {
+		return ids.Ident(info.ObjectOf(resolve.Occurrence{Proc: p, Index: i}))
+	}
+	// comment writes the names declared by Process p which are renamed.
+	comment := func(p asyncpi.Process, n int) {
+		for i := 0; i < n; i++ {
+			if obj := info.Defs[resolve.Occurrence{Proc: p, Index: i}]; obj != nil {
+				w.Write([]byte(ids.Comment(obj)))
 			}
-			buf.WriteString(":=")
-			for i := 0; i < len(p.Vars); i++ {
-				if i != 0 {
-					buf.WriteRune(',')
-				}
-				buf.WriteString(fmt.Sprintf("rcvd.e%d", i))
+		}
+	}
+	// unused writes the assignments to _ of the unused names
+	// declared by Process p.
+	unused := func(p asyncpi.Process, n int) {
+		for i := 0; i < n; i++ {
+			o := resolve.Occurrence{Proc: p, Index: i}
+			if obj := info.Defs[o]; obj != nil && len(info.UsesOf(obj)) == 0 {
+				fmt.Fprintf(w, "_ = %s; ", ids.Ident(obj))
 			}
-			buf.WriteRune(';')
 		}
-		w.Write(buf.Bytes())
-		if err := gen(p.Cont, w); err != nil {
-			return err
+	}
+	// Replication contexts of the enclosing Processes, innermost last.
+	var replicas []replication
+	replica := func() replication {
+		if len(replicas) == 0 {
+			return notReplicated
 		}
-		return nil
-	case *asyncpi.Send:
-		var buf bytes.Buffer
-		switch len(p.Vals) {
-		case 0:
-			buf.WriteString(fmt.Sprintf("%s <- struct{}{};", p.Chan.Ident()))
-		case 1:
-			buf.WriteString(fmt.Sprintf("%s <- %s;", p.Chan.Ident(), p.Vals[0].Ident()))
-		default:
-			buf.WriteString(fmt.Sprintf("%s <- struct {", p.Chan.Ident()))
-			for i := 0; i < len(p.Vals); i++ {
-				if i != 0 {
-					buf.WriteRune(';')
+		return replicas[len(replicas)-1]
+	}
+	// blocking writes the channel operation stmt, which is tracked by
+	// the scheduler of a program as it may block. A replicated operation
+	// waiting is not blocked forever, as it may never be needed.
+	// Statements are written on the same line, so stmt ends with ";".
+	blocking := func(server bool, stmt string) {
+		if !opt.Main {
+			w.Write([]byte(stmt))
+			return
+		}
+		fmt.Fprintf(w, "_pi.block(%t); %s _pi.unblock(%t);", server, stmt, server)
+	}
+	// guard writes the channel operation stmt of Process p,
+	// and requests the next replica if p is a guard of a replica.
+	guard := func(stmt string) {
+		blocking(replica() != notReplicated, stmt)
+		if replica() == lazyReplica {
+			w.Write([]byte(" select { case _piNext <- struct{}{}: default: };"))
+		}
+	}
+	var args []string // Arguments of the enclosing goroutines, innermost last.
+	// inGoroutine returns true if the Process at c is run in a new goroutine,
+	// i.e. all but the last Process of a parallel composition.
+	inGoroutine := func(c *asyncpi.Cursor) bool {
+		par, inPar := c.Parent().(*asyncpi.Par)
+		return inPar && c.Index() < len(par.Procs)-1
+	}
+	// goStart writes the start of the goroutine running Process p,
+	// which is traced as the event of the trace function fn.
+	goStart := func(p asyncpi.Process, fn string) {
+		var params, arg string
+		params, arg, err = goroutineParams(p, info, ids, tn)
+		if err != nil {
+			return
+		}
+		if opt.Trace {
+			if params != "" {
+				params, arg = params+", ", arg+", "
+			}
+			params += "_pigo int64"
+			arg += fmt.Sprintf("_pitrace.%s(_pigo, %s)", fn, strconv.Quote(pos.of(p)))
+		}
+		args = append(args, arg)
+		if opt.Main {
+			w.Write([]byte(fmt.Sprintf("_pi.spawn(); go func(%s){ defer _pi.exit(); ", params)))
+		} else {
+			w.Write([]byte(fmt.Sprintf("go func(%s){ ", params)))
+		}
+	}
+	goEnd := func() {
+		w.Write([]byte(fmt.Sprintf(" }(%s)\n", args[len(args)-1])))
+		args = args[:len(args)-1]
+	}
+	asyncpi.Apply(p, func(c *asyncpi.Cursor) bool {
+		if err != nil {
+			return false
+		}
+		if inGoroutine(c) {
+			if goStart(c.Node(), "Spawn"); err != nil {
+				return false
+			}
+		}
+		switch p := c.Node().(type) {
+		case *asyncpi.NilProcess:
+			w.Write([]byte("/* end */"))
+		case *asyncpi.Par:
+		case *asyncpi.Repeat:
+			switch p.Proc.(type) {
+			case *asyncpi.Recv, *asyncpi.Send:
+				replicas = append(replicas, serverReplica)
+				w.Write([]byte("for { "))
+				if opt.Trace {
+					fmt.Fprintf(w, "_pigo := _pitrace.Replicate(_pigo, %s); ", strconv.Quote(pos.of(p)))
+				}
+			default:
+				// The next replica is started when the guard of
+				// the current replica is fired.
+				replicas = append(replicas, lazyReplica)
+				w.Write([]byte("for { _piNext := make(chan struct{}, 1); "))
+				if goStart(p.Proc, "Replicate"); err != nil {
+					return false
+				}
+			}
+		case *asyncpi.Restrict:
+			if chType, ok := underlying(p.Name.(types.TypedName).Type()).(*types.Chan); ok { // channel is treated differently.
+				// Channels are created bidirectional regardless of capability.
+				var t string
+				if t, err = tn.ChanString(types.SendRecv, chType.Elem()); err != nil {
+					return false
+				}
+				if lin.Linearity(p) == types.Linear {
+					w.Write([]byte(fmt.Sprintf("%s := make(%s, 1);", ident(p, 0), t)))
+				} else {
+					w.Write([]byte(fmt.Sprintf("%s := make(%s);", ident(p, 0), t)))
+				}
+				comment(p, 1)
+				w.Write([]byte(" "))
+				unused(p, 1)
+				return true
+			}
+			var t string
+			if t, err = tn.TypeString(p.Name.(types.TypedName).Type()); err != nil {
+				return false
+			}
+			w.Write([]byte(fmt.Sprintf("var %s %s;", ident(p, 0), t)))
+			comment(p, 1)
+			w.Write([]byte(" "))
+			unused(p, 1)
+		case *asyncpi.Recv:
+			var buf bytes.Buffer
+			switch len(p.Vars) {
+			case 0:
+				buf.WriteString(fmt.Sprintf("<-%s;", ident(p, 0)))
+			case 1:
+				buf.WriteString(fmt.Sprintf("%s := <-%s;", ident(p, 1), ident(p, 0)))
+			default:
+				rcvd := ids.Temp()
+				buf.WriteString(fmt.Sprintf("%s := <-%s;", rcvd, ident(p, 0)))
+				for i := range p.Vars {
+					if i != 0 {
+						buf.WriteRune(',')
+					}
+					buf.WriteString(ident(p, i+1))
+				}
+				buf.WriteString(":=")
+				for i := 0; i < len(p.Vars); i++ {
+					if i != 0 {
+						buf.WriteRune(',')
+					}
+					buf.WriteString(fmt.Sprintf("%s.%s", rcvd, tn.FieldName(elemType(p.Chan), i)))
+				}
+				buf.WriteRune(';')
+			}
+			guard(buf.String())
+			comment(p, len(p.Vars)+1)
+			unused(p, len(p.Vars)+1)
+			if opt.Trace {
+				w.Write([]byte(traceOp("Received", p, p.Chan, p.Vars, pos)))
+			}
+			if _, replicated := c.Parent().(*asyncpi.Repeat); replicated && !isNil(p.Cont) {
+				// Continuations of replicated inputs run concurrently.
+				if goStart(p.Cont, "Spawn"); err != nil {
+					return false
 				}
-				buf.WriteString(fmt.Sprintf("e%d %s", i, p.Vals[i].(types.TypedName).Type()))
 			}
-			buf.WriteString(fmt.Sprintf("}{"))
-			for i, v := range p.Vals {
-				if i != 0 {
-					buf.WriteRune(',')
+			replicas = append(replicas, notReplicated)
+		case *asyncpi.Send:
+			var buf bytes.Buffer
+			switch len(p.Vals) {
+			case 0:
+				buf.WriteString(fmt.Sprintf("%s <- struct{}{};", ident(p, 0)))
+			case 1:
+				buf.WriteString(fmt.Sprintf("%s <- %s;", ident(p, 0), ident(p, 1)))
+			default:
+				var t string
+				if t, err = tn.TypeString(elemType(p.Chan)); err != nil {
+					return false
 				}
-				buf.WriteString(v.Ident())
+				buf.WriteString(fmt.Sprintf("%s <- %s{", ident(p, 0), t))
+				for i := range p.Vals {
+					if i != 0 {
+						buf.WriteRune(',')
+					}
+					buf.WriteString(ident(p, i+1))
+				}
+				buf.WriteString("};")
+			}
+			var traced string
+			if opt.Trace {
+				traced = traceOp("Sent", p, p.Chan, p.Vals, pos)
+			}
+			if opt.AsyncSend && replica() == notReplicated {
+				// A pending output is a message in transit, which
+				// is not blocked forever as it may never be received.
+				// The output is traced before it is sent, so it is
+				// traced before it is received.
+				if opt.Main {
+					fmt.Fprintf(w, "_pi.spawn(); go func(){ defer _pi.exit();%s ", traced)
+					blocking(true, buf.String())
+				} else {
+					fmt.Fprintf(w, "go func(){%s ", traced)
+					w.Write(buf.Bytes())
+				}
+				w.Write([]byte(" }();"))
+				break
+			}
+			w.Write([]byte(traced))
+			guard(buf.String())
+		default:
+			err = asyncpi.UnknownProcessError{Proc: p}
+			return false
+		}
+		return true
+	}, func(c *asyncpi.Cursor) bool {
+		switch p := c.Node().(type) {
+		case *asyncpi.Recv:
+			replicas = replicas[:len(replicas)-1]
+			if _, replicated := c.Parent().(*asyncpi.Repeat); replicated && !isNil(p.Cont) {
+				goEnd()
+			}
+		case *asyncpi.Repeat:
+			if replica() == lazyReplica {
+				goEnd()
+				blocking(true, "<-_piNext;")
 			}
-			buf.WriteString(fmt.Sprintf("}"))
+			replicas = replicas[:len(replicas)-1]
+			w.Write([]byte(" };"))
 		}
-		w.Write(buf.Bytes())
-		return nil
+		if inGoroutine(c) {
+			goEnd()
+		}
+		return true
+	})
+	return err
+}
+
+// nameFields names the fields of the composite types received in
+// Process p by the variables of the first input receiving them,
+// unless the variables are not distinct.
+func nameFields(p asyncpi.Process, tn *typeNamer) {
+	asyncpi.Inspect(p, func(p asyncpi.Process) bool {
+		recv, ok := p.(*asyncpi.Recv)
+		if !ok || len(recv.Vars) < 2 {
+			return true
+		}
+		if _, ok := recv.Chan.(types.TypedName); !ok {
+			return true
+		}
+		names := make([]string, len(recv.Vars))
+		seen := make(map[string]bool)
+		for i, v := range recv.Vars {
+			names[i] = v.Ident()
+			if !token.IsIdentifier(names[i]) || names[i] == "_" {
+				names[i] = mangle(names[i])
+			}
+			if seen[names[i]] {
+				return true
+			}
+			seen[names[i]] = true
+		}
+		tn.nameFields(elemType(recv.Chan), names)
+		return true
+	})
+}
+
+// elemType returns the element type of the channel n.
+func elemType(n asyncpi.Name) types.Type {
+	return underlying(n.(types.TypedName).Type()).(*types.Chan).Elem()
+}
+
+// replication is the replication context of a Process.
+type replication int
+
+const (
+	notReplicated replication = iota
+	serverReplica             // Guard of a replicated input or output.
+	lazyReplica               // Guard of a replica started on demand.
+)
+
+// normaliseRepeat returns Process p with replications rewritten by
+// !(P|Q) ≡ !P|!Q, !!P ≡ !P and !0 ≡ 0, so every replicated Process
+// is an input, an output or a restriction.
+func normaliseRepeat(p asyncpi.Process) asyncpi.Process {
+	return asyncpi.Apply(p, nil, func(c *asyncpi.Cursor) bool {
+		if rep, ok := c.Node().(*asyncpi.Repeat); ok {
+			c.Replace(replicate(rep.Proc))
+		}
+		return true
+	})
+}
+
+// replicate returns the replication of the normalised Process p.
+func replicate(p asyncpi.Process) asyncpi.Process {
+	switch p := p.(type) {
+	case *asyncpi.Par:
+		procs := make([]asyncpi.Process, len(p.Procs))
+		for i, proc := range p.Procs {
+			procs[i] = replicate(proc)
+		}
+		return &asyncpi.Par{Procs: procs}
+	case *asyncpi.Repeat:
+		return replicate(p.Proc)
+	case *asyncpi.NilProcess:
+		return p
 	}
-	return nil
+	return asyncpi.NewRepeat(p)
+}
+
+func isNil(p asyncpi.Process) bool {
+	_, ok := p.(*asyncpi.NilProcess)
+	return ok
+}
+
+// goroutineParams returns the parameter list of the goroutine running
+// Process p in the Process resolved as info, i.e. the free names of p,
+// and the arguments of the goroutine.
+func goroutineParams(p asyncpi.Process, info *resolve.Info, ids *identNamer, tn *typeNamer) (string, string, error) {
+	pInfo, err := resolve.Resolve(p)
+	if err != nil {
+		return "", "", err
+	}
+	var params, args bytes.Buffer
+	for i, obj := range pInfo.FreeNames() {
+		uses := pInfo.UsesOf(obj)
+		s, err := paramType(obj, uses, tn)
+		if err != nil {
+			return "", "", err
+		}
+		if i != 0 {
+			params.WriteString(", ")
+			args.WriteString(", ")
+		}
+		// The free names of p are the names of the enclosing Process.
+		ident := ids.Ident(info.Uses[uses[0]])
+		params.WriteString(fmt.Sprintf("%s %s", ident, s))
+		args.WriteString(ident)
+	}
+	return params.String(), args.String(), nil
+}
+
+// paramType returns the Go type of the parameter for the free name obj
+// of a Process, where uses are the uses of obj in the Process.
+//
+// Channel parameters only have the capabilities the uses need, e.g. an
+// output-only channel type if the Process only sends on it, unless the
+// channel is sent as a value, which needs the bidirectional type as the
+// element types of channels are bidirectional. The capability is only
+// written in the outermost channel type literal, so a bidirectional
+// channel can be passed as the argument.
+func paramType(obj *resolve.Object, uses []resolve.Occurrence, tn *typeNamer) (string, error) {
+	n, ok := uses[0].Name().(types.TypedName)
+	if !ok {
+		return "", types.InferUntypedError{Name: obj.Ident}
+	}
+	ch, isChan := underlying(n.Type()).(*types.Chan)
+	if !isChan {
+		return tn.TypeString(n.Type())
+	}
+	var dir types.ChanDir
+	for _, u := range uses {
+		switch {
+		case u.Index > 0: // Sent as a value.
+			dir = types.SendRecv
+		case isSend(u.Proc):
+			dir |= types.SendOnly
+		default:
+			dir |= types.RecvOnly
+		}
+	}
+	return tn.ChanString(dir, ch.Elem())
+}
+
+func isSend(p asyncpi.Process) bool {
+	_, ok := p.(*asyncpi.Send)
+	return ok
+}
+
+// underlying returns the type t with all References resolved.
+func underlying(t types.Type) types.Type {
+	for t.Underlying() != t {
+		t = t.Underlying()
+	}
+	return t
 }
@@ -2,11 +2,14 @@ package golang_test
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"testing"
 
 	"go.nickng.io/asyncpi"
 	"go.nickng.io/asyncpi/codegen/golang"
+	"go.nickng.io/asyncpi/internal/errors"
 	"go.nickng.io/asyncpi/types"
 )
 
@@ -16,16 +19,71 @@ func ExampleGenerate() {
 	if err != nil {
 		fmt.Println(err) // Parse failed
 	}
-	if err := asyncpi.Bind(&p); err != nil {
+	if err := golang.Generate(p, os.Stdout); err != nil {
+		fmt.Println(err) // Type inference failed
+	}
+	// Output: type T0 = chan struct{}; type T1 = chan T0; a := make(T1, 1); b := make(T0, 1); go func(a T1, b T0){ go func(a chan<- T0, b T0){ a <- b; }(a, b)
+	//x := <-a;x <- struct{}{}; }(a, b)
+	//<-b;/* end */
+}
+
+// This example shows how a recursive type is declared as a named type.
+func ExampleGenerate_recursive() {
+	p, err := asyncpi.Parse(strings.NewReader("(new a)(a<a> | a(x).x<x>)"))
+	if err != nil {
+		fmt.Println(err) // Parse failed
+	}
+	if err := golang.Generate(p, os.Stdout); err != nil {
 		fmt.Println(err)
 	}
-	types.Infer(p)
-	err = types.Unify(p)
+	// Output: type T0 chan T0; a := make(T0); go func(a T0){ a <- a; }(a)
+	//x := <-a;x <- x;
+}
+
+// This example shows how to generate a function from a Process, where the
+// channel parameters only have the capabilities the Process needs.
+func ExampleGenerateFunc() {
+	p, err := asyncpi.Parse(strings.NewReader("a(x).b<x>"))
 	if err != nil {
-		fmt.Println(err) // Unify failed
+		fmt.Println(err) // Parse failed
+	}
+	if err := golang.GenerateFunc("name", p, golang.FormatOptions{Format: true}, os.Stdout); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// // name runs the Process a(x).b<x>
+	// func name(a <-chan interface{}, b chan<- interface{}) {
+	// 	x := <-a
+	// 	b <- x
+	// }
+}
+
+// Type conflicts are reported at the uses of the channel.
+func TestGenerateConflicts(t *testing.T) {
+	p, err := asyncpi.Parse(strings.NewReader("(new c)(c<> | c<a> | c<a,b>)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = golang.Generate(p, io.Discard)
+	causer, ok := err.(errors.Causer)
+	if !ok {
+		t.Fatalf("expects conflicts but got %v", err)
+	}
+	conflicts, ok := causer.Cause().(*types.ConflictError)
+	if !ok {
+		t.Fatalf("expects *types.ConflictError but got %v", causer.Cause())
+	}
+	want := []string{"1:15 1:9", "1:22 1:9"}
+	if len(conflicts.Errs) != len(want) {
+		t.Fatalf("expects %d conflicts but got %d: %v", len(want), len(conflicts.Errs), conflicts)
+	}
+	for i, err := range conflicts.Errs {
+		var got string
+		if err, ok := err.(*types.TypeArityError); ok {
+			got = fmt.Sprintf("%s %s", err.Pos, err.PrevPos)
+		}
+		if got != want[i] {
+			t.Errorf("expects conflict at %s but got %s (%v)", want[i], got, err)
+		}
 	}
-	golang.Generate(p, os.Stdout)
-	// Output: a := make(chan chan struct{}); b := make(chan struct{}); go func(){ go func(){ a <- b; }()
-	//x := <-a;x <- struct{}{}; }()
-	//<-b;/* end */
 }
@@ -0,0 +1,198 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"io"
+	"strings"
+
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/resolve"
+	"go.nickng.io/asyncpi/types"
+)
+
+// NameError is the type of error when the name of a generated function
+// or package is not valid.
+type NameError struct {
+	Name string
+	Msg  string
+}
+
+func (e NameError) Error() string {
+	return fmt.Sprintf("invalid name %q: %s", e.Name, e.Msg)
+}
+
+// Func is a Process generated as the Go function Name.
+type Func struct {
+	Name string
+	Proc asyncpi.Process
+}
+
+// GenerateFunc writes the Process p as the Go function name to w, e.g.
+// a(x).b<x> is written as
+//
+//     // name runs the Process a(x).b<x>
+//     func name(a <-chan interface{}, b chan<- interface{}) {
+//         x := <-a
+//         b <- x
+//     }
+//
+// where the parameters are the free names of p, and the types are the
+// types inferred for them. Channel parameters only have the capabilities
+// p needs, e.g. a is <-chan interface{} as p only receives on a, so the
+// caller can pass bidirectional channels of the element types. The named
+// types of recursive types are declared before the function.
+//
+// If opt.Runtime is set, the function targets the runtime package (see
+// GenerateOpts), so the parameters are the *pi.Group to start the
+// processes in, followed by the names as *pi.Chan or pi.Value.
+//
+// The options opt.Main and opt.Debug are ignored.
+func GenerateFunc(name string, p asyncpi.Process, opt FormatOptions, w io.Writer) error {
+	var code bytes.Buffer
+	if err := genFuncs([]Func{{Name: name, Proc: p}}, opt, &code); err != nil {
+		return err
+	}
+	return writeCode(code.Bytes(), opt, true, w)
+}
+
+// GeneratePackage writes a Go source file of package pkg to w with a
+// function for each of funcs (see GenerateFunc). The file is marked as
+// generated, so it is suitable as the output of go:generate.
+//
+// The named types of recursive types are shared by the functions.
+func GeneratePackage(pkg string, funcs []Func, opt FormatOptions, w io.Writer) error {
+	if !token.IsIdentifier(pkg) || pkg == "_" {
+		return NameError{Name: pkg, Msg: "not a package name"}
+	}
+	var code bytes.Buffer
+	fmt.Fprintf(&code, "// Code generated by asyncpi. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	var imports []string
+	if opt.Runtime {
+		imports = append(imports, runtimeImport)
+	}
+	if opt.Trace {
+		imports = append(imports, traceImport)
+	}
+	if len(imports) > 0 {
+		fmt.Fprintf(&code, "import (\n\t%s\n)\n\n", strings.Join(imports, "\n\t"))
+	}
+	if err := genFuncs(funcs, opt, &code); err != nil {
+		return err
+	}
+	return writeCode(code.Bytes(), opt, false, w)
+}
+
+// genFuncs writes the Go functions of funcs to w.
+func genFuncs(funcs []Func, opt FormatOptions, w io.Writer) error {
+	opt.Main = false
+	type genFunc struct {
+		p    asyncpi.Process
+		lin  *types.LinearityInfo
+		pos  positions
+		info *resolve.Info
+		ids  *identNamer
+	}
+	gens := make([]genFunc, len(funcs))
+	idents := make(map[string]bool) // Identifiers of functions and names.
+	for _, f := range funcs {
+		switch {
+		case !token.IsIdentifier(f.Name) || f.Name == "_":
+			return NameError{Name: f.Name, Msg: "not a function name"}
+		case idents[f.Name]:
+			return NameError{Name: f.Name, Msg: "function already generated"}
+		}
+		idents[f.Name] = true
+	}
+	for i, f := range funcs {
+		decls := declIdentsOf(f.Proc)
+		p, lin, pos, err := prepare(f.Proc)
+		if err != nil {
+			return err
+		}
+		info, err := resolve.Resolve(p)
+		if err != nil {
+			return err
+		}
+		var ids *identNamer
+		if opt.Runtime {
+			ids = newIdentNamer(info, decls, runtimePkg)
+		} else {
+			ids = newIdentNamer(info, decls)
+		}
+		for ident := range ids.used {
+			idents[ident] = true
+		}
+		gens[i] = genFunc{p: p, lin: lin, pos: pos, info: info, ids: ids}
+	}
+	tn := newTypeNamer(idents)
+	for _, g := range gens {
+		nameFields(g.p, tn)
+	}
+	var code bytes.Buffer
+	for i, f := range funcs {
+		var params string
+		var err error
+		if opt.Runtime {
+			params, err = runtimeParams(gens[i].info, gens[i].ids)
+		} else {
+			params, err = funcParams(gens[i].info, gens[i].ids, tn)
+		}
+		if err != nil {
+			return err
+		}
+		if i != 0 {
+			code.WriteString("\n")
+		}
+		fmt.Fprintf(&code, "// %s runs the Process %s\nfunc %s(%s) {\n", f.Name, f.Proc.Calculi(), f.Name, params)
+		if opt.Runtime {
+			err = genRuntimeCode(gens[i].p, gens[i].info, gens[i].pos, opt, gens[i].ids, &code)
+		} else {
+			err = genCode(gens[i].p, gens[i].info, gens[i].lin, gens[i].pos, opt, gens[i].ids, tn, &code)
+		}
+		if err != nil {
+			return err
+		}
+		code.WriteString("\n}\n")
+	}
+	if decls := tn.Decls(); len(decls) > 0 {
+		if _, err := fmt.Fprintf(w, "%s\n\n", decls); err != nil {
+			return err
+		}
+	}
+	_, err := io.Copy(w, &code)
+	return err
+}
+
+// funcParams returns the parameter list of the function of the Process
+// resolved as info, i.e. the free names, where channels only have the
+// capabilities the Process needs (see paramType).
+func funcParams(info *resolve.Info, ids *identNamer, tn *typeNamer) (string, error) {
+	var buf bytes.Buffer
+	for i, obj := range info.FreeNames() {
+		s, err := paramType(obj, info.UsesOf(obj), tn)
+		if err != nil {
+			return "", err
+		}
+		if i != 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(fmt.Sprintf("%s %s%s", ids.Ident(obj), s, ids.Comment(obj)))
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,130 @@
+package golang
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.nickng.io/asyncpi"
+)
+
+func TestGenerateFunc(t *testing.T) {
+	p, err := asyncpi.Parse(strings.NewReader("(new c)(r<c> | c(x).x<x>)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := GenerateFunc("Serve", p, FormatOptions{Format: true}, &b); err != nil {
+		t.Fatal(err)
+	}
+	expected := `type T0 chan T0
+
+// Serve runs the Process (new c)(r<c> | c(x).x<x>)
+func Serve(r chan<- T0) {
+	c := make(T0)
+	go func(c T0, r chan<- T0) { r <- c }(c, r)
+	x := <-c
+	x <- x
+}
+`
+	if b.String() != expected {
+		t.Errorf("expects\n%s\nbut got\n%s", expected, b.String())
+	}
+}
+
+// Functions of the runtime package start the processes in a Group.
+func TestGenerateFuncRuntime(t *testing.T) {
+	p, err := asyncpi.Parse(strings.NewReader("(new c)(r<c> | c(x).x<x>)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := GenerateFunc("Serve", p, FormatOptions{Format: true, Runtime: true}, &b); err != nil {
+		t.Fatal(err)
+	}
+	expected := `// Serve runs the Process (new c)(r<c> | c(x).x<x>)
+func Serve(_pi *pi.Group, r *pi.Chan) {
+	c := _pi.New("c")
+	_pi.Spawn(func() { r.Send(c) })
+	x := c.Recv()[0].(*pi.Chan)
+	x.Send(x)
+}
+`
+	if b.String() != expected {
+		t.Errorf("expects\n%s\nbut got\n%s", expected, b.String())
+	}
+}
+
+func TestGeneratePackage(t *testing.T) {
+	var funcs []Func
+	for _, f := range []struct{ Name, Proc string }{
+		{"Forward", "a(x).b<x>"},
+		{"Serve", "(new c)(r<c> | c(x).x<x>)"},
+		{"Loop", "(new a)(a<a> | a(x).x<x>)"},
+		{"Echo", "!a(x,r).r<x>"},
+		{"Pass", "a<b> | b<>"},
+	} {
+		p, err := asyncpi.Parse(strings.NewReader(f.Proc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		funcs = append(funcs, Func{Name: f.Name, Proc: p})
+	}
+	var b bytes.Buffer
+	if err := GeneratePackage("pi", funcs, FormatOptions{Format: true}, &b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "// Code generated by asyncpi. DO NOT EDIT.\n\npackage pi\n") {
+		t.Errorf("expects a generated file of package pi but got\n%s", b.String())
+	}
+	if testing.Short() {
+		t.Skip("skipping building generated package in short mode")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	dir := t.TempDir()
+	file, caller := filepath.Join(dir, "pi.go"), filepath.Join(dir, "caller.go")
+	if err := os.WriteFile(file, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Channels of type literals can be passed to the functions.
+	callerSrc := "package pi\n\nfunc caller() { Pass(make(chan chan struct{}), make(chan struct{})) }\n"
+	if err := os.WriteFile(caller, []byte(callerSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(goBin, "vet", file, caller).CombinedOutput(); err != nil {
+		t.Errorf("cannot build generated package: %v\n%s\n%s", err, out, b.String())
+	}
+}
+
+func TestGeneratePackageNameError(t *testing.T) {
+	p, err := asyncpi.Parse(strings.NewReader("a<>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		Pkg   string
+		Funcs []Func
+		Name  string
+	}{
+		{"my-pkg", []Func{{Name: "F", Proc: p}}, "my-pkg"},
+		{"pi", []Func{{Name: "1F", Proc: p}}, "1F"},
+		{"pi", []Func{{Name: "F", Proc: p}, {Name: "F", Proc: p}}, "F"},
+	}
+	for _, test := range tests {
+		err := GeneratePackage(test.Pkg, test.Funcs, FormatOptions{}, &bytes.Buffer{})
+		nameErr, ok := err.(NameError)
+		if !ok {
+			t.Errorf("expects NameError but got %v", err)
+			continue
+		}
+		if nameErr.Name != test.Name {
+			t.Errorf("expects invalid name %s but got %s", test.Name, nameErr.Name)
+		}
+	}
+}
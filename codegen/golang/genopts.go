@@ -27,12 +27,102 @@ import (
 // FormatOptions defines options for changing
 // the format of generated code.
 type FormatOptions struct {
-	Main     bool
-	Debug    bool
-	Format   bool
-	FmtStyle FormatStyle
+	Main      bool
+	Debug     bool
+	Format    bool
+	FmtStyle  FormatStyle
+	AsyncSend bool // Outputs never block, as in the asynchronous π-calculus.
+	Runtime   bool // Target the runtime package instead of Go channels.
+	Trace     bool // Emit the events of the trace package.
 }
 
+// progHeader is the start of a program up to the code of the Process.
+const progHeader = `package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func main() {
+	go _pi.monitor()
+	_pi.spawn()
+	go func() {
+	defer _pi.exit()
+`
+
+// progFooter is the end of a program after the code of the Process,
+// which includes the scheduler tracking the processes.
+const progFooter = `
+	}()
+	_pi.wg.Wait()
+}
+
+// _piScheduler tracks the running processes and the processes blocked
+// on a channel, to detect termination and deadlocks.
+type _piScheduler struct {
+	wg       sync.WaitGroup
+	running  int64 // Processes not finished.
+	blocked  int64 // Processes blocked on a channel.
+	servers  int64 // Replicated inputs and pending outputs blocked on a channel.
+	progress int64 // Channel operations completed.
+}
+
+var _pi _piScheduler
+
+func (s *_piScheduler) spawn() {
+	s.wg.Add(1)
+	atomic.AddInt64(&s.running, 1)
+}
+
+func (s *_piScheduler) exit() {
+	atomic.AddInt64(&s.running, -1)
+	s.wg.Done()
+}
+
+func (s *_piScheduler) block(server bool) {
+	if server {
+		atomic.AddInt64(&s.servers, 1)
+	}
+	atomic.AddInt64(&s.blocked, 1)
+}
+
+func (s *_piScheduler) unblock(server bool) {
+	atomic.AddInt64(&s.blocked, -1)
+	if server {
+		atomic.AddInt64(&s.servers, -1)
+	}
+	atomic.AddInt64(&s.progress, 1)
+}
+
+// monitor exits the program when all processes are blocked without
+// progress: the program has terminated if only replicated inputs and
+// pending outputs are blocked, otherwise it is deadlocked.
+func (s *_piScheduler) monitor() {
+	last := int64(-1)
+	for range time.Tick(10 * time.Millisecond) {
+		running, blocked := atomic.LoadInt64(&s.running), atomic.LoadInt64(&s.blocked)
+		servers, progress := atomic.LoadInt64(&s.servers), atomic.LoadInt64(&s.progress)
+		if running == 0 || blocked < running {
+			last = -1
+			continue
+		}
+		if progress != last {
+			last = progress
+			continue
+		}
+		if servers == blocked {
+			os.Exit(0)
+		}
+		fmt.Fprintf(os.Stderr, "deadlock: %d process(es) blocked\n", blocked-servers)
+		os.Exit(1)
+	}
+}
+`
+
 // FormatStyle defines the tools to use for formatting code
 type FormatStyle int
 
@@ -44,34 +134,66 @@ const (
 )
 
 // GenerateOpts writes Go code of the Process p to w using options opt.
+//
+// If opt.Main is set, the code is a complete program, which waits for
+// all processes to finish. The free names of p are declared as
+// channels of the program. The program exits with status 0 when every
+// process has finished or only replicated inputs are waiting, and
+// reports a deadlock with status 1 when other processes are blocked
+// forever.
+//
+// If opt.AsyncSend is set, outputs are asynchronous as in the calculus:
+// each output is sent in a new goroutine, so a Process continues after
+// an output without waiting for the receiver. Replicated outputs still
+// wait, as each replica is only needed when received. In a program,
+// outputs never received do not deadlock the program.
+//
+// If opt.Runtime is set, the code targets the runtime package, which is
+// imported as pi, instead of Go channels: channels are *pi.Chan, other
+// names are pi.Values, and outputs never block. The program runs the
+// Process with pi.Run, which detects termination and deadlocks. A code
+// fragment expects the runtime package to be imported as pi, and the
+// *pi.Group to start the processes in as _pi.
+//
+// If opt.Trace is set, the code emits an event of the trace package at
+// every send, receive, spawn and replica creation, with the number of
+// the goroutine, the names of the channel and the payload, and the
+// position of the operation in the source of p. A code fragment expects
+// the trace package to be imported as _pitrace.
 func GenerateOpts(p asyncpi.Process, opt FormatOptions, w io.Writer) error {
 	var program bytes.Buffer
-	const progHeader = `package main
-	func main() {
-`
-	const progFooter = `
-}`
-
-	if opt.Main {
-		fmt.Fprintf(&program, progHeader)
+	var header, footer string
+	switch {
+	case opt.Main && opt.Runtime:
+		header, footer = runtimeProgHeader, runtimeProgFooter
+	case opt.Main:
+		header, footer = progHeader, progFooter
+	}
+	if opt.Trace {
+		header = withTraceImport(header)
 	}
+	program.WriteString(header)
 	if opt.Debug {
 		fmt.Fprintf(&program, "// Process %s\n", p.Calculi())
 		fmt.Fprint(&program, `fmt.Fprintln(os.Stderr, "--- start ---");`)
 	}
-	if err := Generate(p, &program); err != nil {
+	if err := generate(p, opt, &program); err != nil {
 		return err
 	}
 	if opt.Debug {
 		fmt.Fprint(&program, `fmt.Fprintln(os.Stderr, "--- end ---");`)
 	}
-	if opt.Main {
-		fmt.Fprint(&program, progFooter)
-	}
+	program.WriteString(footer)
+	return writeCode(program.Bytes(), opt, !opt.Main, w)
+}
+
+// writeCode writes the code to w, formatted if opt.Format is set,
+// where fragment is set if code is not a complete source file.
+func writeCode(code []byte, opt FormatOptions, fragment bool, w io.Writer) error {
 	if opt.Format {
 		switch opt.FmtStyle {
 		case Gofmt:
-			b, err := format.Source(program.Bytes())
+			b, err := format.Source(code)
 			if err != nil {
 				return err
 			}
@@ -80,9 +202,9 @@ func GenerateOpts(p asyncpi.Process, opt FormatOptions, w io.Writer) error {
 				return err
 			}
 		case GoImports:
-			b, err := imports.Process("/tmp/tmp.go", program.Bytes(), &imports.Options{
+			b, err := imports.Process("/tmp/tmp.go", code, &imports.Options{
 				Comments:  true,
-				Fragment:  !opt.Main,
+				Fragment:  fragment,
 				TabIndent: true,
 			})
 			if err != nil {
@@ -95,9 +217,6 @@ func GenerateOpts(p asyncpi.Process, opt FormatOptions, w io.Writer) error {
 		}
 		return nil
 	}
-	_, err := io.Copy(w, &program)
-	if err != nil {
-		return err
-	}
-	return nil
+	_, err := w.Write(code)
+	return err
 }
@@ -0,0 +1,215 @@
+package golang
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.nickng.io/asyncpi"
+)
+
+// runProgram generates the program of Process proc with options opt,
+// then runs it and returns its stderr and exit code.
+func runProgram(t *testing.T, proc string, opt FormatOptions) (string, int) {
+	t.Helper()
+	bin := buildProgram(t, proc, opt)
+	var stderr bytes.Buffer
+	cmd := exec.Command(bin)
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return stderr.String(), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stderr.String(), 0
+}
+
+// buildProgram generates the program of Process proc with options opt,
+// then builds it and returns the path of the binary.
+func buildProgram(t *testing.T, proc string, opt FormatOptions) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping building generated program in short mode")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	p, err := asyncpi.Parse(strings.NewReader(proc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prog bytes.Buffer
+	opt.Main, opt.Format = true, true
+	if err := GenerateOpts(p, opt, &prog); err != nil {
+		t.Fatal(err)
+	}
+	// The program is built in the module, so it can import the runtime package.
+	dir, err := os.MkdirTemp(".", "_prog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	file, bin := filepath.Join(dir, "main.go"), filepath.Join(dir, "main")
+	if err := os.WriteFile(file, prog.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(goBin, "build", "-o", bin, file).CombinedOutput(); err != nil {
+		t.Fatalf("cannot build program of %s: %v\n%s", proc, err, out)
+	}
+	return bin
+}
+
+func TestGenerateProgram(t *testing.T) {
+	tests := []struct {
+		Proc   string
+		Stderr string
+		Exit   int
+	}{
+		{"(new a)(new b)(a<b> | a(x).x<> | b().0)", "", 0},
+		// Free names are channels of the program.
+		{"a<> | a().0", "", 0},
+		// Replicated inputs waiting for inputs are terminated.
+		{"(new a)(a<> | !a().0)", "", 0},
+		{"(new a)(a(x).0 | b().a<b>)", "deadlock: 2 process(es) blocked\n", 1},
+		{"(new a)(a<a> | a(x).x<x>)", "deadlock: 1 process(es) blocked\n", 1},
+		// Capabilities of names do not change the types of other names.
+		{"(new a,x)(a<x> | a(y).y<a> | x(z).0)", "", 0},
+		{"(new a,x)(new b)(a(x).x<a> | x<b>)", "deadlock: 2 process(es) blocked\n", 1},
+		{"(new r)(new b)(a(x).x<a> | x<b> | r<a> | r(y).y(z).0)", "deadlock: 3 process(es) blocked\n", 1},
+		// Channels sent on themselves.
+		{"(new b)b<b>", "deadlock: 1 process(es) blocked\n", 1},
+		{"(new a,b)(a<b> | b<b> | a(x).x(y).y<y>)", "deadlock: 1 process(es) blocked\n", 1},
+		{"(new a)(a<a> | a(x).(new c)(x<c> | c<c>))", "deadlock: 2 process(es) blocked\n", 1},
+		{"(new a,b,c)(a<b,c> | a(x,y).x<y> | b<b> | c(z).z<z>)", "deadlock: 3 process(es) blocked\n", 1},
+	}
+	for _, test := range tests {
+		stderr, exit := runProgram(t, test.Proc, FormatOptions{})
+		if test.Stderr != stderr || test.Exit != exit {
+			t.Errorf("expects %s to exit %d with %q but got exit %d with %q",
+				test.Proc, test.Exit, test.Stderr, exit, stderr)
+		}
+	}
+}
+
+func TestGenerateReplication(t *testing.T) {
+	tests := []struct {
+		Proc   string
+		Stderr string
+		Exit   int
+	}{
+		// The continuation of the second input depends on the first,
+		// so inputs must not wait for the previous continuation.
+		{"(new a,b,c,d)(!a(r,s).r().s<> | a<b,c> | a<d,b> | d<> | c().0)", "", 0},
+		{"(new a)(!a<> | a().a().a().0)", "", 0},
+		// Replicas with a private channel are started on demand.
+		{"(new a)(!(new c)(a<c> | c().0) | a(x).x<> | a(y).y<>)", "", 0},
+		{"(new a,b)(!(a<> | b().0) | a().a().(b<> | b<>))", "", 0},
+		{"(new a)(!!a().0 | a<> | a<>)", "", 0},
+		{"(new a,b)(!a(x).x().0 | a<b>)", "deadlock: 1 process(es) blocked\n", 1},
+		// Polyadic outputs of replicas with a private channel.
+		{"(new b)(!(new y)b<y,y> | b(x,z).(x<> | z().0))", "", 0},
+	}
+	for _, test := range tests {
+		// The code fragment must also be valid without the scheduler.
+		p, err := asyncpi.Parse(strings.NewReader(test.Proc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := GenerateOpts(p, FormatOptions{Format: true}, io.Discard); err != nil {
+			t.Errorf("expects %s to generate valid code but got %v", test.Proc, err)
+		}
+		stderr, exit := runProgram(t, test.Proc, FormatOptions{})
+		if test.Stderr != stderr || test.Exit != exit {
+			t.Errorf("expects %s to exit %d with %q but got exit %d with %q",
+				test.Proc, test.Exit, test.Stderr, exit, stderr)
+		}
+	}
+}
+
+func TestGenerateAsyncSend(t *testing.T) {
+	tests := []struct {
+		Proc   string
+		Stderr string
+		Exit   int
+	}{
+		// Outputs not received are not blocked forever.
+		{"(new a,b)(a<> | a<> | a().b<>)", "", 0},
+		{"(new a,b)(!a(x).x<> | a<b> | a<b> | b().0)", "", 0},
+		{"(new a)(a().0)", "deadlock: 1 process(es) blocked\n", 1},
+		// Channels sent on themselves.
+		{"(new b)b<b>", "", 0},
+		{"(new a,b)(a<b> | b<b> | a(x).x(y).y<y>)", "", 0},
+		{"(new a)(a<a> | a(x).(new c)(x<c> | c<c>))", "", 0},
+		{"(new a,b,c)(a<b,c> | a(x,y).x<y> | b<b> | c(z).z<z>)", "deadlock: 1 process(es) blocked\n", 1},
+	}
+	for _, test := range tests {
+		stderr, exit := runProgram(t, test.Proc, FormatOptions{AsyncSend: true})
+		if test.Stderr != stderr || test.Exit != exit {
+			t.Errorf("expects %s to exit %d with %q but got exit %d with %q",
+				test.Proc, test.Exit, test.Stderr, exit, stderr)
+		}
+	}
+}
+
+func TestGenerateRuntime(t *testing.T) {
+	tests := []struct {
+		Proc   string
+		Stderr string
+		Exit   int
+	}{
+		{"(new a)(new b)(a<b> | a(x).x<> | b().0)", "", 0},
+		{"a<> | a().0", "", 0},
+		// Outputs not received are not blocked forever.
+		{"(new a,b)(a<> | a<> | a().b<>)", "", 0},
+		{"(new a,b)(a<b,b> | a(x,y).x<y> | b(z).0)", "", 0},
+		{"(new a,b,c,d)(!a(r,s).r().s<> | a<b,c> | a<d,b> | d<> | c().0)", "", 0},
+		{"(new a)(!a<> | a().a().a().0)", "", 0},
+		{"(new a)(!(new c)(a<c> | c().0) | a(x).x<> | a(y).y<>)", "", 0},
+		{"(new a)(!!a().0 | a<> | a<>)", "", 0},
+		{"(new a)(a(x).0 | b().a<b>)", "deadlock: 2 process(es) blocked\n", 1},
+		{"(new a,b)(!a(x).x().0 | a<b>)", "deadlock: 1 process(es) blocked\n", 1},
+	}
+	for _, test := range tests {
+		stderr, exit := runProgram(t, test.Proc, FormatOptions{Runtime: true})
+		if test.Stderr != stderr || test.Exit != exit {
+			t.Errorf("expects %s to exit %d with %q but got exit %d with %q",
+				test.Proc, test.Exit, test.Stderr, exit, stderr)
+		}
+	}
+}
+
+// The programs of all examples build, except the examples which
+// are not valid processes.
+func TestGenerateExamples(t *testing.T) {
+	invalid := map[string]bool{"ml-syntax-error.pi": true, "syntax-error.pi": true, "unbound.pi": true}
+	files, err := filepath.Glob("../../examples/*.pi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if invalid[filepath.Base(file)] {
+			p, err := asyncpi.Parse(bytes.NewReader(b))
+			if err == nil {
+				err = Generate(p, io.Discard)
+			}
+			if err == nil {
+				t.Errorf("expects error generating %s but got nil", file)
+			}
+			continue
+		}
+		for _, opt := range []FormatOptions{{}, {AsyncSend: true}, {Runtime: true}} {
+			buildProgram(t, string(b), opt)
+		}
+	}
+}
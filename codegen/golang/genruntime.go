@@ -0,0 +1,281 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/resolve"
+	"go.nickng.io/asyncpi/types"
+)
+
+// Runtime backend.
+// This file contains the code generation targeting the runtime package,
+// where a channel is a *runtime.Chan and the other names are
+// runtime.Values, e.g. (new a)(a<b,c> | a(x,y).x<y>) is written as
+//
+//     a := _pi.New("a")
+//     _pi.Spawn(func() { a.Send(b, c) })
+//     _pitmp0 := a.Recv()
+//     x, y := _pitmp0[0].(*pi.Chan), _pitmp0[1]
+//     x.Send(y)
+//
+// where pi is the name of the imported runtime package, and _pi is the
+// *pi.Group of the processes.
+
+// runtimePkg is the name of the runtime package in generated code.
+const runtimePkg = "pi"
+
+// runtimeGroup is the *pi.Group of the processes in generated code.
+const runtimeGroup = reservedPrefix
+
+// runtimeImport is the import of the runtime package.
+const runtimeImport = runtimePkg + ` "go.nickng.io/asyncpi/runtime"`
+
+// runtimeProgHeader is the start of a program using the runtime package
+// up to the code of the Process.
+const runtimeProgHeader = `package main
+
+import (
+	"fmt"
+	"os"
+
+	` + runtimeImport + `
+)
+
+func main() {
+	if err := pi.Run(func(_pi *pi.Group) {
+`
+
+// runtimeProgFooter is the end of a program using the runtime package
+// after the code of the Process.
+const runtimeProgFooter = `
+	}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+`
+
+// runtimeType returns the Go type of the name n in the runtime package.
+func runtimeType(n asyncpi.Name) (string, error) {
+	tn, ok := n.(types.TypedName)
+	if !ok {
+		return "", types.InferUntypedError{Name: n.Ident()}
+	}
+	if _, isChan := underlying(tn.Type()).(*types.Chan); isChan {
+		return "*" + runtimePkg + ".Chan", nil
+	}
+	return runtimePkg + ".Value", nil
+}
+
+// genRuntimeFreeNames writes the declarations of the free names in info
+// as the names of the runtime package.
+func genRuntimeFreeNames(info *resolve.Info, ids *identNamer, w io.Writer) error {
+	for _, obj := range info.FreeNames() {
+		t, err := runtimeType(info.UsesOf(obj)[0].Name())
+		if err != nil {
+			return err
+		}
+		if t == runtimePkg+".Value" {
+			fmt.Fprintf(w, "var %s %s;%s ", ids.Ident(obj), t, ids.Comment(obj))
+			continue
+		}
+		fmt.Fprintf(w, "%s := %s.New(%s);%s ", ids.Ident(obj), runtimeGroup, strconv.Quote(obj.Ident), ids.Comment(obj))
+	}
+	return nil
+}
+
+// runtimeParams returns the parameter list of the function of the
+// Process resolved as info, i.e. the *pi.Group which the processes are
+// started in, followed by the free names.
+func runtimeParams(info *resolve.Info, ids *identNamer) (string, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s *%s.Group", runtimeGroup, runtimePkg)
+	for _, obj := range info.FreeNames() {
+		t, err := runtimeType(info.UsesOf(obj)[0].Name())
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(", ")
+		buf.WriteString(fmt.Sprintf("%s %s%s", ids.Ident(obj), t, ids.Comment(obj)))
+	}
+	return buf.String(), nil
+}
+
+// genRuntimeCode writes Go code of the Process p resolved as info to w,
+// targeting the runtime package, where names are written by the
+// identNamer ids.
+//
+// Each Process of a parallel composition but the last is spawned, and
+// the guards of a replicated Process, i.e. its first inputs and
+// outputs, are performed with its Replica.
+//
+// If opt.Trace is set, the code emits the events of the trace package,
+// where pos are the source positions of the inputs and outputs of p.
+func genRuntimeCode(p asyncpi.Process, info *resolve.Info, pos positions, opt FormatOptions, ids *identNamer, w io.Writer) error {
+	var err error
+	if opt.Trace {
+		w.Write([]byte(traceGo(p, "_pitrace.Go()")))
+	}
+	ident := func(p asyncpi.Process, i int) string {
+		return ids.Ident(info.ObjectOf(resolve.Occurrence{Proc: p, Index: i}))
+	}
+	// decls writes the comments and the assignments to _ of the names
+	// declared by Process p.
+	decls := func(p asyncpi.Process, n int) {
+		for i := 0; i < n; i++ {
+			if obj := info.Defs[resolve.Occurrence{Proc: p, Index: i}]; obj != nil {
+				w.Write([]byte(ids.Comment(obj)))
+			}
+		}
+		for i := 0; i < n; i++ {
+			o := resolve.Occurrence{Proc: p, Index: i}
+			if obj := info.Defs[o]; obj != nil && len(info.UsesOf(obj)) == 0 {
+				fmt.Fprintf(w, " _ = %s;", ids.Ident(obj))
+			}
+		}
+		w.Write([]byte(" "))
+	}
+	// Whether the operations of the enclosing Processes are guards
+	// of a replica, innermost last.
+	var guards []bool
+	guard := func() bool {
+		return len(guards) > 0 && guards[len(guards)-1]
+	}
+	inSpawn := func(c *asyncpi.Cursor) bool {
+		par, inPar := c.Parent().(*asyncpi.Par)
+		return inPar && c.Index() < len(par.Procs)-1
+	}
+	asyncpi.Apply(p, func(c *asyncpi.Cursor) bool {
+		if err != nil {
+			return false
+		}
+		if inSpawn(c) {
+			if opt.Trace {
+				w.Write([]byte("{ " + traceGo(c.Node(), fmt.Sprintf("_pitrace.Spawn(_pigo, %s)", strconv.Quote(pos.of(c.Node()))))))
+			}
+			fmt.Fprintf(w, "%s.Spawn(func(){ ", runtimeGroup)
+		}
+		switch p := c.Node().(type) {
+		case *asyncpi.NilProcess:
+			w.Write([]byte("/* end */"))
+		case *asyncpi.Par:
+		case *asyncpi.Repeat:
+			guards = append(guards, true)
+			fmt.Fprintf(w, "%s.Replicate(func(_pir *%s.Replica){ ", runtimeGroup, runtimePkg)
+			if opt.Trace {
+				w.Write([]byte(traceGo(p.Proc, fmt.Sprintf("_pitrace.Replicate(_pigo, %s)", strconv.Quote(pos.of(p.Proc))))))
+			}
+		case *asyncpi.Restrict:
+			var t string
+			if t, err = runtimeType(p.Name); err != nil {
+				return false
+			}
+			if t == runtimePkg+".Value" {
+				fmt.Fprintf(w, "var %s %s;", ident(p, 0), t)
+			} else {
+				fmt.Fprintf(w, "%s := %s.New(%s);", ident(p, 0), runtimeGroup, strconv.Quote(p.Name.Ident()))
+			}
+			decls(p, 1)
+		case *asyncpi.Recv:
+			recv := fmt.Sprintf("%s.Recv()", ident(p, 0))
+			if guard() {
+				recv = fmt.Sprintf("_pir.Recv(%s)", ident(p, 0))
+			}
+			vals := recv
+			if len(p.Vars) > 1 {
+				vals = ids.Temp()
+				fmt.Fprintf(w, "%s := %s;", vals, recv)
+			}
+			if len(p.Vars) == 0 {
+				fmt.Fprintf(w, "%s;", recv)
+			}
+			for i := range p.Vars {
+				if i != 0 {
+					w.Write([]byte(","))
+				}
+				w.Write([]byte(ident(p, i+1)))
+			}
+			for i, v := range p.Vars {
+				if i == 0 {
+					w.Write([]byte(":="))
+				} else {
+					w.Write([]byte(","))
+				}
+				var t string
+				if t, err = runtimeType(v); err != nil {
+					return false
+				}
+				if t == runtimePkg+".Value" {
+					fmt.Fprintf(w, "%s[%d]", vals, i)
+				} else {
+					fmt.Fprintf(w, "%s[%d].(%s)", vals, i, t)
+				}
+			}
+			if len(p.Vars) > 0 {
+				w.Write([]byte(";"))
+			}
+			decls(p, len(p.Vars)+1)
+			if opt.Trace {
+				w.Write([]byte(traceOp("Received", p, p.Chan, p.Vars, pos)))
+			}
+			guards = append(guards, false)
+		case *asyncpi.Send:
+			var buf bytes.Buffer
+			if guard() {
+				fmt.Fprintf(&buf, "_pir.Send(%s", ident(p, 0))
+			} else {
+				fmt.Fprintf(&buf, "%s.Send(", ident(p, 0))
+			}
+			for i := range p.Vals {
+				if i != 0 || guard() {
+					buf.WriteString(", ")
+				}
+				buf.WriteString(ident(p, i+1))
+			}
+			buf.WriteString(");")
+			if opt.Trace {
+				w.Write([]byte(traceOp("Sent", p, p.Chan, p.Vals, pos)))
+			}
+			w.Write(buf.Bytes())
+		default:
+			err = asyncpi.UnknownProcessError{Proc: p}
+			return false
+		}
+		return true
+	}, func(c *asyncpi.Cursor) bool {
+		switch c.Node().(type) {
+		case *asyncpi.Recv:
+			guards = guards[:len(guards)-1]
+		case *asyncpi.Repeat:
+			guards = guards[:len(guards)-1]
+			w.Write([]byte(" });"))
+		}
+		if inSpawn(c) {
+			w.Write([]byte(" })"))
+			if opt.Trace {
+				w.Write([]byte(" }"))
+			}
+			w.Write([]byte("\n"))
+		}
+		return true
+	})
+	return err
+}
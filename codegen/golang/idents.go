@@ -0,0 +1,204 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+	"unicode"
+
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/resolve"
+)
+
+// reservedPrefix is the prefix of the identifiers of the generated code,
+// such as temporaries, which names are never mangled into.
+const reservedPrefix = "_pi"
+
+// predeclared are the predeclared identifiers of Go, which names must
+// not shadow as the generated code may use them.
+var predeclared = map[string]bool{
+	"any": true, "bool": true, "byte": true, "comparable": true,
+	"complex64": true, "complex128": true, "error": true,
+	"float32": true, "float64": true, "int": true, "int8": true,
+	"int16": true, "int32": true, "int64": true, "rune": true,
+	"string": true, "uint": true, "uint8": true, "uint16": true,
+	"uint32": true, "uint64": true, "uintptr": true,
+	"true": true, "false": true, "iota": true, "nil": true,
+	"append": true, "cap": true, "clear": true, "close": true,
+	"complex": true, "copy": true, "delete": true, "imag": true,
+	"len": true, "make": true, "max": true, "min": true, "new": true,
+	"panic": true, "print": true, "println": true, "real": true,
+	"recover": true,
+}
+
+// identNamer names the Objects of a resolved Process as Go identifiers.
+//
+// The name of an Object is kept if it is a Go identifier which is not
+// a keyword or a predeclared identifier, and no other Object has the
+// same name. Otherwise the name is mangled to a fresh identifier, e.g.
+// type is written as type_, a-b as a_b, and a second x as x_1, so
+// every Object has a distinct identifier. Free names are named first,
+// so a bound name is renamed if it clashes with a free name.
+type identNamer struct {
+	idents map[*resolve.Object]string
+	used   map[string]bool // Identifiers in use.
+	decls  declIdents
+	temps  int
+}
+
+// declIdents are the π identifiers of the names declared in a Process
+// by the positions of their declarations. The copies of a name made by
+// types.Monomorphise keep its position, e.g. fwd_1 and fwd_2 are
+// declared at the position of fwd.
+type declIdents map[asyncpi.Pos]string
+
+// declIdentsOf returns the π identifiers of the names declared in
+// Process p, which must not be modified by types.Monomorphise yet.
+func declIdentsOf(p asyncpi.Process) declIdents {
+	decls := make(declIdents)
+	declare := func(n asyncpi.Name) {
+		if pos := asyncpi.NamePos(n); pos.IsValid() {
+			decls[pos] = n.Ident()
+		}
+	}
+	asyncpi.Inspect(p, func(p asyncpi.Process) bool {
+		switch p := p.(type) {
+		case *asyncpi.Restrict:
+			declare(p.Name)
+		case *asyncpi.Recv:
+			for _, v := range p.Vars {
+				declare(v)
+			}
+		}
+		return true
+	})
+	return decls
+}
+
+// newIdentNamer returns an identNamer of the Objects in info, where
+// decls are the π identifiers of the names declared in the source,
+// and the identifiers reserved are not used.
+func newIdentNamer(info *resolve.Info, decls declIdents, reserved ...string) *identNamer {
+	n := &identNamer{idents: make(map[*resolve.Object]string), used: make(map[string]bool), decls: decls}
+	for _, ident := range reserved {
+		n.used[ident] = true
+	}
+	// Free names are named before bound names, so the identifiers of
+	// the free names, i.e. the parameters of a generated function, do
+	// not depend on the names bound in the Process.
+	var free, bound []*resolve.Object
+	seen := make(map[*resolve.Object]bool)
+	for _, o := range info.Occurrences() {
+		if obj := info.ObjectOf(o); obj != nil && !seen[obj] {
+			seen[obj] = true
+			if obj.IsFree() {
+				free = append(free, obj)
+			} else {
+				bound = append(bound, obj)
+			}
+		}
+	}
+	n.name(free)
+	n.name(bound)
+	return n
+}
+
+// name gives each of objs a Go identifier not in use. Names which can
+// be kept are taken first, so they are not taken by mangled names.
+func (n *identNamer) name(objs []*resolve.Object) {
+	for _, obj := range objs {
+		if isSafeIdent(obj.Ident) && !n.used[obj.Ident] {
+			n.idents[obj] = obj.Ident
+			n.used[obj.Ident] = true
+		}
+	}
+	for _, obj := range objs {
+		if _, named := n.idents[obj]; named {
+			continue
+		}
+		base := mangle(obj.Ident)
+		ident := base
+		for i := 1; n.used[ident]; i++ {
+			ident = fmt.Sprintf("%s_%d", base, i)
+		}
+		n.idents[obj] = ident
+		n.used[ident] = true
+	}
+}
+
+// Ident returns the Go identifier of Object obj.
+func (n *identNamer) Ident(obj *resolve.Object) string {
+	if ident, ok := n.idents[obj]; ok {
+		return ident
+	}
+	return obj.Ident
+}
+
+// Comment returns a comment with the π name of Object obj if its Go
+// identifier is not the same, or an empty string otherwise.
+func (n *identNamer) Comment(obj *resolve.Object) string {
+	ident := obj.Ident
+	if decl := obj.Name(); decl != nil {
+		if src, ok := n.decls[asyncpi.NamePos(decl)]; ok {
+			ident = src
+		}
+	}
+	if n.Ident(obj) == ident {
+		return ""
+	}
+	return fmt.Sprintf(" /* π name %s */", ident)
+}
+
+// Temp returns a fresh temporary identifier.
+func (n *identNamer) Temp() string {
+	n.temps++
+	return fmt.Sprintf("%stmp%d", reservedPrefix, n.temps-1)
+}
+
+// isSafeIdent returns true if ident can be used as a Go identifier
+// of a name.
+func isSafeIdent(ident string) bool {
+	return token.IsIdentifier(ident) && ident != "_" && !predeclared[ident] &&
+		!strings.HasPrefix(ident, reservedPrefix)
+}
+
+// mangle returns a Go identifier for the name ident, where each rune
+// not allowed in an identifier is replaced by _.
+func mangle(ident string) string {
+	var b strings.Builder
+	for i, r := range ident {
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+			b.WriteRune(r)
+		case unicode.IsDigit(r):
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	s := b.String()
+	if strings.HasPrefix(s, reservedPrefix) {
+		s = "v" + s
+	}
+	if !isSafeIdent(s) {
+		s += "_"
+	}
+	return s
+}
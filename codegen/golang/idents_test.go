@@ -0,0 +1,93 @@
+package golang
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.nickng.io/asyncpi"
+)
+
+func TestMangle(t *testing.T) {
+	tests := []struct {
+		Ident    string
+		Expected string
+	}{
+		{"a-b", "a_b"},
+		{"type", "type_"},
+		{"make", "make_"},
+		{"1a", "_1a"},
+		{"_", "__"},
+		{"_pitmp0", "v_pitmp0"},
+	}
+	for _, test := range tests {
+		if got := mangle(test.Ident); got != test.Expected {
+			t.Errorf("expects %s to be mangled as %s but got %s", test.Ident, test.Expected, got)
+		}
+	}
+}
+
+func TestGenerateIdents(t *testing.T) {
+	tests := []struct {
+		Proc     string
+		Expected []string // Expected substrings of the code.
+	}{
+		{"(new type)(type<> | type().0)", []string{"type_ := make(T0, 1); /* π name type */"}},
+		{"(new a-b,a_b)(a-b<a_b> | a-b(x).x<> | a_b().0)", []string{"a_b_1 := make(T1, 1); /* π name a-b */"}},
+		// Each polyadic receive has its own temporary.
+		{"(new a,b)(a<b,b> | a(x,y).b<x,y> | b(x,y).0)", []string{"_pitmp0 := <-a;", "_pitmp1 := <-b;x_1,y_1:=_pitmp1.x,_pitmp1.y; /* π name x */ /* π name y */"}},
+		{"(new a,b)(a<b,b> | a(x,x).0)", []string{"x,x_1:=_pitmp0.e0,_pitmp0.e1; /* π name x */"}},
+		// Copies of a polymorphic server are renamed.
+		{"(new fwd)(new a,b,c,d)(!fwd(x,y).y<x> | fwd<a,b> | fwd<c,d> | b(z).z<> | a().0 | d(w).w(v).0 | c<c>)",
+			[]string{"fwd_1 := make(T3); /* π name fwd */", "fwd_2 := make(T6); /* π name fwd */"}},
+	}
+	for _, test := range tests {
+		p, err := asyncpi.Parse(strings.NewReader(test.Proc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := Generate(p, &b); err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range test.Expected {
+			if !strings.Contains(b.String(), s) {
+				t.Errorf("expects code of %s to contain %s but got %s", test.Proc, s, b.String())
+			}
+		}
+		if stderr, exit := runProgram(t, test.Proc, FormatOptions{}); exit != 0 {
+			t.Errorf("expects %s to exit 0 but got exit %d with %q", test.Proc, exit, stderr)
+		}
+	}
+}
+
+// Free names are the parameters of a function, so they are not renamed
+// by the names bound in the Process, and renamed names are commented.
+func TestGenerateFuncIdents(t *testing.T) {
+	tests := []struct {
+		Proc     string
+		Expected []string // Expected substrings of the code.
+	}{
+		{"(new go_)(go<go_> | go_().0)", []string{
+			"func name(go_ chan<- T0 /* π name go */)",
+			"go__1 := make(T0) /* π name go_ */",
+		}},
+		{"(new x)(x<> | x().0) | (new x)x<>", []string{"x_1 := make(T0) /* π name x */"}},
+		{"(new a)(a<b> | a(b).b<>)", []string{"func name(b T0)", "b_1 := <-a /* π name b */"}},
+	}
+	for _, test := range tests {
+		p, err := asyncpi.Parse(strings.NewReader(test.Proc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := GenerateFunc("name", p, FormatOptions{Format: true}, &b); err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range test.Expected {
+			if !strings.Contains(b.String(), s) {
+				t.Errorf("expects code of %s to contain %s but got %s", test.Proc, s, b.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.nickng.io/asyncpi"
+)
+
+// Tracing.
+// This file contains the instrumentation of generated code with the
+// trace package, imported as _pitrace, e.g. a<x> | a(y).0 is written as
+//
+//     go func(a chan<- T0, x T1, _pigo int64) {
+//         _pitrace.Sent(_pigo, "a", "1:1", "x"); a <- x
+//     }(a, x, _pitrace.Spawn(_pigo, "1:1"))
+//     y := <-a; _pitrace.Received(_pigo, "a", "1:8", "y")
+//
+// where _pigo is the number of the current goroutine. An output is
+// traced before the send and an input after the receive, so a message
+// is always traced as sent before it is traced as received.
+
+// traceImport is the import of the trace package.
+const traceImport = `_pitrace "go.nickng.io/asyncpi/trace"`
+
+// withTraceImport returns the program header with the trace package
+// imported.
+func withTraceImport(header string) string {
+	return strings.Replace(header, "import (\n", "import (\n\t"+traceImport+"\n", 1)
+}
+
+// positions are the source positions of the inputs and outputs of a
+// Process, i.e. the positions of their channels.
+type positions map[asyncpi.Process]asyncpi.Pos
+
+// opPositions returns the positions of the inputs and outputs of Process
+// p. The positions are taken before types.Monomorphise, which replaces
+// the channels of the uses of a polymorphic server with copies of its
+// name (see copyPositions).
+func opPositions(p asyncpi.Process) positions {
+	pos := make(positions)
+	asyncpi.Inspect(p, func(p asyncpi.Process) bool {
+		switch p := p.(type) {
+		case *asyncpi.Recv:
+			pos[p] = asyncpi.NamePos(p.Chan)
+		case *asyncpi.Send:
+			pos[p] = asyncpi.NamePos(p.Chan)
+		}
+		return true
+	})
+	return pos
+}
+
+// copyPositions returns the positions of the inputs and outputs of the
+// Process q in the positions pos of the Process p, where q is the result
+// of types.Monomorphise of p, i.e. a copy of p where servers may be
+// replaced by a parallel composition of their copies and restrictions of
+// the copies may be added.
+func copyPositions(pos positions, p, q asyncpi.Process) positions {
+	qpos := make(positions)
+	var match func(p, q asyncpi.Process)
+	match = func(p, q asyncpi.Process) {
+		switch q := q.(type) {
+		case *asyncpi.Par:
+			if p, ok := p.(*asyncpi.Par); ok && len(p.Procs) == len(q.Procs) {
+				for i := range q.Procs {
+					match(p.Procs[i], q.Procs[i])
+				}
+				return
+			}
+			for _, copy := range q.Procs {
+				match(p, copy)
+			}
+		case *asyncpi.Restrict:
+			if p, ok := p.(*asyncpi.Restrict); ok {
+				match(p.Proc, q.Proc)
+				return
+			}
+			match(p, q.Proc)
+		case *asyncpi.Repeat:
+			if p, ok := p.(*asyncpi.Repeat); ok {
+				match(p.Proc, q.Proc)
+			}
+		case *asyncpi.Recv:
+			if p, ok := p.(*asyncpi.Recv); ok {
+				qpos[q] = pos[p]
+				match(p.Cont, q.Cont)
+			}
+		case *asyncpi.Send:
+			if _, ok := p.(*asyncpi.Send); ok {
+				qpos[q] = pos[p]
+			}
+		}
+	}
+	match(p, q)
+	return qpos
+}
+
+// of returns the position of Process p, i.e. of its first input or
+// output, or an empty string if it is not known.
+func (pos positions) of(p asyncpi.Process) string {
+	var at asyncpi.Pos
+	asyncpi.Inspect(p, func(p asyncpi.Process) bool {
+		if at.IsValid() {
+			return false
+		}
+		at = pos[p]
+		return true
+	})
+	if !at.IsValid() {
+		return ""
+	}
+	return at.String()
+}
+
+// traceOp returns the statement emitting the event of the input or output
+// p with the function fn of the trace package.
+func traceOp(fn string, p asyncpi.Process, ch asyncpi.Name, payload []asyncpi.Name, pos positions) string {
+	args := []string{"_pigo", strconv.Quote(ch.Ident()), strconv.Quote(pos.of(p))}
+	for _, n := range payload {
+		args = append(args, strconv.Quote(n.Ident()))
+	}
+	return fmt.Sprintf(" _pitrace.%s(%s);", fn, strings.Join(args, ", "))
+}
+
+// traceGo returns the declaration of the number of the goroutine of the
+// Process p, where goroutine is the expression of the number, which is
+// assigned to _ if p does not trace any event.
+func traceGo(p asyncpi.Process, goroutine string) string {
+	traced := false
+	asyncpi.Inspect(p, func(p asyncpi.Process) bool {
+		switch p := p.(type) {
+		case *asyncpi.Recv, *asyncpi.Send, *asyncpi.Repeat:
+			traced = true
+		case *asyncpi.Par:
+			traced = traced || len(p.Procs) > 1
+		}
+		return !traced
+	})
+	if traced {
+		return fmt.Sprintf("_pigo := %s; ", goroutine)
+	}
+	return fmt.Sprintf("_pigo := %s; _ = _pigo; ", goroutine)
+}
@@ -0,0 +1,134 @@
+package golang
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/trace"
+)
+
+// traceEvents returns the inputs and outputs traced in stderr as strings
+// sorted, and the number of each kind of event.
+func traceEvents(t *testing.T, stderr string) ([]string, map[trace.Kind]int) {
+	t.Helper()
+	var ops []string
+	kinds := make(map[trace.Kind]int)
+	for _, line := range strings.Split(stderr, "\n") {
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var e trace.Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("cannot decode event %s: %v", line, err)
+		}
+		kinds[e.Kind]++
+		if e.Kind == trace.KindSend || e.Kind == trace.KindRecv {
+			ops = append(ops, string(e.Kind)+" "+e.Chan+"<"+strings.Join(e.Payload, ",")+"> "+e.Pos)
+		}
+	}
+	sort.Strings(ops)
+	return ops, kinds
+}
+
+func TestGenerateTrace(t *testing.T) {
+	tests := []struct {
+		Proc       string
+		Ops        []string
+		Replicates int
+	}{
+		{
+			"(new a,b)(a<b> | a(x).x<>\n | b().0)",
+			[]string{"recv a<x> 1:18", "recv b<> 2:4", "send a<b> 1:11", "send x<> 1:23"},
+			0,
+		},
+		{
+			// Each replica is created when the previous one is needed.
+			"(new a)(!a().0 |\n a<> | a<>)",
+			[]string{"recv a<> 1:10", "recv a<> 1:10", "send a<> 2:2", "send a<> 2:8"},
+			3,
+		},
+	}
+	for _, opt := range []FormatOptions{{Trace: true}, {Trace: true, AsyncSend: true}, {Trace: true, Runtime: true}} {
+		for _, test := range tests {
+			stderr, exit := runProgram(t, test.Proc, opt)
+			if exit != 0 {
+				t.Errorf("expects %s to exit 0 but got exit %d with %q", test.Proc, exit, stderr)
+				continue
+			}
+			ops, kinds := traceEvents(t, stderr)
+			if strings.Join(ops, "; ") != strings.Join(test.Ops, "; ") {
+				t.Errorf("expects %s to trace %v but got %v", test.Proc, test.Ops, ops)
+			}
+			if kinds[trace.KindReplicate] != test.Replicates {
+				t.Errorf("expects %s to trace %d replicas but got %d", test.Proc, test.Replicates, kinds[trace.KindReplicate])
+			}
+			if kinds[trace.KindSpawn] == 0 {
+				t.Errorf("expects %s to trace spawns but got none", test.Proc)
+			}
+		}
+	}
+}
+
+// Positions of copies of a polymorphic server are the positions of the
+// server, as types.Monomorphise copies the bound Process.
+func TestTracePositions(t *testing.T) {
+	p, err := asyncpi.Parse(strings.NewReader("(new f)(!f(x,y).y<x> | (new a:int,b:string,r1,r2)(f<a,r1> |\n f<b,r2>))"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _, pos, err := prepare(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	asyncpi.Inspect(p, func(p asyncpi.Process) bool {
+		switch p := p.(type) {
+		case *asyncpi.Recv:
+			got = append(got, p.Chan.Ident()+"() "+pos.of(p))
+		case *asyncpi.Send:
+			got = append(got, p.Chan.Ident()+"<> "+pos.of(p))
+		}
+		return true
+	})
+	sort.Strings(got)
+	want := []string{"f_1() 1:10", "f_1<> 1:51", "f_2() 1:10", "f_2<> 2:2", "y<> 1:17", "y<> 1:17"}
+	if strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("expects positions %v but got %v", want, got)
+	}
+}
+
+// A message is traced as sent before it is traced as received.
+func TestTraceOrder(t *testing.T) {
+	// The outputs on a are likely to wait for the receiver, which
+	// waits for b first.
+	const n = 20
+	proc := "(new a,b)(b<> | " + strings.Repeat("a<> | ", n) + "b()." + strings.Repeat("a().", n) + "0)"
+	for _, opt := range []FormatOptions{{Trace: true}, {Trace: true, AsyncSend: true}, {Trace: true, Runtime: true}} {
+		stderr, exit := runProgram(t, proc, opt)
+		if exit != 0 {
+			t.Errorf("expects %s to exit 0 but got exit %d with %q", proc, exit, stderr)
+			continue
+		}
+		pending := 0 // Messages traced as sent but not received.
+		for _, line := range strings.Split(stderr, "\n") {
+			var e trace.Event
+			if err := json.Unmarshal([]byte(line), &e); err != nil {
+				continue
+			}
+			switch e.Kind {
+			case trace.KindSend:
+				pending++
+			case trace.KindRecv:
+				if pending--; pending < 0 {
+					t.Fatalf("expects sends to be traced before receives but got\n%s", stderr)
+				}
+			}
+		}
+		if pending != 0 {
+			t.Errorf("expects %d messages received but got %d pending", n, pending)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"go.nickng.io/asyncpi/types"
+)
+
+// RecursiveTypeError is the type of error when a recursive type
+// cannot be declared as a Go type.
+type RecursiveTypeError struct {
+	T types.Type
+}
+
+func (e RecursiveTypeError) Error() string {
+	return fmt.Sprintf("cannot declare recursive type %s", e.T)
+}
+
+// typeNamer writes types as Go types.
+//
+// A named type is declared for each composite type, e.g. chan<int,bool>
+// is written as T1 with the declarations
+//
+//     type T0 struct{x int; y bool}
+//     type T1 = chan T0
+//
+// where the fields are named by nameFields, or e0, e1.. otherwise.
+// Channel types are declared as aliases, so channels of the same type
+// are assignable whichever capability they are written with, and
+// channels made by make or received as values have the same type.
+//
+// Go has no type literals for recursive types, and an alias cannot
+// refer to itself, so a defined type is declared for each recursive
+// channel type instead, e.g. μt0.chan t0 is written as T0 with the
+// declaration
+//
+//     type T0 chan T0
+//
+// A declaration only refers to itself and the named types declared
+// before it, as local type declarations are only in scope after they
+// are declared, so the other types in a cycle are written as type
+// literals, e.g. μt0.chan<t0,int> is written as T1 with the declarations
+//
+//     type T0 struct{e0 chan T0; e1 int}
+//     type T1 chan T0
+//
+// Equal types share the same named type.
+type typeNamer struct {
+	decls  bytes.Buffer // Type declarations, in dependency order.
+	named  []*namedType
+	fields []namedFields
+	idents map[string]bool // Idents in use, which names must not clash with.
+}
+
+// namedFields are the field names of a composite type.
+type namedFields struct {
+	t     types.Type
+	names []string
+}
+
+type namedType struct {
+	t        types.Type
+	name     string
+	declared bool
+}
+
+func newTypeNamer(idents map[string]bool) *typeNamer {
+	return &typeNamer{idents: idents}
+}
+
+// Decls returns the type declarations of the types written so far.
+func (n *typeNamer) Decls() []byte {
+	return n.decls.Bytes()
+}
+
+// nameFields names the fields of composite type t by names,
+// unless the fields of t are already named.
+func (n *typeNamer) nameFields(t types.Type, names []string) {
+	if n.fieldNames(t) == nil {
+		n.fields = append(n.fields, namedFields{t: underlying(t), names: names})
+	}
+}
+
+// fieldNames returns the field names of composite type t,
+// or nil if the fields are not named.
+func (n *typeNamer) fieldNames(t types.Type) []string {
+	t = underlying(t)
+	for _, f := range n.fields {
+		if f.t == t || types.IsEqual(f.t, t) {
+			return f.names
+		}
+	}
+	return nil
+}
+
+// FieldName returns the name of the i-th field of composite type t.
+func (n *typeNamer) FieldName(t types.Type, i int) string {
+	if names := n.fieldNames(t); i < len(names) {
+		return names[i]
+	}
+	return fmt.Sprintf("e%d", i)
+}
+
+// TypeString returns the Go type of type t.
+func (n *typeNamer) TypeString(t types.Type) (string, error) {
+	return n.typeString(t, nil, nil)
+}
+
+// ChanString returns the Go type of a channel with capability dir
+// and element type elem.
+func (n *typeNamer) ChanString(dir types.ChanDir, elem types.Type) (string, error) {
+	if dir == types.SendRecv {
+		return n.TypeString(types.NewChanDir(dir, elem))
+	}
+	s, err := n.TypeString(elem)
+	if err != nil {
+		return "", err
+	}
+	return chanString(dir, s), nil
+}
+
+// chanString returns the Go channel type with capability dir and
+// element type elem, where a receive-only element is parenthesised
+// as chan <-chan T is chan<- (chan T).
+func chanString(dir types.ChanDir, elem string) string {
+	if strings.HasPrefix(elem, "<-") {
+		return fmt.Sprintf("%s (%s)", dir, elem)
+	}
+	return fmt.Sprintf("%s %s", dir, elem)
+}
+
+func (n *typeNamer) lookup(t types.Type) *namedType {
+	for _, nt := range n.named {
+		if nt.t == t || types.IsEqual(nt.t, t) {
+			return nt
+		}
+	}
+	return nil
+}
+
+// typeString returns the Go type of type t in the declaration of the
+// named type current (nil outside of declarations). Only the types
+// already declared and current itself can be referred to by name, as
+// local type declarations are only in scope after they are declared.
+func (n *typeNamer) typeString(t types.Type, current *namedType, visiting []types.Type) (string, error) {
+	t = underlying(t)
+	nt := n.lookup(t)
+	if nt != nil && (nt.declared || nt == current) {
+		if nt.name == "" {
+			nt.name = n.fresh()
+		}
+		return nt.name, nil
+	}
+	for _, v := range visiting {
+		if v == t {
+			return "", RecursiveTypeError{T: t}
+		}
+	}
+	if nt == nil && isNamed(t) {
+		return n.declare(t)
+	}
+	return n.unfold(t, current, append(visiting, t))
+}
+
+// unfold returns the Go type literal of type t.
+func (n *typeNamer) unfold(t types.Type, current *namedType, visiting []types.Type) (string, error) {
+	switch t := t.(type) {
+	case *types.Chan:
+		elem, err := n.typeString(t.Elem(), current, visiting)
+		if err != nil {
+			return "", err
+		}
+		return chanString(t.Dir(), elem), nil
+	case *types.Composite:
+		var buf bytes.Buffer
+		buf.WriteString("struct{")
+		for i, e := range t.Elems() {
+			if i != 0 {
+				buf.WriteRune(';')
+			}
+			elem, err := n.typeString(e, current, visiting)
+			if err != nil {
+				return "", err
+			}
+			buf.WriteString(fmt.Sprintf("%s %s", n.FieldName(t, i), elem))
+		}
+		buf.WriteString("}")
+		return buf.String(), nil
+	}
+	return t.String(), nil
+}
+
+// isNamed returns true if a named type is declared for type t.
+func isNamed(t types.Type) bool {
+	switch t := t.(type) {
+	case *types.Chan:
+		return true
+	case *types.Composite:
+		return len(t.Elems()) > 0
+	}
+	return false
+}
+
+// isRecursive returns true if type t contains a type equal to itself,
+// e.g. chan μt0.chan t0 which is equal to μt0.chan t0, so both are
+// written as the same named type.
+func isRecursive(t types.Type) bool {
+	seen := make(map[types.Type]bool)
+	work := []types.Type{t}
+	for len(work) > 0 {
+		var elems []types.Type
+		switch u := underlying(work[0]).(type) {
+		case *types.Chan:
+			elems = []types.Type{u.Elem()}
+		case *types.Composite:
+			elems = u.Elems()
+		}
+		work = work[1:]
+		for _, e := range elems {
+			e = underlying(e)
+			if seen[e] {
+				continue
+			}
+			if e == t || types.IsEqual(e, t) {
+				return true
+			}
+			seen[e] = true
+			work = append(work, e)
+		}
+	}
+	return false
+}
+
+// declare declares a named type for type t, and returns the name.
+// A channel type is declared as an alias unless it is recursive.
+//
+// The type is named when it refers to itself or after its declaration,
+// so the named types are numbered in declaration order.
+func (n *typeNamer) declare(t types.Type) (string, error) {
+	nt := &namedType{t: t}
+	n.named = append(n.named, nt)
+	lit, err := n.unfold(t, nt, []types.Type{t})
+	if err != nil {
+		return "", err
+	}
+	if nt.name == "" {
+		nt.name = n.fresh()
+	}
+	if _, isChan := t.(*types.Chan); isChan && !isRecursive(t) {
+		fmt.Fprintf(&n.decls, "type %s = %s; ", nt.name, lit)
+	} else {
+		fmt.Fprintf(&n.decls, "type %s %s; ", nt.name, lit)
+	}
+	nt.declared = true
+	return nt.name, nil
+}
+
+// fresh returns a name for a new named type.
+func (n *typeNamer) fresh() string {
+	for i := 0; ; i++ {
+		name := fmt.Sprintf("T%d", i)
+		if !n.idents[name] {
+			n.idents[name] = true
+			return name
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package golang
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.nickng.io/asyncpi"
+)
+
+func TestGenerateNamedTypes(t *testing.T) {
+	tests := []struct {
+		Proc     string
+		Expected []string // Expected substrings of the code.
+	}{
+		// Fields are named by the first input on the channel.
+		{"(new a,b,c)(a<b,c> | a(x,y).x<y> | a<c,b> | a(u,v).0 | b(z).0)", []string{
+			"type T0 chan T0; type T1 struct{x T0;y T0}; type T2 = chan T1; a := make(T2);",
+			"a <- T1{b,c}", "a <- T1{c,b}", "u,v:=_pitmp1.x,_pitmp1.y;",
+		}},
+		// Fields are not named without an input.
+		{"(new a,b)(a<b,b> | b(z).0)", []string{"type T0 = chan interface{}; type T1 struct{e0 T0;e1 T0}; type T2 = chan T1; a := make(T2);"}},
+		{"(new a)(a<a,b> | a(x,y).x<x,y>)", []string{"type T0 struct{x chan T0;y interface{}}; type T1 chan T0;"}},
+	}
+	for _, test := range tests {
+		p, err := asyncpi.Parse(strings.NewReader(test.Proc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := Generate(p, &b); err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range test.Expected {
+			if !strings.Contains(b.String(), s) {
+				t.Errorf("expects code of %s to contain %s but got %s", test.Proc, s, b.String())
+			}
+		}
+		runProgram(t, test.Proc, FormatOptions{}) // Fails if the program does not build.
+	}
+}
@@ -0,0 +1,691 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asyncpi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.nickng.io/asyncpi/internal/errors"
+	"go.nickng.io/asyncpi/internal/name"
+)
+
+// Structural congruence.
+// This file contains the normalisation of processes by structural congruence.
+//
+// The normal form is computed in two passes:
+//
+// The first pass (scoper) flattens nested Par, removes 0, lifts all
+// Restrict of a parallel composition to the top, and then pushes them
+// back down to the narrowest group of components that use them.
+// Unused Restrict are dropped.
+//
+// The second pass (canonicaliser) sorts the parallel components and
+// names the bound names by their binding depth, such that processes
+// which only differ by the order of components, the order of restrictions
+// or the choice of bound names have the same canonical form.
+//
+// The restricted names of a scope are named canonically by partition
+// refinement: the names are coloured by how the components use them
+// until the colouring is stable. Names which are still indistinguishable,
+// e.g. x and y in (νx,y)(x<y> | y<x>), are given a colour of their own in
+// turn (see refiner), so the search is only exponential in the symmetries
+// of a scope, e.g. the number of components which are equal up to the
+// naming of the restricted names.
+
+// Congruent returns true if Process p and q are structurally congruent,
+// i.e. they are equal up to alpha-conversion and the rules
+//
+//     P|0 ≡ P    P|Q ≡ Q|P    (P|Q)|R ≡ P|(Q|R)
+//     (νx)0 ≡ 0    (νx)(νy)P ≡ (νy)(νx)P    !0 ≡ 0
+//     (νx)P|Q ≡ (νx)(P|Q) where x ∉ fn(Q)
+//
+// The unfolding of replication (!P ≡ P|!P) is not considered.
+//
+// Congruent returns an error if the canonical form of p or q cannot be
+// computed (see Canonical).
+func Congruent(p, q Process) (bool, error) {
+	cp, err := Canonical(p)
+	if err != nil {
+		return false, err
+	}
+	cq, err := Canonical(q)
+	if err != nil {
+		return false, err
+	}
+	return cp.Calculi() == cq.Calculi(), nil
+}
+
+// Canonical returns the canonical form of Process p by structural congruence.
+//
+// Two processes are congruent if and only if they have the same
+// canonical form (see Congruent).
+// Bound names in the canonical form are renamed by their binding depth.
+// The input Process p is not modified.
+func Canonical(p Process) (Process, error) {
+	n, err := normalise(p, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot compute canonical form")
+	}
+	return n.canon, nil
+}
+
+// normalise returns the normal form of Process p. The canonical form is
+// only computed if exhaustive is set, otherwise the components are sorted
+// without naming the restricted names, which is linear in the size of p.
+func normalise(p Process, exhaustive bool) (normal, error) {
+	used := make(map[string]bool)
+	collectIdents(p, used)
+	fn := freeIdents(p)
+	s := &scoper{fresh: &freshNamer{used: used}, taken: make(map[string]bool), orig: make(map[Name]Name)}
+	for ident := range fn {
+		s.taken[ident] = true
+	}
+	scoped, err := s.scope(p, map[string]Name{}, map[string]bool{})
+	if err != nil {
+		return normal{}, err
+	}
+	c := &canonicaliser{prefix: canonPrefix(fn), orig: s.orig, exhaustive: exhaustive}
+	return c.canonicalise(scoped, 0, map[string]binding{})
+}
+
+// canonPrefix returns a prefix for canonical names that
+// does not clash with the free names fn.
+func canonPrefix(fn map[string]bool) string {
+	prefix := "_"
+	for {
+		clash := false
+		for ident := range fn {
+			if strings.HasPrefix(ident, prefix) && strings.Trim(ident[len(prefix):], "0123456789") == "" {
+				clash = true
+				break
+			}
+		}
+		if !clash {
+			return prefix
+		}
+		prefix += "_"
+	}
+}
+
+// scoper is the first pass of normalisation.
+type scoper struct {
+	fresh *freshNamer
+	taken map[string]bool // Free names and Restrict names used so far.
+	orig  map[Name]Name   // Original Names of renamed Restrict names.
+}
+
+// scope returns a copy of p with restrictions moved to their narrowest
+// scope. The names of Restrict are renamed if they are not unique.
+//
+// env is the renaming of bound names in scope, and enclosing
+// is the set of binders enclosing p.
+func (s *scoper) scope(p Process, env map[string]Name, enclosing map[string]bool) (Process, error) {
+	switch p := p.(type) {
+	case *NilProcess:
+		return NewNilProcess(), nil
+	case *Send:
+		send := NewSend(substName(p.Chan, env))
+		vals := make([]Name, len(p.Vals))
+		for i := range p.Vals {
+			vals[i] = substName(p.Vals[i], env)
+		}
+		send.SetVals(vals)
+		return send, nil
+	case *Recv:
+		inner := make(map[string]Name, len(env))
+		for x, v := range env {
+			inner[x] = v
+		}
+		innerEnclosing := make(map[string]bool, len(enclosing)+len(p.Vars))
+		for x := range enclosing {
+			innerEnclosing[x] = true
+		}
+		for _, v := range p.Vars {
+			delete(inner, v.Ident())
+			innerEnclosing[v.Ident()] = true
+		}
+		cont, err := s.scope(p.Cont, inner, innerEnclosing)
+		if err != nil {
+			return nil, err
+		}
+		recv := NewRecv(substName(p.Chan, env), cont)
+		recv.SetVars(append([]Name(nil), p.Vars...))
+		return recv, nil
+	case *Repeat:
+		proc, err := s.scope(p.Proc, env, enclosing)
+		if err != nil {
+			return nil, err
+		}
+		if _, isEmpty := proc.(*NilProcess); isEmpty {
+			return proc, nil
+		}
+		return NewRepeat(proc), nil
+	case *Par, *Restrict:
+		var names []Name
+		var comps []Process
+		if err := s.collect(p, env, enclosing, &names, &comps); err != nil {
+			return nil, err
+		}
+		return group(names, comps), nil
+	default:
+		return nil, UnknownProcessError{Proc: p}
+	}
+}
+
+// collect flattens the nested Par and Restrict in p into a list of
+// restricted names and a list of parallel components.
+func (s *scoper) collect(p Process, env map[string]Name, enclosing map[string]bool, names *[]Name, comps *[]Process) error {
+	switch p := p.(type) {
+	case *Par:
+		for _, proc := range p.Procs {
+			if err := s.collect(proc, env, enclosing, names, comps); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *Restrict:
+		n := p.Name
+		if s.taken[n.Ident()] || enclosing[n.Ident()] {
+			n = renameName(n, s.fresh.fresh(n.Ident()))
+			s.orig[n] = p.Name
+		}
+		s.taken[n.Ident()] = true
+		inner := make(map[string]Name, len(env)+1)
+		for x, v := range env {
+			inner[x] = v
+		}
+		inner[p.Name.Ident()] = n
+		*names = append(*names, n)
+		return s.collect(p.Proc, inner, enclosing, names, comps)
+	default:
+		proc, err := s.scope(p, env, enclosing)
+		if err != nil {
+			return err
+		}
+		if _, isEmpty := proc.(*NilProcess); !isEmpty {
+			*comps = append(*comps, proc)
+		}
+		return nil
+	}
+}
+
+// group returns the parallel composition of comps, with each of the
+// restricted names scoped over the smallest group of components using it.
+//
+// The names are unique and none of comps is a Par or a Restrict.
+func group(names []Name, comps []Process) Process {
+	fns := make([]map[string]bool, len(comps))
+	for i := range comps {
+		fns[i] = freeIdents(comps[i])
+	}
+	users := make(map[Name][]int)
+	var used []Name
+	for _, n := range names {
+		for i := range comps {
+			if fns[i][n.Ident()] {
+				users[n] = append(users[n], i)
+			}
+		}
+		if len(users[n]) > 0 {
+			used = append(used, n)
+		}
+	}
+	if len(used) == 0 {
+		return par(comps)
+	}
+
+	// Union-find the components connected by the restricted names.
+	parent := make([]int, len(comps))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for _, n := range used {
+		for _, i := range users[n][1:] {
+			parent[find(i)] = find(users[n][0])
+		}
+	}
+	var roots []int
+	groupComps := make(map[int][]Process)
+	groupNames := make(map[int][]Name)
+	for i := range comps {
+		r := find(i)
+		if _, exists := groupComps[r]; !exists {
+			roots = append(roots, r)
+		}
+		groupComps[r] = append(groupComps[r], comps[i])
+	}
+	for _, n := range used {
+		r := find(users[n][0])
+		groupNames[r] = append(groupNames[r], n)
+	}
+	if len(roots) > 1 {
+		var procs []Process
+		for _, r := range roots {
+			procs = append(procs, group(groupNames[r], groupComps[r]))
+		}
+		return par(procs)
+	}
+
+	// All components are connected, the names used by a single component
+	// are pushed into that component.
+	if len(comps) == 1 {
+		return NewRestricts(used, comps[0])
+	}
+	var shared []Name
+	private := make([][]Name, len(comps))
+	for _, n := range used {
+		if len(users[n]) == 1 {
+			private[users[n][0]] = append(private[users[n][0]], n)
+		} else {
+			shared = append(shared, n)
+		}
+	}
+	procs := make([]Process, len(comps))
+	for i := range comps {
+		procs[i] = comps[i]
+		if len(private[i]) > 0 {
+			procs[i] = NewRestricts(private[i], comps[i])
+		}
+	}
+	return NewRestricts(shared, par(procs))
+}
+
+// par returns the parallel composition of procs.
+func par(procs []Process) Process {
+	switch len(procs) {
+	case 0:
+		return NewNilProcess()
+	case 1:
+		return procs[0]
+	default:
+		return &Par{Procs: procs}
+	}
+}
+
+// normal is a Process in normal form.
+type normal struct {
+	canon Process // Normal form with canonical bound names, if exhaustive.
+	named Process // Normal form with the original bound names.
+	key   string  // Calculi of canon.
+}
+
+// binding is the replacement of a bound name in a normal form.
+type binding struct {
+	canon, named Name
+}
+
+// canonicaliser is the second pass of normalisation.
+type canonicaliser struct {
+	prefix     string
+	orig       map[Name]Name // Original Names of renamed Restrict names.
+	exhaustive bool          // Whether to name the restricted names canonically.
+}
+
+func (c *canonicaliser) level(depth int) Name {
+	return name.New(fmt.Sprintf("%s%d", c.prefix, depth))
+}
+
+func (c *canonicaliser) rename(n Name, env map[string]binding) binding {
+	if b, bound := env[n.Ident()]; bound {
+		return b
+	}
+	return binding{canon: n, named: n}
+}
+
+// canonicalise returns the normal form of p (the output of scoper)
+// where depth is the number of enclosing binders.
+func (c *canonicaliser) canonicalise(p Process, depth int, env map[string]binding) (normal, error) {
+	switch p := p.(type) {
+	case *NilProcess:
+		return newNormal(NewNilProcess(), NewNilProcess()), nil
+	case *Send:
+		ch := c.rename(p.Chan, env)
+		canon, named := NewSend(ch.canon), NewSend(ch.named)
+		for _, v := range p.Vals {
+			b := c.rename(v, env)
+			canon.Vals = append(canon.Vals, b.canon)
+			named.Vals = append(named.Vals, b.named)
+		}
+		return newNormal(canon, named), nil
+	case *Recv:
+		inner := copyEnv(env)
+		var canonVars, namedVars []Name
+		for i, v := range p.Vars {
+			b := binding{canon: c.level(depth + i), named: v}
+			inner[v.Ident()] = b
+			canonVars = append(canonVars, b.canon)
+			namedVars = append(namedVars, b.named)
+		}
+		cont, err := c.canonicalise(p.Cont, depth+len(p.Vars), inner)
+		if err != nil {
+			return normal{}, err
+		}
+		ch := c.rename(p.Chan, env)
+		canon, named := NewRecv(ch.canon, cont.canon), NewRecv(ch.named, cont.named)
+		canon.SetVars(canonVars)
+		named.SetVars(namedVars)
+		return newNormal(canon, named), nil
+	case *Repeat:
+		proc, err := c.canonicalise(p.Proc, depth, env)
+		if err != nil {
+			return normal{}, err
+		}
+		return newNormal(NewRepeat(proc.canon), NewRepeat(proc.named)), nil
+	case *Par, *Restrict:
+		return c.canonicaliseScope(p, depth, env)
+	default:
+		return normal{}, UnknownProcessError{Proc: p}
+	}
+}
+
+// canonicaliseScope returns the normal form of a Restrict and/or Par.
+//
+// The components are ordered by their canonical form. If exhaustive is
+// not set, the restricted names are ordered by their first occurrence in
+// the ordered components, otherwise they are named canonically (see
+// refiner) and the smallest canonical form is chosen.
+func (c *canonicaliser) canonicaliseScope(p Process, depth int, env map[string]binding) (normal, error) {
+	var names []Name
+	for {
+		res, ok := p.(*Restrict)
+		if !ok {
+			break
+		}
+		names = append(names, res.Name)
+		p = res.Proc
+	}
+	comps := []Process{p}
+	if par, ok := p.(*Par); ok {
+		comps = par.Procs
+	}
+
+	// Restore the original names of renamed restricted names
+	// if they are not used in the final scope.
+	named := make([]Name, len(names))
+	idents := make(map[string]bool)
+	for _, comp := range comps {
+		collectIdents(comp, idents)
+	}
+	for i, n := range names {
+		named[i] = n
+		if orig, renamed := c.orig[n]; renamed && !idents[orig.Ident()] {
+			named[i] = orig
+		}
+	}
+
+	// Canonicalise components with placeholder names to order them.
+	placeholders := make(map[Name]int)
+	stars := make([]Name, len(names))
+	starEnv := copyEnv(env)
+	for i, n := range names {
+		stars[i] = name.New("*")
+		placeholders[stars[i]] = i
+		starEnv[n.Ident()] = binding{canon: stars[i], named: named[i]}
+	}
+	starred := make([]normal, len(comps))
+	scoped := make([]bool, len(comps)) // Whether the component uses the names.
+	for i := range comps {
+		var err error
+		if starred[i], err = c.canonicalise(comps[i], depth+len(names), starEnv); err != nil {
+			return normal{}, err
+		}
+		InspectNames(starred[i].canon, func(n Name, _ *Scope) {
+			_, isPlaceholder := placeholders[n]
+			scoped[i] = scoped[i] || isPlaceholder
+		})
+	}
+	order := make([]int, len(comps))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return starred[order[i]].key < starred[order[j]].key })
+
+	// rank returns the ranks of the names by first occurrence in order.
+	rank := func(order []int) []int {
+		ranks := make([]int, len(names))
+		for i := range ranks {
+			ranks[i] = -1
+		}
+		ranked := 0
+		for _, i := range order {
+			InspectNames(starred[i].canon, func(n Name, _ *Scope) {
+				if idx, isPlaceholder := placeholders[n]; isPlaceholder && ranks[idx] < 0 {
+					ranks[idx] = ranked
+					ranked++
+				}
+			})
+		}
+		for i := range ranks {
+			if ranks[i] < 0 { // not used by any component.
+				ranks[i] = ranked
+				ranked++
+			}
+		}
+		return ranks
+	}
+	scope := func(canonNames, namedNames []Name, ns []normal) normal {
+		canonProcs, namedProcs := make([]Process, len(ns)), make([]Process, len(ns))
+		for i := range ns {
+			canonProcs[i], namedProcs[i] = ns[i].canon, ns[i].named
+		}
+		canon, named := par(canonProcs), par(namedProcs)
+		if len(names) > 0 {
+			canon, named = NewRestricts(canonNames, canon), NewRestricts(namedNames, named)
+		}
+		return newNormal(canon, named)
+	}
+
+	if !c.exhaustive || len(names) == 0 {
+		// The starred components are final.
+		ranks := rank(order)
+		canonNames, namedNames := make([]Name, len(names)), make([]Name, len(names))
+		for i := range names {
+			canonNames[ranks[i]], namedNames[ranks[i]] = stars[i], named[i]
+		}
+		ns := make([]normal, len(order))
+		for i, j := range order {
+			ns[i] = starred[j]
+		}
+		return scope(canonNames, namedNames, ns), nil
+	}
+
+	// Name the restricted names by a search over their colourings
+	// (see refine), where each leaf of the search is a canonical naming,
+	// and the smallest canonical form of the leaves is chosen.
+	r := &refiner{c: c, depth: depth, env: env, names: names, named: named, comps: comps, users: make([][]int, len(names))}
+	for i := range comps {
+		if !scoped[i] {
+			continue
+		}
+		InspectNames(starred[i].canon, func(n Name, _ *Scope) {
+			if idx, isPlaceholder := placeholders[n]; isPlaceholder {
+				if users := r.users[idx]; len(users) == 0 || users[len(users)-1] != i {
+					r.users[idx] = append(users, i)
+				}
+			}
+		})
+	}
+	var best normal
+	var bestSet bool
+	err := r.search(make([]int, len(names)), func(ranks []int) error {
+		inner := copyEnv(env)
+		canonNames, namedNames := make([]Name, len(names)), make([]Name, len(names))
+		for i, n := range names {
+			b := binding{canon: c.level(depth + ranks[i]), named: named[i]}
+			inner[n.Ident()] = b
+			canonNames[ranks[i]], namedNames[ranks[i]] = b.canon, b.named
+		}
+		ns := make([]normal, len(comps))
+		for i := range comps {
+			var err error
+			if ns[i], err = c.canonicalise(comps[i], depth+len(names), inner); err != nil {
+				return err
+			}
+		}
+		sort.SliceStable(ns, func(i, j int) bool { return ns[i].key < ns[j].key })
+		n := scope(canonNames, namedNames, ns)
+		if !bestSet || n.key < best.key {
+			best, bestSet = n, true
+		}
+		return nil
+	})
+	if err != nil {
+		return normal{}, err
+	}
+	return best, nil
+}
+
+// refiner searches the canonical namings of the restricted names of a
+// scope by individualisation and refinement, as in graph canonisation.
+//
+// A colouring of the names is refined by the views of each name from
+// the components using it, until the colouring is stable. If a colour
+// is shared by several names, each of them is given a colour of its own
+// in turn, and the search continues with the refined colourings. The
+// leaves of the search are the colourings where every name has its own
+// colour, i.e. the ranks of the names. As the colours only depend on
+// the structure of the components, the leaves of congruent processes
+// are the same, so the smallest canonical form of the leaves is exact.
+type refiner struct {
+	c     *canonicaliser
+	depth int
+	env   map[string]binding
+	names []Name    // Restricted names.
+	named []Name    // Names kept in the normal form with original names.
+	comps []Process // Parallel components.
+	users [][]int   // Components using each of names.
+}
+
+// search calls leaf with the ranks of each leaf of the search from the
+// colouring colours, until leaf returns an error.
+func (r *refiner) search(colours []int, leaf func(ranks []int) error) error {
+	colours, err := r.refine(colours)
+	if err != nil {
+		return err
+	}
+	cells := make(map[int][]int)
+	for i, colour := range colours {
+		cells[colour] = append(cells[colour], i)
+	}
+	if len(cells) == len(colours) {
+		return leaf(colours)
+	}
+	target := -1
+	for colour, cell := range cells {
+		if len(cell) > 1 && (target < 0 || colour < target) {
+			target = colour
+		}
+	}
+	for _, i := range cells[target] {
+		// The colours are doubled to give name i a colour between the
+		// colour of its cell and the next colour.
+		individual := make([]int, len(colours))
+		for j, colour := range colours {
+			individual[j] = 2 * colour
+		}
+		individual[i]++
+		if err := r.search(individual, leaf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refine returns the stable refinement of the colouring colours, where
+// the names are coloured by their colour and their views from the
+// components using them. The colours returned are 0, 1, ... in the order
+// of the views, which only depends on the structure of the components.
+func (r *refiner) refine(colours []int) ([]int, error) {
+	for {
+		sigs := make([]string, len(r.names))
+		for i := range r.names {
+			views := make([]string, len(r.users[i]))
+			for j, comp := range r.users[i] {
+				n, err := r.view(comp, colours, i)
+				if err != nil {
+					return nil, err
+				}
+				views[j] = n.key
+			}
+			sort.Strings(views)
+			sigs[i] = fmt.Sprintf("%d\x00%s", colours[i], strings.Join(views, "\x00"))
+		}
+		distinct := make(map[string]int)
+		var keys []string
+		for _, sig := range sigs {
+			if _, seen := distinct[sig]; !seen {
+				distinct[sig] = 0
+				keys = append(keys, sig)
+			}
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			distinct[key] = i
+		}
+		refined := make([]int, len(r.names))
+		for i, sig := range sigs {
+			refined[i] = distinct[sig]
+		}
+		if len(keys) == countColours(colours) {
+			return refined, nil
+		}
+		colours = refined
+	}
+}
+
+// view returns the normal form of component comp where the restricted
+// names are named by their colours, except the name self, which is
+// marked as the name viewing the component.
+func (r *refiner) view(comp int, colours []int, self int) (normal, error) {
+	env := copyEnv(r.env)
+	for i, n := range r.names {
+		label := name.New(fmt.Sprintf("*%d", colours[i]))
+		if i == self {
+			label = name.New("@")
+		}
+		env[n.Ident()] = binding{canon: label, named: r.named[i]}
+	}
+	return r.c.canonicalise(r.comps[comp], r.depth+len(r.names), env)
+}
+
+// countColours returns the number of distinct colours of colours.
+func countColours(colours []int) int {
+	distinct := make(map[int]bool)
+	for _, colour := range colours {
+		distinct[colour] = true
+	}
+	return len(distinct)
+}
+
+func newNormal(canon, named Process) normal {
+	return normal{canon: canon, named: named, key: canon.Calculi()}
+}
+
+func copyEnv(env map[string]binding) map[string]binding {
+	m := make(map[string]binding, len(env))
+	for x, b := range env {
+		m[x] = b
+	}
+	return m
+}
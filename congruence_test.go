@@ -0,0 +1,183 @@
+package asyncpi
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCongruent(t *testing.T) {
+	tests := []struct {
+		P, Q      string
+		Congruent bool
+	}{
+		{`a<> | b<>`, `b<> | a<>`, true},
+		{`a<> | b<> | c<>`, `c<> | (new z)(b<> | a<>)`, true},
+		{`a<> | 0`, `a<>`, true},
+		{`(new x)0`, `0`, true},
+		{`!0`, `0`, true},
+		{`(new x,y)x<y>`, `(new y,x)x<y>`, true},
+		{`(new x)x<> | b<>`, `(new x)(x<> | b<>)`, true},
+		{`(new x)x<> | x<>`, `(new x)(x<> | x<>)`, false},
+		{`(new x)(new y)(x<> | y<>)`, `(new y)y<> | (new x)x<>`, true},
+		{`(new x)x<> | (new x)x().0`, `(new x)(x<> | x().0)`, false},
+		{`a(x).(x<> | 0)`, `a(y).y<>`, true},
+		{`a(x).(new z)z<>`, `a(y).0`, false},
+		{`!(a<> | b<>)`, `!(b<> | a<>)`, true},
+		{`(new x,y)(x<y> | y<x>)`, `(new u,v)(v<u> | u<v>)`, true},
+		{`(new x,y)(x<y> | y<y>)`, `(new u,v)(u<v> | v<u>)`, false},
+		{`(new x)(a<x> | b<x>)`, `(new x)a<x> | (new x)b<x>`, false},
+		{`(new x,y)(x<y> | y<x> | a<x>)`, `(new u,v)(a<v> | v<u> | u<v>)`, true},
+		{`(new x,y)(x<y> | y<x> | a<x>)`, `(new u,v)(a<v> | v<v> | u<u>)`, false},
+		{`(new x)x<> | (new y)y<> | (new z)z<>`, `(new z)z<> | (new x)x<> | (new y)y<>`, true},
+	}
+	for _, test := range tests {
+		p, err := Parse(strings.NewReader(test.P))
+		if err != nil {
+			t.Fatal(err)
+		}
+		q, err := Parse(strings.NewReader(test.Q))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Congruent(p, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := test.Congruent; want != got {
+			cp, _ := Canonical(p)
+			cq, _ := Canonical(q)
+			t.Errorf("expects Congruent(%s, %s) to be %t but got %t\ncanonical forms: %s and %s",
+				test.P, test.Q, want, got, cp.Calculi(), cq.Calculi())
+		}
+	}
+}
+
+func TestCanonicalNarrowScope(t *testing.T) {
+	p, err := Parse(strings.NewReader(`(new x,y,z)(a<x> | x<y> | b<>)`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Canonical(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := `((new _0)((new _1)_0<_1> | a<_0>) | b<>)`, c.Calculi(); want != got {
+		t.Fatalf("expects canonical form %s but got %s", want, got)
+	}
+}
+
+func TestSimplifyBySCKeepsNames(t *testing.T) {
+	p, err := Parse(strings.NewReader(`(new x)(new y)(0 | x<> | y<> | x().0)`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := p.Calculi()
+	s, err := SimplifyBySC(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := `((new x)(x().0 | x<>) | (new y)y<>)`, s.Calculi(); want != got {
+		t.Fatalf("expects simplified process %s but got %s", want, got)
+	}
+	if orig != p.Calculi() {
+		t.Fatalf("expects SimplifyBySC to not modify %s but got %s", orig, p.Calculi())
+	}
+}
+
+func TestSimplifyBySCRenameClash(t *testing.T) {
+	p, err := Parse(strings.NewReader(`(new x)x<> | x().0`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := SimplifyBySC(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if congruent, err := Congruent(p, s); err != nil {
+		t.Fatal(err)
+	} else if !congruent {
+		t.Fatalf("expects %s to be congruent to %s", s.Calculi(), p.Calculi())
+	}
+}
+
+func TestSimplifyBySCManyComponents(t *testing.T) {
+	var comps []string
+	for i := 0; i < 250; i++ {
+		comps = append(comps, fmt.Sprintf("(new x)(a<x> | x(y).y<b%d>)", i%3))
+	}
+	p, err := Parse(strings.NewReader(strings.Join(comps, " | ")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := SimplifyBySC(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	par, ok := s.(*Par)
+	if !ok || len(par.Procs) != 250 {
+		t.Fatalf("expects 250 parallel components but got %s", s.Calculi())
+	}
+	if congruent, err := Congruent(p, s); err != nil {
+		t.Fatal(err)
+	} else if !congruent {
+		t.Fatalf("expects %s to be congruent to %s", s.Calculi(), p.Calculi())
+	}
+}
+
+// cycle returns the scope of the names h, x0, x1, ... where each xi sends
+// the next name in its cycle and h, and the cycles have the given lengths.
+// The components are in the order of perm.
+func cycle(perm []int, lengths ...int) string {
+	names := []string{"h"}
+	var comps []string
+	for _, length := range lengths {
+		start := len(names)
+		for i := 0; i < length; i++ {
+			names = append(names, fmt.Sprintf("x%d", start+i-1))
+		}
+		for i := 0; i < length; i++ {
+			comps = append(comps, fmt.Sprintf("%s<%s,h>", names[start+i], names[start+(i+1)%length]))
+		}
+	}
+	ordered := make([]string, len(comps))
+	for i, j := range perm {
+		ordered[i] = comps[j]
+	}
+	return "(new " + strings.Join(names, ",") + ")(" + strings.Join(ordered, " | ") + ")"
+}
+
+// Components indistinguishable before naming are named exactly,
+// however many orderings of the components there are.
+func TestCongruentSymmetric(t *testing.T) {
+	identity := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	shuffled := []int{7, 2, 9, 0, 5, 3, 8, 1, 6, 4}
+	tests := []struct {
+		P, Q      string
+		Congruent bool
+	}{
+		{cycle(identity, 10), cycle(shuffled, 10), true},
+		{cycle(identity, 5, 5), cycle(shuffled, 5, 5), true},
+		{cycle(identity, 3, 7), cycle(shuffled, 7, 3), true},
+		// Every name is used the same way, only the cycles differ.
+		{cycle(identity, 10), cycle(shuffled, 5, 5), false},
+		{cycle(identity, 4, 6), cycle(shuffled, 5, 5), false},
+	}
+	for _, test := range tests {
+		p, err := Parse(strings.NewReader(test.P))
+		if err != nil {
+			t.Fatal(err)
+		}
+		q, err := Parse(strings.NewReader(test.Q))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Congruent(p, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := test.Congruent; want != got {
+			t.Errorf("expects Congruent(%s, %s) to be %t but got %t", test.P, test.Q, want, got)
+		}
+	}
+}
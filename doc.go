@@ -52,4 +52,10 @@
 // Since i is used as a channel, i cannot be of type int, the annotation is
 // therefore ignored.
 //
+// A name being created can also be annotated linear, i.e. it is expected to
+// be used exactly once for input and once for output. The annotation is
+// checked by the linearity analysis of the types package.
+//
+//   (new lin r)(a<r> | r().0)
+//
 package asyncpi // import "go.nickng.io/asyncpi"
@@ -0,0 +1,221 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asyncpi
+
+// Traversal.
+// This file contains functions for traversing and rewriting processes.
+
+// Inspect traverses a Process p in depth-first order: it starts by
+// calling f(p); p must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the child Processes of p, followed by a call
+// of f(nil).
+func Inspect(p Process, f func(Process) bool) {
+	Apply(p, func(c *Cursor) bool {
+		return f(c.Node())
+	}, func(c *Cursor) bool {
+		f(nil)
+		return true
+	})
+}
+
+// InspectNames traverses a Process p in depth-first order and calls
+// f(n, s) for each Name n in p, where s is the Scope n occurs in.
+// Names are visited in syntactic order; the binders of a Restrict or a
+// Recv are visited in the scope they are declared in, before the
+// Names in their scope.
+func InspectNames(p Process, f func(n Name, s *Scope)) {
+	Apply(p, func(c *Cursor) bool {
+		switch p := c.Node().(type) {
+		case *Recv:
+			f(p.Chan, c.Scope())
+			for _, v := range p.Vars {
+				f(v, c.Scope())
+			}
+		case *Restrict:
+			f(p.Name, c.Scope())
+		case *Send:
+			f(p.Chan, c.Scope())
+			for _, v := range p.Vals {
+				f(v, c.Scope())
+			}
+		}
+		return true
+	}, nil)
+}
+
+// An ApplyFunc is invoked by Apply for each Process p, even if p is nil,
+// before and/or after the child Processes of p are traversed.
+//
+// See Apply for the interpretation of the return value.
+type ApplyFunc func(*Cursor) bool
+
+// Apply traverses a Process p recursively, starting with p, and calling
+// pre and post for each Process in depth-first order. Both pre and post
+// can be nil.
+//
+// If pre returns false, the children of the Process are not traversed,
+// and post is not called for that Process.
+//
+// If post returns false, the traversal is terminated and Apply returns
+// immediately.
+//
+// The Process can be modified using the Cursor methods Replace and Delete.
+// If pre replaces the Process, the children of the replacement are
+// traversed instead. If the Process is deleted, its children are not
+// traversed and post is not called.
+//
+// Apply returns the (possibly replaced) Process p.
+func Apply(p Process, pre, post ApplyFunc) Process {
+	root := p
+	c := &Cursor{node: p, index: -1}
+	c.set = func(p Process) { root = p }
+	a := &applier{pre: pre, post: post}
+	a.apply(c)
+	return root
+}
+
+// A Cursor describes a Process encountered during Apply.
+type Cursor struct {
+	parent  Process
+	node    Process
+	index   int // Index in parent Par, or -1.
+	set     func(Process)
+	deleted bool
+	scope   *Scope
+}
+
+// Node returns the current Process.
+func (c *Cursor) Node() Process { return c.node }
+
+// Parent returns the parent of the current Process,
+// or nil if the current Process is the root.
+func (c *Cursor) Parent() Process { return c.parent }
+
+// Index returns the index of the current Process in its parent Par,
+// or -1 if the parent is not a Par.
+func (c *Cursor) Index() int { return c.index }
+
+// Scope returns the Scope of the current Process, i.e. the Names bound
+// by the enclosing Restrict and Recv. The Scope is nil if the current
+// Process has no enclosing binder.
+func (c *Cursor) Scope() *Scope { return c.scope }
+
+// Replace replaces the current Process with p.
+func (c *Cursor) Replace(p Process) {
+	c.node = p
+	c.set(p)
+}
+
+// Delete deletes the current Process from its parent Par.
+// If the parent is not a Par, the current Process is replaced by
+// the inaction process.
+func (c *Cursor) Delete() {
+	if par, ok := c.parent.(*Par); ok && c.index >= 0 {
+		par.Procs = append(par.Procs[:c.index], par.Procs[c.index+1:]...)
+		c.deleted = true
+		return
+	}
+	c.Replace(NewNilProcess())
+	c.deleted = true
+}
+
+type applier struct {
+	pre, post ApplyFunc
+}
+
+// apply traverses the Process at c, and returns false if the traversal
+// is terminated.
+func (a *applier) apply(c *Cursor) bool {
+	if a.pre != nil && !a.pre(c) {
+		return true
+	}
+	if c.deleted {
+		return true
+	}
+	if !a.children(c.node, c.scope) {
+		return false
+	}
+	if a.post != nil && !a.post(c) {
+		return false
+	}
+	return true
+}
+
+// children traverses the child Processes of p in scope.
+//
+// This is the only place where a new Process implementation needs to be
+// added for traversal.
+func (a *applier) children(p Process, scope *Scope) bool {
+	switch p := p.(type) {
+	case *Par:
+		for i := 0; i < len(p.Procs); {
+			c := &Cursor{parent: p, node: p.Procs[i], index: i, scope: scope}
+			c.set = func(q Process) { p.Procs[c.index] = q }
+			if !a.apply(c) {
+				return false
+			}
+			if !c.deleted {
+				i++
+			}
+		}
+	case *Recv:
+		s := &Scope{Outer: scope, Binder: p, Names: p.Vars}
+		c := &Cursor{parent: p, node: p.Cont, index: -1, scope: s}
+		c.set = func(q Process) { p.Cont = q }
+		return a.apply(c)
+	case *Repeat:
+		c := &Cursor{parent: p, node: p.Proc, index: -1, scope: scope}
+		c.set = func(q Process) { p.Proc = q }
+		return a.apply(c)
+	case *Restrict:
+		s := &Scope{Outer: scope, Binder: p, Names: []Name{p.Name}}
+		c := &Cursor{parent: p, node: p.Proc, index: -1, scope: s}
+		c.set = func(q Process) { p.Proc = q }
+		return a.apply(c)
+	}
+	return true
+}
+
+// Scope is a set of Names bound by a binder (a Restrict or a Recv),
+// nested in the Outer Scope.
+//
+// A nil *Scope is the empty top-level scope.
+type Scope struct {
+	Outer  *Scope
+	Binder Process // Restrict or Recv that binds Names.
+	Names  []Name
+}
+
+// Lookup returns the innermost bound Name with the given ident and the
+// Scope it is bound in. It returns nil if ident is not bound (i.e. free).
+func (s *Scope) Lookup(ident string) (Name, *Scope) {
+	for ; s != nil; s = s.Outer {
+		for i := len(s.Names) - 1; i >= 0; i-- {
+			if s.Names[i].Ident() == ident {
+				return s.Names[i], s
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Depth returns the number of nested Scopes, i.e. 0 for the top-level.
+func (s *Scope) Depth() int {
+	depth := 0
+	for ; s != nil; s = s.Outer {
+		depth++
+	}
+	return depth
+}
@@ -0,0 +1,94 @@
+package asyncpi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInspectOrder(t *testing.T) {
+	p, err := Parse(strings.NewReader(`(new a)(a<b> | !a(x).x<>)`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var visited []string
+	Inspect(p, func(p Process) bool {
+		if p != nil {
+			visited = append(visited, p.Calculi())
+		}
+		return true
+	})
+	want := []string{`(new a)(a<b> | !a(x).x<>)`, `(a<b> | !a(x).x<>)`, `a<b>`, `!a(x).x<>`, `a(x).x<>`, `x<>`}
+	if len(want) != len(visited) {
+		t.Fatalf("expects to visit %q but got %q", want, visited)
+	}
+	for i := range want {
+		if want[i] != visited[i] {
+			t.Errorf("expects to visit %s but got %s", want[i], visited[i])
+		}
+	}
+}
+
+func TestApplyReplaceDelete(t *testing.T) {
+	p, err := Parse(strings.NewReader(`a<> | b<> | c().0`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = Apply(p, func(c *Cursor) bool {
+		if s, ok := c.Node().(*Send); ok {
+			switch s.Chan.Ident() {
+			case "a":
+				c.Delete()
+			case "b":
+				c.Replace(NewRepeat(s))
+				return false
+			}
+		}
+		return true
+	}, nil)
+	if want, got := `((!b<>) | c().0)`, p.Calculi(); want != got {
+		t.Fatalf("expects %s but got %s", want, got)
+	}
+	p = Apply(p, nil, func(c *Cursor) bool {
+		if _, ok := c.Node().(*Par); ok {
+			c.Replace(NewNilProcess())
+		}
+		return true
+	})
+	if want, got := `0`, p.Calculi(); want != got {
+		t.Fatalf("expects %s but got %s", want, got)
+	}
+}
+
+func TestApplyScope(t *testing.T) {
+	p, err := Parse(strings.NewReader(`(new a)a(x,a).(new y)x<a,y,z>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	Apply(p, func(c *Cursor) bool {
+		s, ok := c.Node().(*Send)
+		if !ok {
+			return true
+		}
+		if want, got := 3, c.Scope().Depth(); want != got {
+			t.Errorf("expects scope depth %d but got %d", want, got)
+		}
+		for _, v := range s.Vals {
+			n, scope := c.Scope().Lookup(v.Ident())
+			switch v.Ident() {
+			case "a":
+				if _, ok := scope.Binder.(*Recv); !ok || n != scope.Binder.(*Recv).Vars[1] {
+					t.Errorf("expects %s to be bound by receive but got %v", v.Ident(), scope.Binder)
+				}
+			case "y":
+				if _, ok := scope.Binder.(*Restrict); !ok {
+					t.Errorf("expects %s to be bound by restrict but got %v", v.Ident(), scope.Binder)
+				}
+			case "z":
+				if n != nil || scope != nil {
+					t.Errorf("expects %s to be free but got %v", v.Ident(), n)
+				}
+			}
+		}
+		return true
+	}, nil)
+}
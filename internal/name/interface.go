@@ -23,3 +23,13 @@ type Setter interface {
 type TypeHinter interface {
 	TypeHint() string
 }
+
+// Positioner means a name has a position in the source input.
+type Positioner interface {
+	Pos() Pos
+}
+
+// LinearAnnotated means a name may be annotated linear.
+type LinearAnnotated interface {
+	IsLinear() bool
+}
@@ -15,14 +15,36 @@
 // Package name provides internal default implementations of the asyncpi Names.
 package name
 
+import "fmt"
+
+// Pos is a position of a name in the source input.
+// The zero Pos is not a valid position.
+type Pos struct {
+	Line, Col int
+}
+
+// IsValid returns true if pos is a valid position.
+func (pos Pos) IsValid() bool {
+	return pos.Line > 0
+}
+
+func (pos Pos) String() string {
+	if !pos.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", pos.Line, pos.Col)
+}
+
 // base is a default Name implementation.
 type base struct {
-	name string
+	name   string
+	pos    Pos
+	linear bool
 }
 
 // New returns a new concrete name from a string.
 func New(name string) *base {
-	return &base{name}
+	return &base{name: name}
 }
 
 // Ident returns the string identifier of the base name n.
@@ -39,15 +61,37 @@ func (n *base) String() string {
 	return n.name
 }
 
+// Pos returns the source position of the base name n.
+func (n *base) Pos() Pos {
+	return n.pos
+}
+
+// SetPos sets the source position.
+func (n *base) SetPos(pos Pos) {
+	n.pos = pos
+}
+
+// IsLinear returns true if the base name n is annotated linear.
+func (n *base) IsLinear() bool {
+	return n.linear
+}
+
+// SetLinear sets the linear annotation.
+func (n *base) SetLinear(linear bool) {
+	n.linear = linear
+}
+
 // hinted represents a name with type hint.
 type hinted struct {
-	name string
-	hint string
+	name   string
+	hint   string
+	pos    Pos
+	linear bool
 }
 
 // NewHinted returns a new hinted name from a string name and type hint.
 func NewHinted(name, hint string) *hinted {
-	return &hinted{name, hint}
+	return &hinted{name: name, hint: hint}
 }
 
 // Ident returns the string identifier of the hinted name n.
@@ -64,7 +108,41 @@ func (n *hinted) String() string {
 	return n.name
 }
 
+// Pos returns the source position of the hinted name n.
+func (n *hinted) Pos() Pos {
+	return n.pos
+}
+
+// SetPos sets the source position.
+func (n *hinted) SetPos(pos Pos) {
+	n.pos = pos
+}
+
+// IsLinear returns true if the hinted name n is annotated linear.
+func (n *hinted) IsLinear() bool {
+	return n.linear
+}
+
+// SetLinear sets the linear annotation.
+func (n *hinted) SetLinear(linear bool) {
+	n.linear = linear
+}
+
 // TypeHint returns the type hint of hinted name n.
 func (n *hinted) TypeHint() string {
 	return n.hint
 }
+
+// Copy returns a copy of a name n implemented in this package,
+// and false if n is not implemented in this package.
+func Copy(n interface{}) (interface{}, bool) {
+	switch n := n.(type) {
+	case *base:
+		clone := *n
+		return &clone, true
+	case *hinted:
+		clone := *n
+		return &clone, true
+	}
+	return nil, false
+}
@@ -0,0 +1,221 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interp
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/resolve"
+	"go.nickng.io/asyncpi/types"
+)
+
+// Func is a Go function acting as an external service, which is called
+// with the values of a message and returns the values of its reply.
+//
+// A name bound to a Func is an output-only channel, where the last value
+// of each message is the reply channel, i.e. a<x1,...,xn,r> calls the
+// Func with x1,...,xn and sends its results on r.
+type Func func(args []Value) []Value
+
+// HostError is the type of error when a value of the caller cannot be
+// used as the channel of a name.
+type HostError struct {
+	Name string
+	Msg  string
+}
+
+func (e *HostError) Error() string {
+	return fmt.Sprintf("cannot use %s: %s", e.Name, e.Msg)
+}
+
+var (
+	valuesType  = reflect.TypeOf([]Value(nil))
+	chanPtrType = reflect.TypeOf((*Chan)(nil))
+)
+
+// hostChan is a Go channel of the caller. A message is sent as a []Value
+// on a chan []Value, and as its only value on other channels.
+type hostChan struct {
+	ch    reflect.Value
+	tuple bool
+}
+
+func newHostChan(ch reflect.Value) hostChan {
+	return hostChan{ch: ch, tuple: ch.Type().Elem() == valuesType}
+}
+
+// message returns the values vals as a message sent on h.
+func (h hostChan) message(ident string, vals []Value) (reflect.Value, error) {
+	if h.tuple {
+		return reflect.ValueOf(vals), nil
+	}
+	if len(vals) != 1 {
+		return reflect.Value{}, &ArityError{Chan: ident, Want: 1, Got: len(vals)}
+	}
+	elem := h.ch.Type().Elem()
+	v := reflect.ValueOf(vals[0])
+	if !v.IsValid() {
+		return reflect.Zero(elem), nil
+	}
+	if !v.Type().AssignableTo(elem) {
+		return reflect.Value{}, &HostError{Name: ident, Msg: fmt.Sprintf("cannot send %v (type: %T) as %s", vals[0], vals[0], elem)}
+	}
+	return v, nil
+}
+
+// values returns the values of the message v received on h.
+func (h hostChan) values(v reflect.Value) []Value {
+	if h.tuple {
+		return v.Interface().([]Value)
+	}
+	return []Value{v.Interface()}
+}
+
+// channelOf returns the channel of the value v of the name ident, which
+// is a *Chan, a hostChan or a Func.
+func channelOf(ident string, v Value) (Value, error) {
+	switch c := v.(type) {
+	case *Chan, Func:
+		return c, nil
+	}
+	if ch := reflect.ValueOf(v); ch.Kind() == reflect.Chan && !ch.IsNil() {
+		return newHostChan(ch), nil
+	}
+	return nil, &ChanError{Name: ident, Value: v}
+}
+
+// BindFunc binds the free name ident to the external service f (see Func).
+//
+// The type of the name inferred by types.Infer must be a channel which
+// is only sent on, and carries a reply channel as its last value.
+func (in *Interp) BindFunc(ident string, f Func) error {
+	obj, err := in.freeName(ident)
+	if err != nil {
+		return err
+	}
+	if f == nil {
+		return &NameError{Name: ident, Msg: "nil Func"}
+	}
+	t, err := in.typeOf(ident)
+	if err != nil {
+		return err
+	}
+	if ch, isChan := t.(*types.Chan); isChan {
+		if ch.Dir().Has(types.RecvOnly) {
+			return &NameError{Name: ident, Msg: "a service cannot be received on"}
+		}
+		elems := elemTypes(ch)
+		if len(elems) == 0 {
+			return &NameError{Name: ident, Msg: "a service needs a reply channel"}
+		}
+		if _, isBase := underlying(elems[len(elems)-1]).(*types.Base); isBase {
+			return &NameError{Name: ident, Msg: fmt.Sprintf("reply of type %s is not a channel", elems[len(elems)-1])}
+		}
+	} else if err := checkValueType(ident, t, reflect.TypeOf(f)); err != nil {
+		return err
+	}
+	in.binds[obj] = f
+	return nil
+}
+
+// typeOf returns the type of the free name ident inferred by types.Infer,
+// with the capability inferred by types.InferCapabilities.
+func (in *Interp) typeOf(ident string) (types.Type, error) {
+	if in.typed == nil {
+		p := asyncpi.Clone(in.proc)
+		if err := types.Infer(p); err != nil {
+			return nil, err
+		}
+		if err := types.InferCapabilities(p); err != nil {
+			return nil, err
+		}
+		typed, err := resolve.Resolve(p)
+		if err != nil {
+			return nil, err
+		}
+		in.typed = typed
+	}
+	for _, obj := range in.typed.FreeNames() {
+		if obj.Ident != ident {
+			continue
+		}
+		n, ok := in.typed.UsesOf(obj)[0].Name().(types.TypedName)
+		if !ok {
+			return nil, types.InferUntypedError{Name: ident}
+		}
+		return underlying(n.Type()), nil
+	}
+	return nil, &NameError{Name: ident, Msg: "not a free name"}
+}
+
+// checkChanType checks the type t of the name ident against the Go
+// channel type g.
+func checkChanType(ident string, t types.Type, g reflect.Type) error {
+	ch, isChan := t.(*types.Chan)
+	if !isChan {
+		return checkValueType(ident, t, g)
+	}
+	if ch.Dir().Has(types.SendOnly) && g.ChanDir()&reflect.SendDir == 0 {
+		return &NameError{Name: ident, Msg: fmt.Sprintf("sent on but %s is receive-only", g)}
+	}
+	if ch.Dir().Has(types.RecvOnly) && g.ChanDir()&reflect.RecvDir == 0 {
+		return &NameError{Name: ident, Msg: fmt.Sprintf("received on but %s is send-only", g)}
+	}
+	if g.Elem() == valuesType {
+		return nil
+	}
+	elems := elemTypes(ch)
+	if len(elems) != 1 {
+		return &NameError{Name: ident, Msg: fmt.Sprintf("carries %d values but %s carries 1", len(elems), g)}
+	}
+	return checkValueType(ident, elems[0], g.Elem())
+}
+
+// checkValueType checks the type t of a value of the name ident against
+// the Go type g.
+func checkValueType(ident string, t types.Type, g reflect.Type) error {
+	if g.Kind() == reflect.Interface {
+		return nil
+	}
+	switch t := underlying(t).(type) {
+	case *types.Chan:
+		if g.Kind() != reflect.Chan && g.Kind() != reflect.Func && g != chanPtrType {
+			return &NameError{Name: ident, Msg: fmt.Sprintf("type %s is not a channel", g)}
+		}
+	case *types.Base:
+		if g.String() != t.String() {
+			return &NameError{Name: ident, Msg: fmt.Sprintf("type %s is not %s", g, t)}
+		}
+	}
+	return nil
+}
+
+// elemTypes returns the types of the values carried by channel type ch.
+func elemTypes(ch *types.Chan) []types.Type {
+	if c, ok := underlying(ch.Elem()).(*types.Composite); ok {
+		return c.Elems()
+	}
+	return []types.Type{ch.Elem()}
+}
+
+// underlying returns the type t with all References resolved.
+func underlying(t types.Type) types.Type {
+	for t.Underlying() != t {
+		t = t.Underlying()
+	}
+	return t
+}
@@ -0,0 +1,154 @@
+package interp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBindType(t *testing.T) {
+	tests := []struct {
+		proc string
+		ch   interface{}
+		ok   bool
+	}{
+		{"a<b>", make(chan []Value), true},
+		{"a<b>", make(chan *Chan), true},
+		{"a<b> | b().0", make(chan chan []Value), true},
+		{"a<b> | b().0", make(chan string), false}, // b is a channel.
+		{"a<b,c>", make(chan interface{}), false},  // Polyadic.
+		{"a<b>", make(<-chan []Value), false},      // Sent on.
+		{"a().0", make(chan<- []Value), false},     // Received on.
+		{"a().0", make(<-chan []Value), true},
+		{"(new b:int)a<b>", make(chan int), true},
+		{"(new b:int)a<b>", make(chan string), false},
+		{"a<b>", 1, false},
+	}
+	for _, test := range tests {
+		in := newInterp(t, test.proc, Options{})
+		err := in.Bind("a", test.ch)
+		if test.ok && err != nil {
+			t.Errorf("%s: expects %T bound but got %v", test.proc, test.ch, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("%s: expects error binding %T but got nil", test.proc, test.ch)
+		}
+	}
+}
+
+func TestBindFuncType(t *testing.T) {
+	tests := []struct {
+		proc string
+		ok   bool
+	}{
+		{"(new r)(a<b,r> | r(x).0)", true},
+		{"(new r)(a<r> | r().0)", true},
+		{"a<>", false},                        // No reply.
+		{"(new b:int,c:int)a<b,c>", false},    // Reply not a channel.
+		{"(new r)(a<b,r> | a(x,y).0)", false}, // Received on.
+	}
+	for _, test := range tests {
+		in := newInterp(t, test.proc, Options{})
+		err := in.BindFunc("a", func(args []Value) []Value { return args })
+		if test.ok && err != nil {
+			t.Errorf("%s: expects Func bound but got %v", test.proc, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("%s: expects error binding Func but got nil", test.proc)
+		}
+	}
+}
+
+func TestBindChan(t *testing.T) {
+	in := newInterp(t, "!a(x).b<x>", Options{})
+	a, b := make(chan int), make(chan int)
+	if err := in.Bind("a", a); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Bind("b", b); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() { errc <- in.Run(ctx) }()
+	for i := 0; i < 3; i++ {
+		a <- i
+		if got := <-b; got != i {
+			t.Errorf("expects %d but got %d", i, got)
+		}
+	}
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("expects %v but got %v", context.Canceled, err)
+	}
+}
+
+func TestBindFunc(t *testing.T) {
+	in := newInterp(t, "in(x).(new r)(double<x,r> | r(y).out<y>)", Options{})
+	inc, out := make(chan int, 1), make(chan []Value, 1)
+	inc <- 3
+	if err := in.Bind("in", inc); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Bind("out", out); err != nil {
+		t.Fatal(err)
+	}
+	err := in.BindFunc("double", func(args []Value) []Value {
+		return []Value{2 * args[0].(int)}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Run(context.Background()); err != nil {
+		t.Fatalf("expects no error but got %v", err)
+	}
+	if got := <-out; len(got) != 1 || got[0] != 6 {
+		t.Errorf("expects [6] but got %v", got)
+	}
+}
+
+// A Process serving the requests of an HTTP handler.
+func TestBindHTTP(t *testing.T) {
+	in := newInterp(t, "!req(k,r).lookup<k,r>", Options{})
+	reqs := make(chan []Value)
+	if err := in.Bind("req", reqs); err != nil {
+		t.Fatal(err)
+	}
+	db := map[string]string{"pi": "3.14"}
+	err := in.BindFunc("lookup", func(args []Value) []Value {
+		return []Value{db[args[0].(string)]}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() { errc <- in.Run(ctx) }()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reply := make(chan string)
+		reqs <- []Value{strings.TrimPrefix(r.URL.Path, "/"), reply}
+		fmt.Fprint(w, <-reply)
+	}))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/pi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "3.14" {
+		t.Errorf("expects 3.14 but got %s", body)
+	}
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("expects %v but got %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package interp executes asynchronous π-calculus Processes directly,
+// without generating Go code.
+//
+// Each Process of a parallel composition runs in a goroutine, and each
+// restricted name is a Chan created when the restriction is executed.
+// Outputs never block, and replicated Processes are copied on demand,
+// when the first input or output of the previous copy is fired. The
+// processes are run by the runtime package, so a Chan is a runtime.Chan.
+//
+// Free names can be bound to Go channels and functions of the caller,
+// e.g. a Process serving the requests of an HTTP handler
+//
+//     !req(x,r).lookup<x,r>
+//
+// is run by
+//
+//     in, err := interp.New(p, interp.Options{})
+//     in.Bind("req", reqs) // reqs is a chan []interp.Value
+//     in.BindFunc("lookup", func(args []interp.Value) []interp.Value {
+//         return []interp.Value{db[args[0].(string)]}
+//     })
+//     err = in.Run(ctx)
+//
+// where the handler sends []interp.Value{key, reply} on reqs, and
+// receives the result on reply. Free names which are not bound are fresh
+// Chans.
+package interp // import "go.nickng.io/asyncpi/interp"
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/internal/errors"
+	"go.nickng.io/asyncpi/resolve"
+	"go.nickng.io/asyncpi/runtime"
+)
+
+// Value is a value of a name, which is a *Chan, a Go channel or a Func
+// for a channel. Values from the caller can be of any type.
+type Value = runtime.Value
+
+// Options are the budgets of a Run.
+type Options struct {
+	MaxSteps int           // Maximum number of communications, 0 for no limit.
+	Timeout  time.Duration // Maximum duration of a Run, 0 for no limit.
+}
+
+// DeadlockError is the type of error when the processes are blocked
+// forever.
+type DeadlockError = runtime.DeadlockError
+
+// StepLimitError is the type of error when the processes exceed the
+// step budget of Options.MaxSteps.
+type StepLimitError struct {
+	Steps int
+}
+
+func (e *StepLimitError) Error() string {
+	return fmt.Sprintf("step budget of %d communication(s) exceeded", e.Steps)
+}
+
+// NameError is the type of error when a name cannot be bound.
+type NameError struct {
+	Name string
+	Msg  string
+}
+
+func (e *NameError) Error() string {
+	return fmt.Sprintf("cannot bind %q: %s", e.Name, e.Msg)
+}
+
+// ChanError is the type of error when the value of a name used as a
+// channel is not a channel.
+type ChanError struct {
+	Name  string
+	Value Value
+}
+
+func (e *ChanError) Error() string {
+	return fmt.Sprintf("%s is not a channel: %v (type: %T)", e.Name, e.Value, e.Value)
+}
+
+// ArityError is the type of error when a message received does not
+// have the number of values of the input.
+type ArityError struct {
+	Chan string
+	Want int
+	Got  int
+}
+
+func (e *ArityError) Error() string {
+	return fmt.Sprintf("arity mismatch on %s: expects %d value(s) but got %d", e.Chan, e.Want, e.Got)
+}
+
+// Interp is an interpreter of a Process.
+type Interp struct {
+	proc  asyncpi.Process
+	info  *resolve.Info
+	opt   Options
+	typed *resolve.Info // Names of a typed copy of proc, nil until needed.
+	binds map[*resolve.Object]Value
+	steps int
+}
+
+// New returns an interpreter of Process p with the budgets opt.
+// The Process p is not modified.
+func New(p asyncpi.Process, opt Options) (*Interp, error) {
+	info, err := resolve.Resolve(p)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot resolve names")
+	}
+	return &Interp{proc: p, info: info, opt: opt, binds: make(map[*resolve.Object]Value)}, nil
+}
+
+// freeName returns the free name with the given ident.
+func (in *Interp) freeName(ident string) (*resolve.Object, error) {
+	for _, obj := range in.info.FreeNames() {
+		if obj.Ident == ident {
+			return obj, nil
+		}
+	}
+	return nil, &NameError{Name: ident, Msg: "not a free name"}
+}
+
+// Bind binds the free name ident to the Go channel ch, so the messages
+// sent on the name are sent to ch and the messages received are
+// received from ch. Closing ch blocks the receivers forever.
+//
+// A message is a []Value on a chan []Value, or its only value on other
+// Go channels. The type of the name inferred by types.Infer must match
+// the type of ch, e.g. a name carrying a channel cannot be bound to a
+// chan string, and a name which is sent on cannot be bound to a
+// receive-only channel.
+func (in *Interp) Bind(ident string, ch interface{}) error {
+	obj, err := in.freeName(ident)
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(ch)
+	if v.Kind() != reflect.Chan {
+		return &NameError{Name: ident, Msg: fmt.Sprintf("%T is not a channel", ch)}
+	}
+	if v.IsNil() {
+		return &NameError{Name: ident, Msg: "nil channel"}
+	}
+	t, err := in.typeOf(ident)
+	if err != nil {
+		return err
+	}
+	if err := checkChanType(ident, t, v.Type()); err != nil {
+		return err
+	}
+	in.binds[obj] = ch
+	return nil
+}
+
+// Steps returns the number of communications of the last Run.
+func (in *Interp) Steps() int {
+	return in.steps
+}
+
+// Run executes the Process and waits until the processes are terminated,
+// i.e. every process has finished or is blocked forever, where only
+// replicas and outputs are waiting. Processes waiting on a channel bound
+// by Bind are not blocked forever, as the caller may communicate.
+//
+// Run returns a *DeadlockError if other processes are blocked forever,
+// a *StepLimitError if the step budget is exceeded, or ctx.Err() if ctx
+// is done or the time budget is exceeded first. In every case, the
+// processes are stopped before Run returns.
+func (in *Interp) Run(ctx context.Context) error {
+	if in.opt.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, in.opt.Timeout)
+		defer cancel()
+	}
+	m := &machine{info: in.info, maxSteps: in.opt.MaxSteps}
+	err := runtime.Config{}.Run(ctx, func(g *runtime.Group) {
+		m.g = g
+		var e *env
+		for _, obj := range in.info.FreeNames() {
+			v, bound := in.binds[obj]
+			if !bound {
+				v = g.New(obj.Ident)
+			}
+			e = e.bind(obj, v)
+		}
+		m.stop(m.exec(in.proc, e, nil))
+	})
+	in.steps = m.steps
+	return err
+}
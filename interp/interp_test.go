@@ -0,0 +1,171 @@
+package interp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.nickng.io/asyncpi"
+)
+
+func newInterp(t *testing.T, proc string, opt Options) *Interp {
+	t.Helper()
+	p, err := asyncpi.Parse(strings.NewReader(proc))
+	if err != nil {
+		t.Fatalf("cannot parse %s: %v", proc, err)
+	}
+	in, err := New(p, opt)
+	if err != nil {
+		t.Fatalf("cannot create interpreter of %s: %v", proc, err)
+	}
+	return in
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		proc  string
+		steps int
+	}{
+		{"0", 0},
+		{"(new a)(a<> | a().0)", 1},
+		{"(new a,b)(a<b> | a(x).x<> | b().0)", 2},
+		{"(new a,b)(a<b,b> | a(x,y).x<y> | b(z).0)", 2},
+		{"(new a)(a<> | a<>)", 0},                         // Pending outputs.
+		{"(new a)(!a().0 | a<> | a<>)", 2},                // Replicated input.
+		{"(new a)(!a<> | a().a().a().0)", 3},              // Replicated output.
+		{"(new a)(!(new c)(a<c> | c().0) | a(x).x<>)", 2}, // Replicated restriction.
+	}
+	for _, test := range tests {
+		in := newInterp(t, test.proc, Options{})
+		if err := in.Run(context.Background()); err != nil {
+			t.Errorf("%s: expects no error but got %v", test.proc, err)
+			continue
+		}
+		if in.Steps() != test.steps {
+			t.Errorf("%s: expects %d steps but got %d", test.proc, test.steps, in.Steps())
+		}
+	}
+}
+
+func TestRunDeadlock(t *testing.T) {
+	tests := []struct {
+		proc    string
+		blocked int
+	}{
+		{"a().0", 1},
+		{"(new a,b)(a().b<> | b().a<>)", 2},
+		{"(new a,b)(!a(x).x().0 | a<b>)", 1},
+	}
+	for _, test := range tests {
+		in := newInterp(t, test.proc, Options{})
+		err := in.Run(context.Background())
+		deadlock, ok := err.(*DeadlockError)
+		if !ok {
+			t.Errorf("%s: expects DeadlockError but got %v", test.proc, err)
+			continue
+		}
+		if deadlock.Blocked != test.blocked {
+			t.Errorf("%s: expects %d blocked processes but got %d", test.proc, test.blocked, deadlock.Blocked)
+		}
+	}
+}
+
+func TestRunStepLimit(t *testing.T) {
+	in := newInterp(t, "(new a)(!a().a<> | a<>)", Options{MaxSteps: 10})
+	err := in.Run(context.Background())
+	if _, ok := err.(*StepLimitError); !ok {
+		t.Fatalf("expects StepLimitError but got %v", err)
+	}
+	if in.Steps() != 10 {
+		t.Errorf("expects 10 steps but got %d", in.Steps())
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	ch := make(chan []Value)
+	in := newInterp(t, "a().0", Options{Timeout: 10 * time.Millisecond})
+	if err := in.Bind("a", ch); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Run(context.Background()); err != context.DeadlineExceeded {
+		t.Errorf("expects %v but got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan []Value)
+	in := newInterp(t, "!a(x).x<x>", Options{})
+	if err := in.Bind("a", ch); err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error)
+	go func() { errc <- in.Run(ctx) }()
+	reply := make(chan []Value)
+	for i := 0; i < 3; i++ {
+		ch <- []Value{reply}
+		if got := <-reply; len(got) != 1 || got[0] != reply {
+			t.Errorf("expects reply channel echoed but got %v", got)
+		}
+	}
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("expects %v but got %v", context.Canceled, err)
+	}
+}
+
+func TestBind(t *testing.T) {
+	ch := make(chan []Value, 1)
+	in := newInterp(t, "(new b)a<b>", Options{})
+	if err := in.Bind("b", ch); err == nil {
+		t.Errorf("expects error binding restricted name b but got nil")
+	}
+	if err := in.Bind("a", ch); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Run(context.Background()); err != nil {
+		t.Fatalf("expects no error but got %v", err)
+	}
+	vals := <-ch
+	if c, ok := vals[0].(*Chan); !ok || c.Name() != "b" {
+		t.Errorf("expects channel b but got %v", vals)
+	}
+}
+
+// Closing a bound channel blocks its receivers.
+func TestBindClose(t *testing.T) {
+	ch := make(chan []Value)
+	close(ch)
+	in := newInterp(t, "!a().0", Options{})
+	if err := in.Bind("a", ch); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Run(context.Background()); err != nil {
+		t.Errorf("expects no error but got %v", err)
+	}
+}
+
+func TestRunArity(t *testing.T) {
+	ch := make(chan []Value, 1)
+	ch <- []Value{1, 2}
+	in := newInterp(t, "a(x).0", Options{})
+	if err := in.Bind("a", ch); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := in.Run(context.Background()).(*ArityError); !ok {
+		t.Errorf("expects ArityError")
+	}
+}
+
+func TestRunChanError(t *testing.T) {
+	ch := make(chan []Value, 1)
+	ch <- []Value{1}
+	in := newInterp(t, "a(x).x<>", Options{})
+	if err := in.Bind("a", ch); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := in.Run(context.Background()).(*ChanError); !ok {
+		t.Errorf("expects ChanError")
+	}
+}
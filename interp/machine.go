@@ -0,0 +1,270 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interp
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/resolve"
+	"go.nickng.io/asyncpi/runtime"
+)
+
+// errStopped is returned by the operations of a process when the Run
+// has ended.
+var errStopped = errors.New("stopped")
+
+// Chan is a channel created by a restriction, with an unbounded buffer
+// of messages. A Chan is only valid in the Run which created it.
+type Chan = runtime.Chan
+
+// env is an environment mapping names to their values.
+type env struct {
+	obj  *resolve.Object
+	val  Value
+	next *env
+}
+
+// bind returns the environment e extended with obj bound to v.
+func (e *env) bind(obj *resolve.Object, v Value) *env {
+	return &env{obj: obj, val: v, next: e}
+}
+
+// lookup returns the value bound to obj, or nil if obj is not bound.
+func (e *env) lookup(obj *resolve.Object) Value {
+	for ; e != nil; e = e.next {
+		if e.obj == obj {
+			return e.val
+		}
+	}
+	return nil
+}
+
+// machine is the state of a Run, where the processes are run by the
+// runtime.Group g, which detects when they are blocked forever.
+type machine struct {
+	g        *runtime.Group
+	info     *resolve.Info
+	maxSteps int
+
+	mu    sync.Mutex
+	steps int
+}
+
+// step records a communication, and stops the Run if it exceeds the
+// step budget.
+func (m *machine) step() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.maxSteps > 0 && m.steps >= m.maxSteps {
+		m.g.Stop(&StepLimitError{Steps: m.maxSteps})
+		return errStopped
+	}
+	m.steps++
+	return nil
+}
+
+// stop stops the Run if a process failed with err.
+func (m *machine) stop(err error) {
+	if err != nil && err != errStopped {
+		m.g.Stop(err)
+	}
+}
+
+// spawn starts the process f.
+func (m *machine) spawn(f func() error) {
+	m.g.Spawn(func() { m.stop(f()) })
+}
+
+// replicate starts the replicated Process p.
+func (m *machine) replicate(p asyncpi.Process, e *env) {
+	m.g.Replicate(func(r *runtime.Replica) { m.stop(m.exec(p, e, r)) })
+}
+
+// exec executes Process p in the environment e, where r is the replica
+// if the operations of p are its guards.
+func (m *machine) exec(p asyncpi.Process, e *env, r *runtime.Replica) error {
+	value := func(p asyncpi.Process, i int) Value {
+		return e.lookup(m.info.ObjectOf(resolve.Occurrence{Proc: p, Index: i}))
+	}
+	for {
+		switch proc := p.(type) {
+		case *asyncpi.NilProcess:
+			return nil
+		case *asyncpi.Par:
+			if len(proc.Procs) == 0 {
+				return nil
+			}
+			for _, q := range proc.Procs[:len(proc.Procs)-1] {
+				q := q
+				m.spawn(func() error { return m.exec(q, e, r) })
+			}
+			p = proc.Procs[len(proc.Procs)-1]
+		case *asyncpi.Repeat:
+			m.replicate(proc.Proc, e)
+			return nil
+		case *asyncpi.Restrict:
+			obj := m.info.ObjectOf(resolve.Occurrence{Proc: proc, Index: 0})
+			e = e.bind(obj, m.g.New(proc.Name.Ident()))
+			p = proc.Proc
+		case *asyncpi.Recv:
+			ch := value(proc, 0)
+			vals, err := m.recv(proc.Chan.Ident(), ch, r)
+			if err != nil {
+				return err
+			}
+			if len(vals) != len(proc.Vars) {
+				return &ArityError{Chan: proc.Chan.Ident(), Want: len(proc.Vars), Got: len(vals)}
+			}
+			for i := range proc.Vars {
+				e = e.bind(m.info.ObjectOf(resolve.Occurrence{Proc: proc, Index: i + 1}), vals[i])
+			}
+			r = nil
+			p = proc.Cont
+		case *asyncpi.Send:
+			vals := make([]Value, len(proc.Vals))
+			for i := range proc.Vals {
+				vals[i] = value(proc, i+1)
+			}
+			return m.send(proc.Chan.Ident(), value(proc, 0), vals, r)
+		default:
+			return asyncpi.UnknownProcessError{Proc: proc}
+		}
+	}
+}
+
+// recv receives a message on the channel ch of the name ident, where r
+// is the replica if the input is its guard.
+func (m *machine) recv(ident string, ch Value, r *runtime.Replica) ([]Value, error) {
+	c, err := channelOf(ident, ch)
+	if err != nil {
+		return nil, err
+	}
+	switch c := c.(type) {
+	case *Chan:
+		var vals []Value
+		if r != nil {
+			vals = r.Recv(c)
+		} else {
+			vals = c.Recv()
+		}
+		return vals, m.step()
+	case hostChan:
+		if c.ch.Type().ChanDir()&reflect.RecvDir == 0 {
+			return nil, &HostError{Name: ident, Msg: "cannot receive from send-only channel"}
+		}
+		vals, err := m.recvHost(ident, c, r)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil {
+			r.Fire()
+		}
+		return vals, nil
+	}
+	return nil, &HostError{Name: ident, Msg: "cannot receive from a service"}
+}
+
+// recvHost receives a message on the Go channel h of the name ident.
+// A process waiting on h is not blocked unless h is closed, as the
+// caller may send on h.
+func (m *machine) recvHost(ident string, h hostChan, r *runtime.Replica) ([]Value, error) {
+	chosen, v, ok := reflect.Select([]reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(m.g.Done())},
+		{Dir: reflect.SelectRecv, Chan: h.ch},
+	})
+	if chosen == 0 {
+		return nil, errStopped
+	}
+	if !ok {
+		// Nothing is ever sent on a new Chan, so the process is
+		// blocked until the Run ends and stops it.
+		blocked := m.g.New(ident)
+		if r != nil {
+			r.Recv(blocked)
+		} else {
+			blocked.Recv()
+		}
+		return nil, errStopped
+	}
+	return h.values(v), m.step()
+}
+
+// send sends the values vals on the channel ch of the name ident, where
+// r is the replica if the output is its guard. An output which is not a
+// guard never blocks. A guard blocks until it is received, so the next
+// replica is only started when the output is needed.
+func (m *machine) send(ident string, ch Value, vals []Value, r *runtime.Replica) error {
+	c, err := channelOf(ident, ch)
+	if err != nil {
+		return err
+	}
+	switch c := c.(type) {
+	case *Chan:
+		if r != nil {
+			r.Send(c, vals...)
+		} else {
+			c.Send(vals...)
+		}
+		return nil
+	case hostChan:
+		if c.ch.Type().ChanDir()&reflect.SendDir == 0 {
+			return &HostError{Name: ident, Msg: "cannot send to receive-only channel"}
+		}
+		msg, err := c.message(ident, vals)
+		if err != nil {
+			return err
+		}
+		if r == nil {
+			m.spawn(func() error { return m.sendHost(c, msg) })
+			return nil
+		}
+		if err := m.sendHost(c, msg); err != nil {
+			return err
+		}
+	case Func:
+		if len(vals) == 0 {
+			return &HostError{Name: ident, Msg: "no reply channel"}
+		}
+		m.spawn(func() error { return m.call(ident, c, vals[:len(vals)-1], vals[len(vals)-1]) })
+	}
+	if r != nil {
+		r.Fire()
+	}
+	return nil
+}
+
+// sendHost sends the message msg on the Go channel h.
+func (m *machine) sendHost(h hostChan, msg reflect.Value) error {
+	chosen, _, _ := reflect.Select([]reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(m.g.Done())},
+		{Dir: reflect.SelectSend, Chan: h.ch, Send: msg},
+	})
+	if chosen == 0 {
+		return errStopped
+	}
+	return m.step()
+}
+
+// call calls the service f of the name ident with args, and sends the
+// results on reply.
+func (m *machine) call(ident string, f Func, args []Value, reply Value) error {
+	if err := m.step(); err != nil {
+		return err
+	}
+	return m.send(ident+" reply", reply, f(args), nil)
+}
@@ -2,7 +2,12 @@ package asyncpi
 
 //go:generate goyacc -p asyncpi -o parser.y.go asyncpi.y
 
-import "io"
+import (
+	"fmt"
+	"io"
+
+	"go.nickng.io/asyncpi/internal/name"
+)
 
 // lexer for asyncpi.
 type lexer struct {
@@ -18,11 +23,51 @@ func newLexer(r io.Reader) *lexer {
 // Lex is provided for yacc-compatible parser.
 func (l *lexer) Lex(yylval *asyncpiSymType) int {
 	var token tok
-	token, yylval.strval, _, _ = l.scanner.Scan()
+	var start TokenPos
+	token, yylval.strval, start, _ = l.scanner.Scan()
+	if token == kNAME {
+		// start is the position before the first character of a name.
+		yylval.pos = Pos{Line: len(start.Lines) + 1, Col: start.Char + 1}
+	}
 	return int(token)
 }
 
+// newName returns a new Name at source position pos.
+func newName(ident string, pos Pos) Name {
+	n := name.New(ident)
+	n.SetPos(pos)
+	return n
+}
+
+// newHintedName returns a new Name with type hint at source position pos.
+func newHintedName(ident, hint string, pos Pos) Name {
+	n := name.NewHinted(ident, hint)
+	n.SetPos(pos)
+	return n
+}
+
 // Error handles error.
+// Only the first error is kept.
 func (l *lexer) Error(err string) {
-	l.Errors <- &ParseError{Err: err, Pos: l.scanner.pos}
+	select {
+	case l.Errors <- &ParseError{Err: err, Pos: l.scanner.pos}:
+	default:
+	}
+}
+
+// Annotations of restricted names.
+const annotLinear = "lin"
+
+// annotateName returns the restricted Name n with annotation annot,
+// e.g. lin in (new lin a).
+func annotateName(n Name, annot string, l asyncpiLexer) Name {
+	switch annot {
+	case annotLinear:
+		if ln, ok := n.(interface{ SetLinear(bool) }); ok {
+			ln.SetLinear(true)
+		}
+	default:
+		l.Error(fmt.Sprintf("unknown annotation %q for %s", annot, n.Ident()))
+	}
+	return n
 }
@@ -0,0 +1,258 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lint reports suspicious constructs in a Process, such as
+// duplicate receive binders or restricted names that are never used.
+//
+// The checks are syntactic and based on the name resolution of package
+// resolve, so a Process does not need to be bound (asyncpi.Bind) first.
+package lint // import "go.nickng.io/asyncpi/lint"
+
+import (
+	"fmt"
+	"sort"
+
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/internal/errors"
+	"go.nickng.io/asyncpi/resolve"
+)
+
+// Severity is the severity of a Diagnostic.
+type Severity int
+
+const (
+	// Info is a Diagnostic which is not necessarily a problem.
+	Info Severity = iota
+	// Warning is a Diagnostic which is likely a mistake.
+	Warning
+	// Error is a Diagnostic which violates the rules of the calculus.
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Names of the checks.
+const (
+	CheckUnbound       = "unbound"
+	CheckDuplicateVars = "duplicate"
+	CheckUnused        = "unused"
+	CheckShadow        = "shadow"
+	CheckNoReceiver    = "noreceiver"
+)
+
+// Diagnostic is a problem found in a Process.
+type Diagnostic struct {
+	Pos      asyncpi.Pos // Position of the Name the problem is found at.
+	Severity Severity
+	Check    string // Name of the check, e.g. CheckUnused.
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s (%s)", d.Pos, d.Severity, d.Message, d.Check)
+}
+
+// Lint runs all checks on Process p and returns the Diagnostics
+// ordered by their position in p.
+func Lint(p asyncpi.Process) ([]Diagnostic, error) {
+	info, err := resolve.Resolve(p)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot lint")
+	}
+	l := &linter{info: info}
+	l.unbound()
+	l.duplicateVars()
+	l.unused()
+	l.shadow()
+	l.noReceiver()
+	sort.SliceStable(l.diags, func(i, j int) bool {
+		return l.order[l.diags[i].occ] < l.order[l.diags[j].occ]
+	})
+	diags := make([]Diagnostic, len(l.diags))
+	for i := range l.diags {
+		diags[i] = l.diags[i].Diagnostic
+	}
+	return diags, nil
+}
+
+// HasErrors returns true if any of the Diagnostics diags is an Error.
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+type linter struct {
+	info  *resolve.Info
+	order map[resolve.Occurrence]int
+	diags []diagnostic
+}
+
+// diagnostic is a Diagnostic with the Occurrence it is found at.
+type diagnostic struct {
+	Diagnostic
+	occ resolve.Occurrence
+}
+
+func (l *linter) report(occ resolve.Occurrence, sev Severity, check, format string, a ...interface{}) {
+	if l.order == nil {
+		l.order = make(map[resolve.Occurrence]int)
+		for i, o := range l.info.Occurrences() {
+			l.order[o] = i
+		}
+	}
+	l.diags = append(l.diags, diagnostic{
+		Diagnostic: Diagnostic{
+			Pos:      asyncpi.NamePos(occ.Name()),
+			Severity: sev,
+			Check:    check,
+			Message:  fmt.Sprintf(format, a...),
+		},
+		occ: occ,
+	})
+}
+
+// unbound reports the free names of the Process.
+//
+// A free name is a Warning if a binder of the same ident exists
+// elsewhere in the Process, i.e. it is likely used out of scope.
+// Otherwise it is only reported if it is used as a channel, as free
+// names which are only sent as values are the parameters of an open
+// Process.
+func (l *linter) unbound() {
+	declared := make(map[string]resolve.Occurrence)
+	for _, o := range l.info.Occurrences() {
+		if obj := l.info.Defs[o]; obj != nil {
+			if _, seen := declared[obj.Ident]; !seen {
+				declared[obj.Ident] = o
+			}
+		}
+	}
+	for _, obj := range l.info.Free.Objects() {
+		uses := l.info.UsesOf(obj)
+		if decl, ok := declared[obj.Ident]; ok {
+			l.report(uses[0], Warning, CheckUnbound, "%s is not bound here but declared at %s",
+				obj.Ident, asyncpi.NamePos(decl.Name()))
+			continue
+		}
+		for _, use := range uses {
+			if use.Index == 0 {
+				l.report(use, Info, CheckUnbound, "%s is a free name", obj.Ident)
+				break
+			}
+		}
+	}
+}
+
+// duplicateVars reports receives with the same variable more than once.
+func (l *linter) duplicateVars() {
+	for _, o := range l.info.Occurrences() {
+		recv, ok := o.Proc.(*asyncpi.Recv)
+		if !ok || o.Index == 0 {
+			continue
+		}
+		for i := 0; i < o.Index-1; i++ {
+			if recv.Vars[i].Ident() == o.Name().Ident() {
+				l.report(o, Error, CheckDuplicateVars, "%s is received more than once in %s(%s)",
+					o.Name().Ident(), recv.Chan.Ident(), idents(recv.Vars))
+				break
+			}
+		}
+	}
+}
+
+// unused reports restricted names that are never used.
+func (l *linter) unused() {
+	for _, o := range l.info.Occurrences() {
+		if obj := l.info.Defs[o]; obj != nil && obj.IsRestricted() && len(l.info.UsesOf(obj)) == 0 {
+			l.report(o, Warning, CheckUnused, "%s is restricted but never used", obj.Ident)
+		}
+	}
+}
+
+// shadow reports binders which hide a binder of an enclosing scope.
+func (l *linter) shadow() {
+	for _, o := range l.info.Occurrences() {
+		obj := l.info.Defs[o]
+		if obj == nil {
+			continue
+		}
+		s, outer := obj.Scope.Parent.LookupParent(obj.Ident)
+		if outer != nil && s != l.info.Free {
+			l.report(o, Warning, CheckShadow, "%s shadows the declaration at %s",
+				obj.Ident, asyncpi.NamePos(outer.Name()))
+		}
+	}
+}
+
+// noReceiver reports sends on names that are never received on.
+//
+// Only restricted names are Warnings: a name sent as a value may be
+// received through a variable, and a free name may be received by the
+// environment. Sends on variables are not reported.
+func (l *linter) noReceiver() {
+	received := make(map[*resolve.Object]bool)
+	escaped := make(map[*resolve.Object]bool)
+	for o, obj := range l.info.Uses {
+		switch o.Proc.(type) {
+		case *asyncpi.Recv:
+			received[obj] = true
+		case *asyncpi.Send:
+			if o.Index > 0 {
+				escaped[obj] = true
+			}
+		}
+	}
+	reported := make(map[*resolve.Object]bool)
+	for _, o := range l.info.Occurrences() {
+		obj := l.info.Uses[o]
+		if _, isSend := o.Proc.(*asyncpi.Send); !isSend || o.Index != 0 || obj.IsVar() {
+			continue
+		}
+		if received[obj] || reported[obj] {
+			continue
+		}
+		reported[obj] = true
+		switch {
+		case obj.IsFree():
+			l.report(o, Info, CheckNoReceiver, "%s is sent on but not received in the process", obj.Ident)
+		case !escaped[obj]:
+			l.report(o, Warning, CheckNoReceiver, "%s is sent on but never received", obj.Ident)
+		}
+	}
+}
+
+func idents(names []asyncpi.Name) string {
+	var s string
+	for i, n := range names {
+		if i > 0 {
+			s += ","
+		}
+		s += n.Ident()
+	}
+	return s
+}
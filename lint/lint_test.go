@@ -0,0 +1,75 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"go.nickng.io/asyncpi"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		Proc  string
+		Diags []string
+	}{
+		{`(new a)(a<> | a().0)`, nil},
+		{`a(x,x).x<>`, []string{
+			"1:1: info: a is a free name (unbound)",
+			"1:5: error: x is received more than once in a(x,x) (duplicate)",
+		}},
+		{`(new a)0`, []string{
+			"1:6: warning: a is restricted but never used (unused)",
+		}},
+		{`(new a)a(a).a().0`, []string{
+			"1:10: warning: a shadows the declaration at 1:6 (shadow)",
+		}},
+		{`(new a)(a<> | a<>)`, []string{
+			"1:9: warning: a is sent on but never received (noreceiver)",
+		}},
+		{`(new a)(a<> | b<a>)`, []string{
+			"1:15: info: b is a free name (unbound)",
+			"1:15: info: b is sent on but not received in the process (noreceiver)",
+		}},
+		// Free names only sent as values are not reported.
+		{`(new a)(a<b,c> | a(x,y).x<y>)`, nil},
+		{`(new a)(a<b> | a(x).x<> | b<>)`, []string{
+			"1:27: info: b is a free name (unbound)",
+			"1:27: info: b is sent on but not received in the process (noreceiver)",
+		}},
+		{`(new c)c(x).0 | x<>`, []string{
+			"1:17: warning: x is not bound here but declared at 1:10 (unbound)",
+			"1:17: info: x is sent on but not received in the process (noreceiver)",
+		}},
+	}
+	for _, test := range tests {
+		p, err := asyncpi.Parse(strings.NewReader(test.Proc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		diags, err := Lint(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, d := range diags {
+			got = append(got, d.String())
+		}
+		if want, got := strings.Join(test.Diags, "\n"), strings.Join(got, "\n"); want != got {
+			t.Errorf("expects diagnostics of %s:\n%s\nbut got\n%s", test.Proc, want, got)
+		}
+	}
+}
+
+func TestHasErrors(t *testing.T) {
+	p, err := asyncpi.Parse(strings.NewReader(`(new a)a(x,x).0`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags, err := Lint(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !HasErrors(diags) {
+		t.Fatalf("expects %s to have errors but got %v", p.Calculi(), diags)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"go.nickng.io/asyncpi"
 	"go.nickng.io/asyncpi/internal/errors"
 	"go.nickng.io/asyncpi/name"
+	"go.nickng.io/asyncpi/resolve"
 )
 
 func errInferSort(err error) error {
@@ -28,52 +29,41 @@ func errInferSort(err error) error {
 }
 
 // InferSortsByUsage puts names in a Process into their respective sort {name,var}.
+// A Name is a var if it is bound by a Recv, otherwise (i.e. restricted or
+// free) it is a name.
 func InferSortsByUsage(p asyncpi.Process) error {
 	if err := upgrade(p); err != nil {
 		return errInferSort(errors.Wrap(err, "cannot upgrade process Names to SortedNames"))
 	}
-	isVarSort := make(map[asyncpi.Name]bool)
-	procs := []asyncpi.Process{p}
-	for len(procs) > 0 {
-		p, procs = procs[0], procs[1:]
-		switch p := p.(type) {
-		case *asyncpi.NilProcess:
-			// nothing to do
-		case *asyncpi.Repeat:
-			procs = append(procs, p.Proc)
-		case *asyncpi.Par:
-			procs = append(procs, p.Procs...)
-		case *asyncpi.Recv:
-			for i := range p.Vars {
-				isVarSort[p.Vars[i]] = true
-				if s, canSetSort := p.Vars[i].(setter); canSetSort {
-					s.SetSort(VarSort)
-				} else {
-					return errInferSort(asyncpi.ImmutableNameError{Name: p.Vars[i]})
-				}
-			}
-			procs = append(procs, p.Cont)
-		case *asyncpi.Send:
-			for i := range p.Vals {
-				if _, ok := isVarSort[p.Vals[i]]; !ok { // if not seen
-					isVarSort[p.Vals[i]] = false
-					if s, canSetSort := p.Vals[i].(setter); canSetSort {
-						s.SetSort(NameSort)
-					} else {
-						return errInferSort(asyncpi.ImmutableNameError{Name: p.Vals[i]})
-					}
-				}
-			}
-		case *asyncpi.Restrict:
-			isVarSort[p.Name] = false // new name = not var
-			procs = append(procs, p.Proc)
-		default:
-			return errInferSort(asyncpi.UnknownProcessError{Proc: p})
+	info, err := resolve.Resolve(p)
+	if err != nil {
+		return errInferSort(err)
+	}
+	for _, o := range info.Occurrences() {
+		s, canSetSort := o.Name().(setter)
+		if !canSetSort {
+			return errInferSort(asyncpi.ImmutableNameError{Name: o.Name()})
+		}
+		if info.ObjectOf(o).IsVar() {
+			s.SetSort(VarSort)
+		} else {
+			s.SetSort(NameSort)
 		}
 	}
 	return nil
 }
 
+// InferSortsByUsageCopy returns a copy of the Process p with
+// names put into their respective sort {name,var}.
+// Unlike InferSortsByUsage, the input Process p is not modified.
+func InferSortsByUsageCopy(p asyncpi.Process) (asyncpi.Process, error) {
+	p = asyncpi.Clone(p)
+	if err := InferSortsByUsage(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
 func InferSortsByPrefix(p asyncpi.Process) error {
 	if err := name.Walk(byPrefix{}, p); err != nil {
 		return errInferSort(err)
@@ -81,6 +71,17 @@ func InferSortsByPrefix(p asyncpi.Process) error {
 	return nil
 }
 
+// InferSortsByPrefixCopy returns a copy of the Process p with
+// names put into their respective sort by their prefix.
+// Unlike InferSortsByPrefix, the input Process p is not modified.
+func InferSortsByPrefixCopy(p asyncpi.Process) (asyncpi.Process, error) {
+	p = asyncpi.Clone(p)
+	if err := InferSortsByPrefix(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
 // byPrefix is a name.Visitor implementation which puts names in sorts.
 // A Name is a name/var depending on its prefix:
 //   names={a,b,c,...} vars={...,x,y,z}
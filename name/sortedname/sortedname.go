@@ -64,6 +64,24 @@ func (n *SortedName) SetSort(s Sorts) {
 	n.s = s
 }
 
+// CloneName returns a copy of the SortedName n using the Cloner c.
+func (n *SortedName) CloneName(c *asyncpi.Cloner) asyncpi.Name {
+	clone := &SortedName{s: n.s}
+	c.Set(n, clone)
+	clone.Name = c.Name(n.Name)
+	return clone
+}
+
+// Pos returns the source position of the wrapped Name.
+func (n *SortedName) Pos() asyncpi.Pos {
+	return asyncpi.NamePos(n.Name)
+}
+
+// IsLinear returns true if the wrapped Name is annotated linear.
+func (n *SortedName) IsLinear() bool {
+	return asyncpi.IsAnnotatedLinear(n.Name)
+}
+
 func (n *SortedName) FreeNames() []asyncpi.Name {
 	if n.s == NameSort {
 		return []asyncpi.Name{n}
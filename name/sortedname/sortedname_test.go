@@ -152,3 +152,37 @@ func TestRecvSort(t *testing.T) {
 			p.Calculi(), expect, got)
 	}
 }
+
+func TestInferSortsByUsageCopy(t *testing.T) {
+	p := asyncpi.NewRecv(constName("u"), asyncpi.NewNilProcess())
+	p.Vars = append(p.Vars, constName("x"))
+	c, err := InferSortsByUsageCopy(p)
+	if err != nil {
+		t.Fatalf("cannot infer sort: %v", err)
+	}
+	if _, ok := p.Vars[0].(*SortedName); ok {
+		t.Errorf("Expecting %s to be unchanged but got %T", p.Calculi(), p.Vars[0])
+	}
+	if expect, got := VarSort, c.(*asyncpi.Recv).Vars[0].(*SortedName).Sort(); expect != got {
+		t.Errorf("Expecting %s sort to be %d but got %d.", c.(*asyncpi.Recv).Vars[0].Ident(), expect, got)
+	}
+}
+
+func TestInferSortsByUsageUnbound(t *testing.T) {
+	// Variables are sorted by their binder, even if Names are not shared.
+	send := asyncpi.NewSend(constName("x"))
+	send.Vals = append(send.Vals, constName("x"))
+	p := asyncpi.NewRecv(constName("u"), send)
+	p.Vars = append(p.Vars, constName("x"))
+	if err := InferSortsByUsage(p); err != nil {
+		t.Fatalf("cannot infer sort: %v", err)
+	}
+	for _, n := range []asyncpi.Name{send.Chan, send.Vals[0]} {
+		if expect, got := VarSort, n.(*SortedName).Sort(); expect != got {
+			t.Errorf("Expecting %s sort to be %d but got %d.", n.Ident(), expect, got)
+		}
+	}
+	if expect, got := NameSort, p.Chan.(*SortedName).Sort(); expect != got {
+		t.Errorf("Expecting %s sort to be %d but got %d.", p.Chan.Ident(), expect, got)
+	}
+}
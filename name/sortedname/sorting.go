@@ -0,0 +1,286 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sortedname
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/resolve"
+)
+
+// Sorting.
+// This file contains sort inference in the style of Milner's sortings,
+// where the sort of a channel is the list of sorts of its payloads, e.g.
+//
+//     a<b> | a(x).x<> ⊢ a: ch(ch()), b: ch()
+//
+// Sorts are inferred by unification, and may be recursive, e.g.
+//
+//     a<a> ⊢ a: μs0.ch(s0)
+
+// sortVar is a sort variable in a union-find structure.
+type sortVar struct {
+	parent *sortVar
+	args   []*sortVar  // Payload sorts, valid if known.
+	known  bool        // Whether the arity of the sort is known.
+	pos    asyncpi.Pos // Position where the arity is first known.
+}
+
+func (s *sortVar) find() *sortVar {
+	for s.parent != nil {
+		if s.parent.parent != nil {
+			s.parent = s.parent.parent
+		}
+		s = s.parent
+	}
+	return s
+}
+
+// Sorting is the result of sort inference of a Process.
+type Sorting struct {
+	// Conflicts are the arity conflicts found during inference,
+	// in the order they are found.
+	Conflicts []ArityConflict
+
+	info  *resolve.Info
+	objs  []*resolve.Object // in order of first occurrence.
+	sorts map[*resolve.Object]*sortVar
+	names map[*sortVar]int // Printed names of sort variables.
+}
+
+// ArityConflict is a channel used with different payload arities.
+type ArityConflict struct {
+	Name      string      // Name of the channel.
+	Pos       asyncpi.Pos // Position of the conflicting use.
+	Arity     int         // Arity of the conflicting use.
+	PrevPos   asyncpi.Pos // Position where the arity is first inferred.
+	PrevArity int         // Previously inferred arity.
+}
+
+func (c ArityConflict) String() string {
+	return fmt.Sprintf("%s: %s has arity %d but arity %d is inferred at %s",
+		c.Pos, c.Name, c.Arity, c.PrevArity, c.PrevPos)
+}
+
+// SortingError is the type of error when sort inference
+// finds arity conflicts.
+type SortingError struct {
+	Conflicts []ArityConflict
+}
+
+func (e *SortingError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d arity conflict(s)", len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		fmt.Fprintf(&buf, "\n\t%s", c)
+	}
+	return buf.String()
+}
+
+// InferSorting infers the sorting of Process p.
+//
+// All arity conflicts are collected in the returned Sorting,
+// and returned as a *SortingError.
+func InferSorting(p asyncpi.Process) (*Sorting, error) {
+	info, err := resolve.Resolve(p)
+	if err != nil {
+		return nil, errInferSort(err)
+	}
+	s := &Sorting{
+		info:  info,
+		sorts: make(map[*resolve.Object]*sortVar),
+		names: make(map[*sortVar]int),
+	}
+	for _, o := range info.Occurrences() {
+		s.sortOf(info.ObjectOf(o))
+	}
+	for _, o := range info.Occurrences() {
+		if o.Index != 0 || o.IsBinder() {
+			continue
+		}
+		var payload []asyncpi.Name
+		switch p := o.Proc.(type) {
+		case *asyncpi.Send:
+			payload = p.Vals
+		case *asyncpi.Recv:
+			payload = p.Vars
+		}
+		ch := s.newSort()
+		ch.known = true
+		ch.pos = asyncpi.NamePos(o.Name())
+		for i := range payload {
+			ch.args = append(ch.args, s.sortOf(info.ObjectOf(resolve.Occurrence{Proc: o.Proc, Index: i + 1})))
+		}
+		s.unify(o.Name().Ident(), s.sortOf(info.ObjectOf(o)), ch)
+	}
+	if len(s.Conflicts) > 0 {
+		return s, errInferSort(&SortingError{Conflicts: s.Conflicts})
+	}
+	return s, nil
+}
+
+func (s *Sorting) newSort() *sortVar {
+	return &sortVar{}
+}
+
+// varName returns the printed name of the sort variable srt.
+// Sort variables are numbered in the order they are printed.
+func (s *Sorting) varName(srt *sortVar) string {
+	if _, ok := s.names[srt]; !ok {
+		s.names[srt] = len(s.names)
+	}
+	return fmt.Sprintf("s%d", s.names[srt])
+}
+
+func (s *Sorting) sortOf(obj *resolve.Object) *sortVar {
+	if srt, ok := s.sorts[obj]; ok {
+		return srt
+	}
+	s.objs = append(s.objs, obj)
+	s.sorts[obj] = s.newSort()
+	return s.sorts[obj]
+}
+
+// unify unifies the sorts x and y of the channel named ident,
+// recording arity conflicts.
+func (s *Sorting) unify(ident string, x, y *sortVar) {
+	type pair struct{ x, y *sortVar }
+	work := []pair{{x, y}}
+	for len(work) > 0 {
+		x, y := work[0].x.find(), work[0].y.find()
+		work = work[1:]
+		switch {
+		case x == y:
+		case !y.known:
+			y.parent = x
+		case !x.known:
+			x.parent = y
+		case len(x.args) != len(y.args):
+			s.Conflicts = append(s.Conflicts, ArityConflict{
+				Name:      ident,
+				Pos:       y.pos,
+				Arity:     len(y.args),
+				PrevPos:   x.pos,
+				PrevArity: len(x.args),
+			})
+		default:
+			y.parent = x
+			for i := range x.args {
+				work = append(work, pair{x.args[i], y.args[i]})
+			}
+		}
+	}
+}
+
+// Arity returns the inferred payload arity of the Name at Occurrence o,
+// and false if the sort of the Name is unconstrained.
+func (s *Sorting) Arity(o resolve.Occurrence) (int, bool) {
+	obj := s.info.ObjectOf(o)
+	if obj == nil {
+		return 0, false
+	}
+	srt := s.sortOf(obj).find()
+	return len(srt.args), srt.known
+}
+
+// SortOf returns the inferred sort of the Name at Occurrence o
+// as a string, or the empty string if o is not in the Process.
+func (s *Sorting) SortOf(o resolve.Occurrence) string {
+	obj := s.info.ObjectOf(o)
+	if obj == nil {
+		return ""
+	}
+	return s.sortString(s.sortOf(obj))
+}
+
+// sortString returns the sort srt as a string. Recursive sorts are
+// written with μ binders, and unconstrained sorts are written as
+// sort variables.
+func (s *Sorting) sortString(srt *sortVar) string {
+	var buf bytes.Buffer
+	var visit func(srt *sortVar, visiting []*sortVar)
+	visit = func(srt *sortVar, visiting []*sortVar) {
+		srt = srt.find()
+		if !srt.known {
+			buf.WriteString(s.varName(srt))
+			return
+		}
+		for _, v := range visiting {
+			if v == srt {
+				buf.WriteString(s.varName(srt))
+				return
+			}
+		}
+		recursive := s.occurs(srt, srt)
+		if recursive {
+			fmt.Fprintf(&buf, "μ%s.", s.varName(srt))
+		}
+		buf.WriteString("ch(")
+		for i, arg := range srt.args {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			visit(arg, append(visiting, srt))
+		}
+		buf.WriteString(")")
+	}
+	visit(srt, nil)
+	return buf.String()
+}
+
+// occurs returns true if sort x occurs in the payload sorts of y.
+func (s *Sorting) occurs(x, y *sortVar) bool {
+	seen := make(map[*sortVar]bool)
+	work := append([]*sortVar(nil), y.find().args...)
+	for len(work) > 0 {
+		srt := work[0].find()
+		work = work[1:]
+		if srt == x {
+			return true
+		}
+		if seen[srt] {
+			continue
+		}
+		seen[srt] = true
+		work = append(work, srt.args...)
+	}
+	return false
+}
+
+// String returns the inferred sorting, one name per line
+// in order of first occurrence.
+// Names declared more than once are qualified by their position.
+func (s *Sorting) String() string {
+	count := make(map[string]int)
+	for _, obj := range s.objs {
+		count[obj.Ident]++
+	}
+	var lines []string
+	for _, obj := range s.objs {
+		ident := obj.Ident
+		if count[ident] > 1 {
+			pos := asyncpi.NamePos(obj.Name())
+			if obj.IsFree() {
+				pos = asyncpi.NamePos(s.info.UsesOf(obj)[0].Name())
+			}
+			ident = fmt.Sprintf("%s@%s", ident, pos)
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", ident, s.sortString(s.sorts[obj])))
+	}
+	return strings.Join(lines, "\n")
+}
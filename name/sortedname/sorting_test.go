@@ -0,0 +1,56 @@
+package sortedname
+
+import (
+	"strings"
+	"testing"
+
+	"go.nickng.io/asyncpi"
+)
+
+func TestInferSorting(t *testing.T) {
+	tests := []struct {
+		Proc    string
+		Sorting string
+	}{
+		{`a<b> | a(x).x<>`, "a: ch(ch())\nb: ch()\nx: ch()"},
+		{`(new a)a<a>`, "a: μs0.ch(s0)"},
+		{`a<b,c>`, "a: ch(s0,s1)\nb: s0\nc: s1"},
+		{`a(x).x<> | (new x)x().0`, "a: ch(ch())\nx@1:3: ch()\nx@1:17: ch()"},
+		{`a(x).a<x>`, "a: ch(s0)\nx: s0"},
+	}
+	for _, test := range tests {
+		p, err := asyncpi.Parse(strings.NewReader(test.Proc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := InferSorting(p)
+		if err != nil {
+			t.Fatalf("cannot infer sorting of %s: %v", test.Proc, err)
+		}
+		if want, got := test.Sorting, s.String(); want != got {
+			t.Errorf("expects sorting of %s:\n%s\nbut got\n%s", test.Proc, want, got)
+		}
+	}
+}
+
+func TestInferSortingConflicts(t *testing.T) {
+	p, err := asyncpi.Parse(strings.NewReader(`a<b> | a(x,y).0 | a(z).z<c> | b().0`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := InferSorting(p)
+	if err == nil {
+		t.Fatalf("expects arity conflicts but got sorting:\n%s", s)
+	}
+	want := []string{
+		"1:8: a has arity 2 but arity 1 is inferred at 1:1",
+		"1:31: b has arity 0 but arity 1 is inferred at 1:24",
+	}
+	var got []string
+	for _, c := range s.Conflicts {
+		got = append(got, c.String())
+	}
+	if want, got := strings.Join(want, "\n"), strings.Join(got, "\n"); want != got {
+		t.Fatalf("expects conflicts:\n%s\nbut got\n%s", want, got)
+	}
+}
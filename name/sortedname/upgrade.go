@@ -20,16 +20,14 @@ import (
 
 // Upgrade wraps all Names in the Process p into SortedNames.
 func upgrade(p asyncpi.Process) error {
-	procs := []asyncpi.Process{p}
-	for len(procs) > 0 {
-		p, procs = procs[0], procs[1:]
+	var err error
+	asyncpi.Inspect(p, func(p asyncpi.Process) bool {
+		if err != nil {
+			return false
+		}
 		switch p := p.(type) {
-		case *asyncpi.NilProcess:
+		case nil, *asyncpi.NilProcess, *asyncpi.Repeat, *asyncpi.Par:
 			// nothing to do
-		case *asyncpi.Repeat:
-			procs = append(procs, p.Proc)
-		case *asyncpi.Par:
-			procs = append(procs, p.Procs...)
 		case *asyncpi.Recv:
 			p.Chan = New(p.Chan)
 			var vars []asyncpi.Name
@@ -37,7 +35,6 @@ func upgrade(p asyncpi.Process) error {
 				vars = append(vars, New(p.Vars[i]))
 			}
 			p.Vars = vars
-			procs = append(procs, p.Cont)
 		case *asyncpi.Send:
 			p.Chan = New(p.Chan)
 			var vals []asyncpi.Name
@@ -47,10 +44,11 @@ func upgrade(p asyncpi.Process) error {
 			p.Vals = vals
 		case *asyncpi.Restrict:
 			p.Name = New(p.Name)
-			procs = append(procs, p.Proc)
 		default:
-			return asyncpi.UnknownProcessError{Proc: p}
+			err = asyncpi.UnknownProcessError{Proc: p}
+			return false
 		}
-	}
-	return nil
+		return true
+	})
+	return err
 }
@@ -27,6 +27,16 @@ func MakeNamesUnique(p asyncpi.Process) error {
 	return nil
 }
 
+// MakeNamesUniqueCopy returns a copy of the Process p with unique names.
+// Unlike MakeNamesUnique, the input Process p is not modified.
+func MakeNamesUniqueCopy(p asyncpi.Process) (asyncpi.Process, error) {
+	p = asyncpi.Clone(p)
+	if err := MakeNamesUnique(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
 type uniqueNamer struct {
 	names map[asyncpi.Name]string
 }
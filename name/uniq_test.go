@@ -61,3 +61,23 @@ func TestUpdateNamePar(t *testing.T) {
 		t.Fatalf("Expecting %d unique free vars, but got %d: %s", expect, got, proc.Calculi())
 	}
 }
+
+func TestUpdateNameRestrict(t *testing.T) {
+	input := `(new a)(a<x> | a(y).0)`
+	proc, err := asyncpi.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := asyncpi.Bind(&proc); err != nil {
+		t.Fatal(err)
+	}
+	if err := MakeNamesUnique(proc); err != nil {
+		t.Fatalf("cannot update name: %v", err)
+	}
+	if expect, got := 1, len(proc.FreeNames()); expect != got {
+		t.Fatalf("Expecting %d unique free names, but got %d: %s", expect, got, proc.Calculi())
+	}
+	if got := proc.(*asyncpi.Restrict).Proc.(*asyncpi.Par).Procs[0].(*asyncpi.Send).Vals[0].Ident(); got == "x" {
+		t.Fatalf("Expecting names under restriction to be unique, but got %s", proc.Calculi())
+	}
+}
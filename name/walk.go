@@ -23,42 +23,46 @@ type Visitor interface {
 
 // Walk traverses a Process p in breadth-first order,
 // and applies v.VisitName(n) on each Name n encountered.
+// The Names of a Process are visited in syntactic order, before the
+// Names of its child Processes (see asyncpi.Inspect).
 func Walk(v Visitor, proc asyncpi.Process) error {
 	procs := []asyncpi.Process{proc}
 	for len(procs) > 0 {
 		proc, procs = procs[0], procs[1:]
+		var err error
 		switch p := proc.(type) {
-		case *asyncpi.NilProcess:
-			// finish
-		case *asyncpi.Repeat:
-			procs = append(procs, p.Proc)
-		case *asyncpi.Par:
-			procs = append(procs, p.Procs...)
+		case *asyncpi.NilProcess, *asyncpi.Repeat, *asyncpi.Par:
 		case *asyncpi.Recv:
-			if err := v.VisitName(p.Chan); err != nil {
-				return err
-			}
-			for i := range p.Vars {
-				if err := v.VisitName(p.Vars[i]); err != nil {
-					return err
-				}
-			}
-			procs = append(procs, p.Cont)
+			err = visitNames(v, append([]asyncpi.Name{p.Chan}, p.Vars...))
 		case *asyncpi.Send:
-			if err := v.VisitName(p.Chan); err != nil {
-				return err
-			}
-			for i := range p.Vals {
-				if err := v.VisitName(p.Vals[i]); err != nil {
-					return err
-				}
-			}
+			err = visitNames(v, append([]asyncpi.Name{p.Chan}, p.Vals...))
 		case *asyncpi.Restrict:
-			if err := v.VisitName(p.Name); err != nil {
-				return err
-			}
+			err = v.VisitName(p.Name)
 		default:
-			return asyncpi.UnknownProcessError{Proc: p}
+			err = asyncpi.UnknownProcessError{Proc: p}
+		}
+		if err != nil {
+			return err
+		}
+		root := true
+		asyncpi.Inspect(proc, func(p asyncpi.Process) bool {
+			if root {
+				root = false
+				return true
+			}
+			if p != nil {
+				procs = append(procs, p)
+			}
+			return false
+		})
+	}
+	return nil
+}
+
+func visitNames(v Visitor, names []asyncpi.Name) error {
+	for i := range names {
+		if err := v.VisitName(names[i]); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -0,0 +1,32 @@
+package name
+
+import (
+	"strings"
+	"testing"
+
+	"go.nickng.io/asyncpi"
+)
+
+type nameRecorder []string
+
+func (r *nameRecorder) VisitName(n asyncpi.Name) error {
+	*r = append(*r, n.Ident())
+	return nil
+}
+
+// Names are visited in breadth-first order of the Processes.
+func TestWalkOrder(t *testing.T) {
+	p, err := asyncpi.Parse(strings.NewReader(`a(x).x(y).y<> | (new z)(z<a> | !b().c<>)`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got nameRecorder
+	if err := Walk(&got, p); err != nil {
+		t.Fatal(err)
+	}
+	// Depth-first order would be a x x y y z z a b c.
+	want := []string{"a", "x", "z", "x", "y", "y", "z", "a", "b", "c"}
+	if strings.Join(want, " ") != strings.Join(got, " ") {
+		t.Errorf("expects names %v but got %v", want, got)
+	}
+}
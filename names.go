@@ -14,6 +14,35 @@ func newNames(names ...string) []Name {
 	return pn
 }
 
+// Pos is a position (line and column) in the source input.
+// The zero Pos is not a valid position.
+type Pos = name.Pos
+
+// NamePos returns the source position of a Name n,
+// or the zero Pos if n does not have a position,
+// e.g. Names not created by the parser.
+//
+// Name implementations which wrap other Names should provide
+// a Pos() Pos method to expose the position of the wrapped Name.
+func NamePos(n Name) Pos {
+	if p, ok := n.(name.Positioner); ok {
+		return p.Pos()
+	}
+	return Pos{}
+}
+
+// IsAnnotatedLinear returns true if a Name n is annotated linear,
+// i.e. declared as (new lin n).
+//
+// Name implementations which wrap other Names should provide
+// an IsLinear() bool method to expose the annotation of the wrapped Name.
+func IsAnnotatedLinear(n Name) bool {
+	if l, ok := n.(name.LinearAnnotated); ok {
+		return l.IsLinear()
+	}
+	return false
+}
+
 // freeNameser is an interface which Name should
 // provide to have custom FreeNames implementation.
 type freeNameser interface {
@@ -13,50 +13,49 @@ func errSubst(err error) error {
 //
 // The caller must ensure that the size of xs and vs are the same
 // such that xs[i] is substituted by vs[i] in 0 <= i < len(xs).
+//
+// Deprecated: Subst modifies the Names in p in place and does not avoid
+// capturing of names by binders. Use Substitute instead.
 func Subst(p Process, vs, xs []Name) error {
 	if len(xs) != len(vs) {
 		return errSubst(ErrInvalid)
 	}
-	procs := []Process{p}
-	for len(procs) > 0 {
-		p, procs = procs[0], procs[1:]
+	var err error
+	setName := func(n Name, ident string) {
+		if ch, canSetName := n.(name.Setter); canSetName {
+			ch.SetName(ident)
+		}
+	}
+	Inspect(p, func(p Process) bool {
+		if err != nil {
+			return false
+		}
 		switch p := p.(type) {
-		case *NilProcess:
-		case *Par:
-			procs = append(procs, p.Procs...)
+		case nil, *NilProcess, *Par, *Restrict, *Repeat:
 		case *Recv:
 			for i, x := range xs {
 				if IsSameName(p.Chan, x) {
-					if ch, canSetName := p.Chan.(name.Setter); canSetName {
-						ch.SetName(vs[i].Ident())
-					}
+					setName(p.Chan, vs[i].Ident())
 				}
 				for _, rv := range p.Vars {
 					if IsSameName(rv, x) {
-						if ch, canSetName := rv.(name.Setter); canSetName {
-							ch.SetName(vs[i].Ident())
-						}
+						setName(rv, vs[i].Ident())
 					}
 				}
 			}
-			procs = append(procs, p.Cont)
-		case *Restrict:
-			procs = append(procs, p.Proc)
-		case *Repeat:
-			procs = append(procs, p.Proc)
 		case *Send:
 			for i, x := range xs {
 				if IsSameName(p.Chan, x) {
-					if ch, canSetName := p.Chan.(name.Setter); canSetName {
-						ch.SetName(vs[i].Ident())
-					}
+					setName(p.Chan, vs[i].Ident())
 				}
 			}
 		default:
-			return errSubst(UnknownProcessError{Proc: p})
+			err = errSubst(UnknownProcessError{Proc: p})
+			return false
 		}
-	}
-	return nil
+		return true
+	})
+	return err
 }
 
 // Reduce1 performs a single step of reduction for Process p.
@@ -111,10 +110,14 @@ func reduceOnce(p Process) (changed bool, err error) {
 			if r, hasSharedChan := recvs[ch]; hasSharedChan {
 				recv := (*r).(*Recv)
 				send := (*s).(*Send)
-				if err := Subst(recv.Cont, send.Vals, recv.Vars); err != nil {
-					return false, err
+				if len(recv.Vars) != len(send.Vals) {
+					return false, errSubst(ErrInvalid)
+				}
+				sub := make(map[Name]Name, len(recv.Vars))
+				for i := range recv.Vars {
+					sub[recv.Vars[i]] = send.Vals[i]
 				}
-				*s, *r = NewNilProcess(), recv.Cont
+				*s, *r = NewNilProcess(), Substitute(recv.Cont, sub)
 				return true, nil
 			}
 		}
@@ -138,207 +141,18 @@ func errSimplify(err error) error {
 
 // SimplifyBySC simplifies a Process p by structural congruence rules.
 //
-// It applies functions to (1) remove unnecessary Restrict,
-// and (2) remove superfluous inact.
+// It returns the normal form of p (see Canonical) but keeps the original
+// bound names where possible, i.e. it (1) flattens parallel compositions
+// and removes superfluous inact, (2) moves Restrict to their narrowest
+// scope and removes unnecessary Restrict, and (3) sorts the parallel
+// components. Restricted names are renamed only when they clash after
+// moving their scope.
 //
+// The input Process p is not modified.
 func SimplifyBySC(p Process) (Process, error) {
-	unwanted, err := findUnusedRestrict(p)
-	if err != nil {
-		return nil, errSimplify(errors.Wrap(err, "cannot find unused restricts"))
-	}
-	p, err = filterRestrict(p, unwanted)
+	n, err := normalise(p, false)
 	if err != nil {
-		return nil, errSimplify(errors.Wrap(err, "cannot filter unused restricts"))
-	}
-	p, err = filterNilProcess(p)
-	if err != nil {
-		return nil, errSimplify(errors.Wrap(err, "cannot filter nil processes"))
-	}
-	return p, nil
-}
-
-// findUnusedRestrict returns a slice of unused Names
-// introduced by a Restrict in Process p.
-//
-// More accurately, this function returns all x in the form of:
-//
-//     (νx)P where x ∉ fn(P)
-//
-// Such that the unused Names and the Restrict that
-// introduced them can be removed.
-func findUnusedRestrict(p Process) (unused []Name, err error) {
-	if err := Bind(&p); err != nil {
-		return nil, err
-	}
-	type rescount struct {
-		ResName Name
-		Count   int
-	}
-	resUses := make(map[string]*rescount)
-	procs := []Process{p}
-	for len(procs) > 0 {
-		p, procs = procs[0], procs[1:]
-		switch p := p.(type) {
-		case *NilProcess:
-		case *Par:
-			procs = append(procs, p.Procs...)
-		case *Recv:
-			if rc, exists := resUses[p.Chan.Ident()]; exists {
-				rc.Count++
-			}
-			for _, v := range p.Vars {
-				if rc, exists := resUses[v.Ident()]; exists {
-					rc.Count++
-				}
-			}
-			procs = append(procs, p.Cont)
-		case *Repeat:
-			procs = append(procs, p.Proc)
-		case *Restrict:
-			resUses[p.Name.Ident()] = &rescount{ResName: p.Name, Count: 1}
-			procs = append(procs, p.Proc)
-		case *Send:
-			if rc, exists := resUses[p.Chan.Ident()]; exists {
-				rc.Count++
-			}
-			for _, v := range p.Vals {
-				if rc, exists := resUses[v.Ident()]; exists {
-					rc.Count++
-
-				}
-			}
-		default:
-			return nil, UnknownProcessError{Proc: p}
-		}
-	}
-	for _, rc := range resUses {
-		if rc.Count == 1 {
-			unused = append(unused, rc.ResName)
-		}
-	}
-	return unused, nil
-}
-
-// filterRestrict returns a Process where all unwanted Names
-// introduced by Restrict are removed from the input Process p.
-//
-// More accurately, this function returns P given a slice of x:
-//
-//     (νx)P where x ∉ fn(P)
-//
-// See also findUnusedRestrict(Process) function for creating the
-// unwanted Name slice.
-func filterRestrict(p Process, unwanted []Name) (Process, error) {
-	switch p := p.(type) {
-	case *NilProcess:
-		return p, nil
-	case *Par:
-		var procs []Process
-		for _, proc := range p.Procs {
-			res, err := filterRestrict(proc, unwanted)
-			if err != nil {
-				return nil, err
-			}
-			procs = append(procs, res)
-		}
-		p.Procs = procs
-		return p, nil
-	case *Recv:
-		var err error
-		p.Cont, err = filterRestrict(p.Cont, unwanted)
-		if err != nil {
-			return nil, err
-		}
-		return p, nil
-	case *Repeat:
-		var err error
-		p.Proc, err = filterRestrict(p.Proc, unwanted)
-		if err != nil {
-			return nil, err
-		}
-		return p, nil
-	case *Restrict:
-		var err error
-		p.Proc, err = filterRestrict(p.Proc, unwanted)
-		if err != nil {
-			return nil, err
-		}
-		for _, n := range unwanted {
-			if n == p.Name { // note: pointer compare
-				return p.Proc, nil
-			}
-		}
-		return p, nil
-	case *Send:
-		return p, nil
-	default:
-		return nil, UnknownProcessError{Proc: p}
-	}
-}
-
-// filterNilProcess returns a Process where superfluous NilProcess
-// that does not end a Process are removed from the input Process p.
-//
-// More accurately, this function removes 0 or collapses Process involving 0:
-//
-//     (P|0) → P
-//     !0 → 0
-//
-// NilProcess at the end of Processes are unchanged.
-func filterNilProcess(p Process) (Process, error) {
-	switch p := p.(type) {
-	case *NilProcess:
-		return p, nil
-	case *Par:
-		var procs []Process
-		for _, proc := range p.Procs {
-			proc, err := filterNilProcess(proc)
-			if err != nil {
-				return nil, err
-			}
-			if _, isEmpty := proc.(*NilProcess); !isEmpty {
-				procs = append(procs, proc)
-			}
-		}
-		switch len(procs) {
-		case 0:
-			return NewNilProcess(), nil
-		case 1:
-			return procs[0], nil
-		default:
-			p.Procs = procs
-			return p, nil
-		}
-	case *Recv:
-		var err error
-		p.Cont, err = filterNilProcess(p.Cont)
-		if err != nil {
-			return nil, err
-		}
-		return p, nil
-	case *Repeat:
-		var err error
-		p.Proc, err = filterNilProcess(p.Proc)
-		if err != nil {
-			return nil, err
-		}
-		if _, isEmpty := p.Proc.(*NilProcess); isEmpty {
-			return p.Proc, nil
-		}
-		return p, nil
-	case *Restrict:
-		var err error
-		p.Proc, err = filterNilProcess(p.Proc)
-		if err != nil {
-			return nil, err
-		}
-		if _, isEmpty := p.Proc.(*NilProcess); isEmpty {
-			return p.Proc, nil
-		}
-		return p, nil
-	case *Send:
-		return p, nil
-	default:
-		return nil, UnknownProcessError{Proc: p}
+		return nil, errSimplify(err)
 	}
+	return n.named, nil
 }
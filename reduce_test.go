@@ -5,6 +5,23 @@ import (
 	"testing"
 )
 
+// expectCongruent fails the test t if Process p is not structurally
+// congruent to the Process want.
+func expectCongruent(t *testing.T, p Process, want string) {
+	t.Helper()
+	q, err := Parse(strings.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	congruent, err := Congruent(p, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !congruent {
+		t.Fatalf("expects process congruent to %s but got %s", want, p.Calculi())
+	}
+}
+
 // Tests reduction of (send | recv)
 func TestReduceSendRecv(t *testing.T) {
 	const proc = `(new a)(a<b> | a(x).x().0)`
@@ -17,22 +34,8 @@ func TestReduceSendRecv(t *testing.T) {
 	} else if !changed {
 		t.Fatalf("expects %s to reduce but unchanged", p.Calculi())
 	}
-	p, err = SimplifyBySC(p)
-	if err != nil {
-		t.Fatalf("cannot simplify process: %v", err)
-	}
 	t.Logf("%s reduces to %s", proc, p.Calculi())
-	p2, ok := p.(*Recv)
-	if !ok {
-		t.Fatalf("expects *Recv but got %s (type %T)", p.Calculi(), p)
-	}
-	if want, got := "b", p2.Chan.Ident(); want != got {
-		t.Fatalf("expects receive on %s but got %s", want, got)
-	}
-	_, ok = p2.Cont.(*NilProcess)
-	if !ok {
-		t.Fatalf("expects *NilProcess but got %s (type %T)", p2.Cont.Calculi(), p2.Cont)
-	}
+	expectCongruent(t, p, `b().0`)
 }
 
 // Test reduction of (recv | send)
@@ -47,18 +50,8 @@ func TestReduceRecvSend(t *testing.T) {
 	} else if !changed {
 		t.Fatalf("expects %s to reduce but unchanged", p.Calculi())
 	}
-	p, err = SimplifyBySC(p)
-	if err != nil {
-		t.Fatalf("cannot simplify process: %v", err)
-	}
 	t.Logf("%s reduces to %s", proc, p.Calculi())
-	p2, ok := p.(*Send)
-	if !ok {
-		t.Fatalf("expects *Send but got %s (type %T)", p.Calculi(), p)
-	}
-	if want, got := "b", p2.Chan.Ident(); want != got {
-		t.Fatalf("expects send on %s but got %s", want, got)
-	}
+	expectCongruent(t, p, `b<>`)
 }
 
 // Test reduction (and simplify) where all names are bound.
@@ -78,20 +71,7 @@ func TestReduceBoundRecvSend(t *testing.T) {
 		t.Fatalf("cannot simplify process: %v", err)
 	}
 	t.Logf("%s reduces to %s", proc, p.Calculi())
-	p2, ok := p.(*Restrict)
-	if !ok {
-		t.Fatalf("expects a *Restrict but got %s (type %T)", p.Calculi(), p)
-	}
-	if want, got := "b", p2.Name.Ident(); want != got {
-		t.Fatalf("expects restrict on %s but got %s", want, got)
-	}
-	p3, ok := p2.Proc.(*Send)
-	if !ok {
-		t.Fatalf("expects *Send but got %s (type %T)", p2.Calculi(), p2)
-	}
-	if want, got := "b", p3.Chan.Ident(); want != got {
-		t.Fatalf("expects send on %s but got %s", want, got)
-	}
+	expectCongruent(t, p, `(new b)b<>`)
 }
 
 func TestReduceFreeSendRecv(t *testing.T) {
@@ -106,6 +86,7 @@ func TestReduceFreeSendRecv(t *testing.T) {
 		t.Fatalf("expects %s to reduce but unchanged", p.Calculi())
 	}
 	t.Logf("%s reduces to %s", proc, p.Calculi())
+	expectCongruent(t, p, `0`)
 }
 
 func TestReduceNone(t *testing.T) {
@@ -120,6 +101,7 @@ func TestReduceNone(t *testing.T) {
 		t.Fatalf("expects %s to not reduce but reduced to %s", proc, p.Calculi())
 	}
 	t.Logf("%s reduces to %s (no change)", proc, p.Calculi())
+	expectCongruent(t, p, proc)
 }
 
 func TestReduceBoundRecvRecv(t *testing.T) {
@@ -134,6 +116,7 @@ func TestReduceBoundRecvRecv(t *testing.T) {
 		t.Fatalf("expects %s to not reduce but reduced to %s", proc, p.Calculi())
 	}
 	t.Logf("%s reduces to %s (no change)", proc, p.Calculi())
+	expectCongruent(t, p, proc)
 }
 
 // Non-shared name cannot reduce.
@@ -154,6 +137,7 @@ func TestReduceFreeRecvRecv(t *testing.T) {
 		t.Fatalf("expects %s to not reduce but reduced to %s", proc, p.Calculi())
 	}
 	t.Logf("%s reduces to %s (no change)", proc, p.Calculi())
+	expectCongruent(t, p, proc)
 }
 
 func TestReduceMultiple(t *testing.T) {
@@ -162,39 +146,23 @@ func TestReduceMultiple(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if changed, err := reduceOnce(p); err != nil {
-		t.Fatalf("cannot reduce: %v", err)
-	} else if !changed {
-		t.Fatalf("expects %s to reduce but unchanged", p.Calculi())
-	}
-	p, err = SimplifyBySC(p)
-	if err != nil {
-		t.Fatalf("cannot simplify process: %v", err)
-	}
-	t.Logf("%s reduces to %s", proc, p.Calculi())
-	procPrev := p.Calculi()
-	if changed, err := reduceOnce(p); err != nil {
-		t.Fatalf("cannot reduce: %v", err)
-	} else if !changed {
-		t.Fatalf("expects %s to reduce but unchanged", p.Calculi())
-	}
-	p, err = SimplifyBySC(p)
-	if err != nil {
-		t.Fatalf("cannot simplify process: %v", err)
-	}
-	t.Logf("%s reduces to %s", procPrev, p.Calculi())
-	procPrev = p.Calculi()
-	if changed, err := reduceOnce(p); err != nil {
-		t.Fatalf("cannot reduce: %v", err)
-	} else if !changed {
-		t.Fatalf("expects %s to reduce but unchanged", p.Calculi())
-	}
-	p, err = SimplifyBySC(p)
-	if err != nil {
-		t.Fatalf("cannot simplify process: %v", err)
-	}
-	t.Logf("%s reduces to %s", procPrev, p.Calculi())
-	if _, ok := p.(*NilProcess); !ok {
-		t.Fatalf("expects *NilProcess but got %s (type %T)", p.Calculi(), p)
+	steps := []string{
+		`b(z).z<c> | b<d> | d(z).0`,
+		`d<c> | d(z).0`,
+		`0`,
+	}
+	for _, want := range steps {
+		procPrev := p.Calculi()
+		if changed, err := reduceOnce(p); err != nil {
+			t.Fatalf("cannot reduce: %v", err)
+		} else if !changed {
+			t.Fatalf("expects %s to reduce but unchanged", p.Calculi())
+		}
+		p, err = SimplifyBySC(p)
+		if err != nil {
+			t.Fatalf("cannot simplify process: %v", err)
+		}
+		t.Logf("%s reduces to %s", procPrev, p.Calculi())
+		expectCongruent(t, p, want)
 	}
 }
@@ -0,0 +1,292 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package resolve links every occurrence of a Name in a Process to its
+// binder, similar to the Info of go/types.
+//
+// Unlike asyncpi.Bind, the result of Resolve is a table that does not
+// modify the Process or rely on sharing of Name values, so it remains
+// valid after a Process is cloned or if Names are compared by identity.
+package resolve // import "go.nickng.io/asyncpi/resolve"
+
+import (
+	"sort"
+
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/internal/errors"
+)
+
+// An Occurrence is a position of a Name in a Process.
+//
+// Index 0 is the channel of a Send or a Recv, or the name of a Restrict.
+// Index i > 0 is the (i-1)-th value of a Send, or the (i-1)-th variable
+// of a Recv.
+type Occurrence struct {
+	Proc  asyncpi.Process // *Send, *Recv or *Restrict.
+	Index int
+}
+
+// Name returns the Name at the Occurrence o.
+func (o Occurrence) Name() asyncpi.Name {
+	switch p := o.Proc.(type) {
+	case *asyncpi.Send:
+		if o.Index == 0 {
+			return p.Chan
+		}
+		return p.Vals[o.Index-1]
+	case *asyncpi.Recv:
+		if o.Index == 0 {
+			return p.Chan
+		}
+		return p.Vars[o.Index-1]
+	case *asyncpi.Restrict:
+		return p.Name
+	}
+	return nil
+}
+
+// IsBinder returns true if the Occurrence o declares a bound name,
+// i.e. it is the name of a Restrict or a variable of a Recv.
+func (o Occurrence) IsBinder() bool {
+	switch o.Proc.(type) {
+	case *asyncpi.Restrict:
+		return true
+	case *asyncpi.Recv:
+		return o.Index > 0
+	}
+	return false
+}
+
+// An Object is a name declared by a binder (a Restrict or a Recv),
+// or a free name.
+type Object struct {
+	Ident string
+	Decl  Occurrence // Declaring Occurrence, zero if the name is free.
+	Scope *Scope     // Scope the name is declared in.
+}
+
+// Name returns the declaring Name of the Object,
+// or nil if the Object is a free name.
+func (o *Object) Name() asyncpi.Name {
+	if o.IsFree() {
+		return nil
+	}
+	return o.Decl.Name()
+}
+
+// Binder returns the Restrict or Recv which declares the Object,
+// or nil if the Object is a free name.
+func (o *Object) Binder() asyncpi.Process {
+	return o.Decl.Proc
+}
+
+// IsFree returns true if the Object is a free name.
+func (o *Object) IsFree() bool {
+	return o.Decl.Proc == nil
+}
+
+// IsRestricted returns true if the Object is declared by a Restrict.
+func (o *Object) IsRestricted() bool {
+	_, ok := o.Decl.Proc.(*asyncpi.Restrict)
+	return ok
+}
+
+// IsVar returns true if the Object is a variable declared by a Recv.
+func (o *Object) IsVar() bool {
+	_, ok := o.Decl.Proc.(*asyncpi.Recv)
+	return ok
+}
+
+func (o *Object) String() string {
+	return o.Ident
+}
+
+// A Scope is the set of Objects declared by a binder.
+type Scope struct {
+	Parent   *Scope
+	Children []*Scope
+	Binder   asyncpi.Process // nil for the scope of free names.
+
+	objects []*Object // in declaration order.
+}
+
+func newScope(parent *Scope, binder asyncpi.Process) *Scope {
+	s := &Scope{Parent: parent, Binder: binder}
+	if parent != nil {
+		parent.Children = append(parent.Children, s)
+	}
+	return s
+}
+
+func (s *Scope) insert(obj *Object) {
+	obj.Scope = s
+	s.objects = append(s.objects, obj)
+}
+
+// Objects returns the Objects declared in the Scope s in declaration order.
+func (s *Scope) Objects() []*Object {
+	return s.objects
+}
+
+// Lookup returns the Object with the given ident declared in Scope s,
+// or nil if there is no such Object.
+//
+// If a Recv declares the same ident more than once,
+// the last declaration is returned.
+func (s *Scope) Lookup(ident string) *Object {
+	for i := len(s.objects) - 1; i >= 0; i-- {
+		if s.objects[i].Ident == ident {
+			return s.objects[i]
+		}
+	}
+	return nil
+}
+
+// LookupParent returns the innermost Object with the given ident in
+// Scope s or its parents, and the Scope it is declared in.
+// It returns nil if there is no such Object.
+func (s *Scope) LookupParent(ident string) (*Scope, *Object) {
+	for ; s != nil; s = s.Parent {
+		if obj := s.Lookup(ident); obj != nil {
+			return s, obj
+		}
+	}
+	return nil, nil
+}
+
+// Info holds the result of name resolution.
+type Info struct {
+	// Defs maps the binder Occurrences to the Objects they declare.
+	Defs map[Occurrence]*Object
+
+	// Uses maps the non-binder Occurrences to the Objects they denote.
+	// A free name occurrence maps to an Object in the Free Scope.
+	Uses map[Occurrence]*Object
+
+	// Scopes maps each Restrict and Recv to the Scope it declares.
+	Scopes map[asyncpi.Process]*Scope
+
+	// Free is the outermost Scope, which contains the free names.
+	Free *Scope
+
+	order map[Occurrence]int       // syntactic order of Occurrences.
+	uses  map[*Object][]Occurrence // Uses of each Object in syntactic order.
+}
+
+// ObjectOf returns the Object declared or denoted by Occurrence o,
+// or nil if o is not found.
+func (info *Info) ObjectOf(o Occurrence) *Object {
+	if obj, ok := info.Defs[o]; ok {
+		return obj
+	}
+	return info.Uses[o]
+}
+
+// FreeNames returns the free names in the resolved Process sorted by Ident.
+func (info *Info) FreeNames() []*Object {
+	objs := append([]*Object(nil), info.Free.Objects()...)
+	sort.Slice(objs, func(i, j int) bool { return objs[i].Ident < objs[j].Ident })
+	return objs
+}
+
+// Occurrences returns all Name Occurrences in syntactic order.
+func (info *Info) Occurrences() []Occurrence {
+	occs := make([]Occurrence, len(info.order))
+	for o, i := range info.order {
+		occs[i] = o
+	}
+	return occs
+}
+
+// UsesOf returns the Occurrences that denote Object obj in syntactic order.
+// The result must not be modified.
+func (info *Info) UsesOf(obj *Object) []Occurrence {
+	return info.uses[obj]
+}
+
+// Resolve links every Name occurrence in Process p to its binder.
+func Resolve(p asyncpi.Process) (*Info, error) {
+	info := &Info{
+		Defs:   make(map[Occurrence]*Object),
+		Uses:   make(map[Occurrence]*Object),
+		Scopes: make(map[asyncpi.Process]*Scope),
+		Free:   newScope(nil, nil),
+		order:  make(map[Occurrence]int),
+		uses:   make(map[*Object][]Occurrence),
+	}
+	// scopeOf returns the Scope of the enclosing binder.
+	scopeOf := func(c *asyncpi.Cursor) *Scope {
+		if c.Scope() == nil {
+			return info.Free
+		}
+		return info.Scopes[c.Scope().Binder]
+	}
+	var err error
+	use := func(o Occurrence, s *Scope) {
+		if o.Name() == nil {
+			err = errors.Wrap(asyncpi.ErrInvalid, "nil name")
+			return
+		}
+		info.order[o] = len(info.order)
+		ident := o.Name().Ident()
+		_, obj := s.LookupParent(ident)
+		if obj == nil {
+			obj = &Object{Ident: ident}
+			info.Free.insert(obj)
+		}
+		info.Uses[o] = obj
+		info.uses[obj] = append(info.uses[obj], o)
+	}
+	def := func(o Occurrence, s *Scope) {
+		if o.Name() == nil {
+			err = errors.Wrap(asyncpi.ErrInvalid, "nil name")
+			return
+		}
+		info.order[o] = len(info.order)
+		obj := &Object{Ident: o.Name().Ident(), Decl: o}
+		s.insert(obj)
+		info.Defs[o] = obj
+	}
+	asyncpi.Apply(p, func(c *asyncpi.Cursor) bool {
+		if err != nil {
+			return false
+		}
+		switch p := c.Node().(type) {
+		case *asyncpi.NilProcess, *asyncpi.Par, *asyncpi.Repeat:
+		case *asyncpi.Recv:
+			use(Occurrence{Proc: p, Index: 0}, scopeOf(c))
+			s := newScope(scopeOf(c), p)
+			info.Scopes[p] = s
+			for i := range p.Vars {
+				def(Occurrence{Proc: p, Index: i + 1}, s)
+			}
+		case *asyncpi.Restrict:
+			s := newScope(scopeOf(c), p)
+			info.Scopes[p] = s
+			def(Occurrence{Proc: p, Index: 0}, s)
+		case *asyncpi.Send:
+			use(Occurrence{Proc: p, Index: 0}, scopeOf(c))
+			for i := range p.Vals {
+				use(Occurrence{Proc: p, Index: i + 1}, scopeOf(c))
+			}
+		default:
+			err = asyncpi.UnknownProcessError{Proc: p}
+		}
+		return err == nil
+	}, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot resolve names")
+	}
+	return info, nil
+}
@@ -0,0 +1,127 @@
+package resolve
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.nickng.io/asyncpi"
+)
+
+func parse(t *testing.T, s string) asyncpi.Process {
+	p, err := asyncpi.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestResolveDefsUses(t *testing.T) {
+	p := parse(t, `(new x)(a(y).x<y> | x().0)`)
+	info, err := Resolve(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := p.(*asyncpi.Restrict)
+	par := res.Proc.(*asyncpi.Par)
+	recv := par.Procs[0].(*asyncpi.Recv)
+	send := recv.Cont.(*asyncpi.Send)
+	recv2 := par.Procs[1].(*asyncpi.Recv)
+
+	x := info.Defs[Occurrence{Proc: res, Index: 0}]
+	if x == nil || !x.IsRestricted() {
+		t.Fatalf("expects x to be defined by restriction but got %v", x)
+	}
+	y := info.Defs[Occurrence{Proc: recv, Index: 1}]
+	if y == nil || !y.IsVar() {
+		t.Fatalf("expects y to be defined by receive but got %v", y)
+	}
+	if want, got := x, info.Uses[Occurrence{Proc: send, Index: 0}]; want != got {
+		t.Errorf("expects %s to denote %v but got %v", send.Calculi(), want, got)
+	}
+	if want, got := y, info.Uses[Occurrence{Proc: send, Index: 1}]; want != got {
+		t.Errorf("expects %s to denote %v but got %v", send.Calculi(), want, got)
+	}
+	if want, got := x, info.Uses[Occurrence{Proc: recv2, Index: 0}]; want != got {
+		t.Errorf("expects %s to denote %v but got %v", recv2.Calculi(), want, got)
+	}
+	a := info.Uses[Occurrence{Proc: recv, Index: 0}]
+	if a == nil || !a.IsFree() || a.Scope != info.Free {
+		t.Fatalf("expects a to be free but got %v", a)
+	}
+	if want, got := 3, len(info.UsesOf(x))+len(info.UsesOf(y)); want != got {
+		t.Errorf("expects %d uses of x and y but got %d", want, got)
+	}
+}
+
+func TestResolveShadow(t *testing.T) {
+	p := parse(t, `(new x)(new x)x<>`)
+	info, err := Resolve(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outer := p.(*asyncpi.Restrict)
+	inner := outer.Proc.(*asyncpi.Restrict)
+	send := inner.Proc.(*asyncpi.Send)
+	if want, got := info.Defs[Occurrence{Proc: inner}], info.Uses[Occurrence{Proc: send}]; want != got {
+		t.Fatalf("expects x to denote the inner binder %v but got %v", want, got)
+	}
+	if want, got := info.Scopes[outer], info.Scopes[inner].Parent; want != got {
+		t.Fatalf("expects parent scope %v but got %v", want, got)
+	}
+}
+
+func TestResolveFreeNames(t *testing.T) {
+	p := parse(t, `b<a> | a(x).x<c> | b().0`)
+	info, err := Resolve(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var idents []string
+	for _, obj := range info.FreeNames() {
+		idents = append(idents, obj.Ident)
+	}
+	if want, got := "a,b,c", strings.Join(idents, ","); want != got {
+		t.Fatalf("expects free names %s but got %s", want, got)
+	}
+	// Free names with the same ident are the same Object.
+	for _, obj := range info.FreeNames() {
+		if obj.Ident == "b" && len(info.UsesOf(obj)) != 2 {
+			t.Fatalf("expects 2 uses of b but got %d", len(info.UsesOf(obj)))
+		}
+	}
+}
+
+func TestUsesOf(t *testing.T) {
+	p := parse(t, `(new x)(x<a> | a(y).(y<x> | x().0))`)
+	info, err := Resolve(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := info.Defs[Occurrence{Proc: p}]
+	var got []string
+	for _, o := range info.UsesOf(x) {
+		got = append(got, fmt.Sprintf("%s@%d", o.Proc.Calculi(), o.Index))
+	}
+	if want := "x<a>@0,y<x>@1,x().0@0"; want != strings.Join(got, ",") {
+		t.Fatalf("expects uses %s but got %s", want, strings.Join(got, ","))
+	}
+}
+
+func TestResolveClone(t *testing.T) {
+	p := parse(t, `(new x)a(y).x<y>`)
+	if err := asyncpi.Bind(&p); err != nil {
+		t.Fatal(err)
+	}
+	q := asyncpi.Clone(p)
+	info, err := Resolve(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 1, len(info.FreeNames()); want != got {
+		t.Fatalf("expects %d free names but got %d", want, got)
+	}
+	if want, got := 5, len(info.Occurrences()); want != got {
+		t.Fatalf("expects %d occurrences but got %d", want, got)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	goruntime "runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Chan is a π-calculus channel with an unbounded buffer of messages,
+// where each message is a tuple of values. Messages are received in
+// the order they are sent.
+type Chan struct {
+	name    string
+	g       *Group
+	msgs    []*message // Messages not received, guarded by g.
+	waiting []*waiter  // Receivers waiting for a message, guarded by g.
+}
+
+type message struct {
+	vals   []Value
+	recv   chan struct{} // Closed when received, nil if the sender does not wait.
+	server bool          // The sender is a replica waiting for its guard.
+}
+
+type waiter struct {
+	vals   chan []Value
+	server bool
+}
+
+// New returns a new channel of the processes of the Group g, i.e. the
+// restriction (new name). The name is only used to print the channel.
+func (g *Group) New(name string) *Chan {
+	return &Chan{name: name, g: g}
+}
+
+// Name returns the name of the channel c.
+func (c *Chan) Name() string { return c.name }
+
+func (c *Chan) String() string { return c.name }
+
+// Send sends the values vals on c without blocking.
+func (c *Chan) Send(vals ...Value) {
+	c.g.mu.Lock()
+	c.g.stopped()
+	defer c.g.mu.Unlock()
+	c.send(&message{vals: vals})
+}
+
+// send delivers the message m, or keeps it until received.
+// It is called with c.g locked.
+func (c *Chan) send(m *message) bool {
+	if len(c.waiting) == 0 {
+		c.msgs = append(c.msgs, m)
+		return false
+	}
+	w := c.waiting[0]
+	c.waiting = c.waiting[1:]
+	c.g.unblock(w.server)
+	w.vals <- m.vals
+	return true
+}
+
+// sendWait sends the values vals on c and blocks until they are received.
+func (c *Chan) sendWait(server bool, vals []Value) {
+	m := &message{vals: vals, recv: make(chan struct{}), server: server}
+	c.g.mu.Lock()
+	c.g.stopped()
+	if c.send(m) {
+		c.g.mu.Unlock()
+		return
+	}
+	c.g.block(server)
+	c.g.mu.Unlock()
+	select {
+	case <-m.recv:
+	case <-c.g.done:
+		goruntime.Goexit()
+	}
+}
+
+// Recv receives the values of a message on c, and blocks until there
+// is a message.
+func (c *Chan) Recv() []Value {
+	return c.recv(false)
+}
+
+func (c *Chan) recv(server bool) []Value {
+	c.g.mu.Lock()
+	c.g.stopped()
+	if len(c.msgs) > 0 {
+		m := c.msgs[0]
+		c.msgs = c.msgs[1:]
+		if m.recv != nil {
+			c.g.unblock(m.server)
+			close(m.recv)
+		}
+		c.g.mu.Unlock()
+		return m.vals
+	}
+	w := &waiter{vals: make(chan []Value, 1), server: server}
+	c.waiting = append(c.waiting, w)
+	c.g.block(server)
+	c.g.mu.Unlock()
+	select {
+	case vals := <-w.vals:
+		return vals
+	case <-c.g.done:
+		goruntime.Goexit()
+		return nil
+	}
+}
+
+// A Replica is a copy of the replicated process of Replicate.
+//
+// The guards of a replica, i.e. its first inputs and outputs, are
+// performed with the Replica, and the next replica is started when a
+// guard is fired. A replica waiting for its guard is not blocked, as
+// it may never be needed.
+type Replica struct {
+	g     *Group
+	f     func(*Replica)
+	fired int32
+	once  sync.Once
+}
+
+// Replicate starts the replicated process !P in the Group g, where each
+// replica of P is run by f. Replicas are started on demand, when the
+// guard of the previous replica is fired.
+func (g *Group) Replicate(f func(r *Replica)) {
+	g.Spawn(func() { f(&Replica{g: g, f: f}) })
+}
+
+// Fire fires the guard of the replica r, which starts the next replica.
+// The guards performed with r are fired by Recv and Send, so Fire is
+// only needed for guards performed otherwise, e.g. on a Go channel.
+func (r *Replica) Fire() {
+	r.once.Do(func() {
+		atomic.StoreInt32(&r.fired, 1)
+		r.g.Replicate(r.f)
+	})
+}
+
+func (r *Replica) isFired() bool {
+	return atomic.LoadInt32(&r.fired) == 1
+}
+
+// Recv receives on c as a guard of the replica r (see Chan.Recv).
+func (r *Replica) Recv(c *Chan) []Value {
+	vals := c.recv(!r.isFired())
+	r.Fire()
+	return vals
+}
+
+// Send sends on c as a guard of the replica r (see Chan.Send). Before
+// the guard is fired, it blocks until the values are received, so the
+// next replica is only started when the output is needed.
+func (r *Replica) Send(c *Chan, vals ...Value) {
+	if r.isFired() {
+		c.Send(vals...)
+		return
+	}
+	c.sendWait(true, vals)
+	r.Fire()
+}
@@ -0,0 +1,213 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package runtime provides the run-time support of Go code generated
+// from asynchronous π-calculus Processes.
+//
+// A Chan is a π-calculus channel, where an output never blocks and
+// each output carries a tuple of values, so a Process maps directly
+// to Go code, e.g.
+//
+//     (new a)(a<b,c> | a(x,y).x<y>)
+//
+// is run by
+//
+//     runtime.Run(func(g *runtime.Group) {
+//         a := g.New("a")
+//         g.Spawn(func() { a.Send(b, c) })
+//         v := a.Recv()
+//         x, y := v[0].(*runtime.Chan), v[1]
+//         x.Send(y)
+//     })
+//
+// The processes of each Run are tracked by its Group, which detects
+// when the processes are terminated or deadlocked. Runs are independent
+// of each other, so they can be run concurrently.
+package runtime // import "go.nickng.io/asyncpi/runtime"
+
+import (
+	"context"
+	"fmt"
+	goruntime "runtime"
+	"sync"
+)
+
+// Value is a value sent on a Chan, which is a *Chan for a channel.
+type Value interface{}
+
+// Scheduler runs the processes started by Spawn and Replicate.
+type Scheduler interface {
+	// Go runs the process f concurrently.
+	Go(f func())
+}
+
+type goScheduler struct{}
+
+// Go runs f in a new goroutine.
+func (goScheduler) Go(f func()) { go f() }
+
+// DeadlockError is the type of error when the processes started by Run
+// are blocked forever.
+type DeadlockError struct {
+	Blocked int // Number of blocked processes, excluding replicas.
+}
+
+func (e *DeadlockError) Error() string {
+	return fmt.Sprintf("deadlock: %d process(es) blocked", e.Blocked)
+}
+
+// A Group is the processes started by a Run, and the channels they
+// communicate on. Blocked processes are waiting in a receive (or a send
+// of a replica guard). As outputs never block, the processes are
+// blocked forever when all of them are blocked.
+type Group struct {
+	mu      sync.Mutex
+	sched   Scheduler
+	procs   sync.WaitGroup
+	running int           // Processes not finished.
+	blocked int           // Processes blocked on a Chan.
+	servers int           // Replicas blocked on their guard.
+	done    chan struct{} // Closed when the Run ends.
+	ended   bool
+	err     error
+}
+
+// Spawn starts the process f in the Group g, i.e. f is run in parallel.
+// No process is started after the Run of g has ended.
+func (g *Group) Spawn(f func()) {
+	g.mu.Lock()
+	if g.ended {
+		g.mu.Unlock()
+		return
+	}
+	g.running++
+	g.procs.Add(1)
+	g.mu.Unlock()
+	g.sched.Go(func() {
+		defer g.exit()
+		f()
+	})
+}
+
+// exit records that a process has finished.
+func (g *Group) exit() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.running--
+	g.check()
+	g.procs.Done()
+}
+
+// block records that a process is blocked. It is called with g locked.
+func (g *Group) block(server bool) {
+	g.blocked++
+	if server {
+		g.servers++
+	}
+	g.check()
+}
+
+// unblock records that a blocked process can continue.
+// It is called with g locked.
+func (g *Group) unblock(server bool) {
+	g.blocked--
+	if server {
+		g.servers--
+	}
+}
+
+// check ends the Run if all processes are blocked or finished.
+// It is called with g locked.
+func (g *Group) check() {
+	if g.ended || g.blocked < g.running {
+		return
+	}
+	if g.blocked > g.servers {
+		g.end(&DeadlockError{Blocked: g.blocked - g.servers})
+		return
+	}
+	g.end(nil)
+}
+
+// end ends the Run with the error err. It is called with g locked.
+func (g *Group) end(err error) {
+	if g.ended {
+		return
+	}
+	g.ended, g.err = true, err
+	close(g.done)
+}
+
+// stopped stops the calling process if the Run of g has ended.
+// It is called with g locked, which is unlocked if the process stops.
+func (g *Group) stopped() {
+	if g.ended {
+		g.mu.Unlock()
+		goruntime.Goexit()
+	}
+}
+
+// Stop ends the Run of g with the error err, unless it has already
+// ended, e.g. when a process fails.
+func (g *Group) Stop(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.end(err)
+}
+
+// Done returns a channel which is closed when the Run of g ends, so
+// processes waiting for other events than messages on a Chan can stop.
+func (g *Group) Done() <-chan struct{} {
+	return g.done
+}
+
+// Config is the configuration of a Run.
+type Config struct {
+	// Scheduler runs the processes, where nil runs each process in a
+	// new goroutine.
+	Scheduler Scheduler
+}
+
+// Run runs the process f in a new Group, and waits until the processes
+// of the Group are terminated, i.e. every process has finished or only
+// replicas are waiting for their guard. It returns a *DeadlockError if
+// other processes are blocked forever.
+//
+// The Run ends early with the error of Group.Stop, or with ctx.Err() if
+// ctx is done first. Run returns when the processes left are stopped:
+// processes blocked on a Chan, or communicating on a Chan after the Run
+// has ended, are stopped by runtime.Goexit, and processes waiting for
+// other events should return when Group.Done is closed.
+func (cfg Config) Run(ctx context.Context, f func(g *Group)) error {
+	g := &Group{sched: cfg.Scheduler, done: make(chan struct{})}
+	if g.sched == nil {
+		g.sched = goScheduler{}
+	}
+	g.Spawn(func() { f(g) })
+	select {
+	case <-g.done:
+	case <-ctx.Done():
+		g.Stop(ctx.Err())
+	}
+	g.procs.Wait()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.err
+}
+
+// Run runs the process f in a new Group with the default Config
+// (see Config.Run).
+func Run(f func(g *Group)) error {
+	return Config{}.Run(context.Background(), f)
+}
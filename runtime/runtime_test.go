@@ -0,0 +1,215 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunTerminate(t *testing.T) {
+	var got []Value
+	err := Run(func(g *Group) {
+		a, b := g.New("a"), g.New("b")
+		g.Spawn(func() { a.Send(b, 1) })
+		v := a.Recv()
+		x := v[0].(*Chan)
+		x.Send(v[1])
+		got = b.Recv()
+	})
+	if err != nil {
+		t.Fatalf("expects no error but got %v", err)
+	}
+	if len(got) != 1 || got[0] != 1 {
+		t.Errorf("expects [1] but got %v", got)
+	}
+}
+
+func TestRunOrder(t *testing.T) {
+	var got []Value
+	err := Run(func(g *Group) {
+		a := g.New("a")
+		for i := 0; i < 3; i++ {
+			a.Send(i)
+		}
+		for i := 0; i < 3; i++ {
+			got = append(got, a.Recv()...)
+		}
+	})
+	if err != nil {
+		t.Fatalf("expects no error but got %v", err)
+	}
+	for i, v := range got {
+		if v != i {
+			t.Errorf("expects message %d to be %d but got %v", i, i, v)
+		}
+	}
+}
+
+// Outputs never received do not block.
+func TestRunPendingOutput(t *testing.T) {
+	err := Run(func(g *Group) {
+		a := g.New("a")
+		a.Send()
+		a.Send()
+		a.Recv()
+	})
+	if err != nil {
+		t.Errorf("expects no error but got %v", err)
+	}
+}
+
+func TestRunDeadlock(t *testing.T) {
+	err := Run(func(g *Group) {
+		a, b := g.New("a"), g.New("b")
+		g.Spawn(func() { a.Recv() })
+		b.Recv()
+		a.Send()
+	})
+	deadlock, ok := err.(*DeadlockError)
+	if !ok {
+		t.Fatalf("expects DeadlockError but got %v", err)
+	}
+	if deadlock.Blocked != 2 {
+		t.Errorf("expects 2 blocked processes but got %d", deadlock.Blocked)
+	}
+}
+
+func TestReplicate(t *testing.T) {
+	count := make(chan int, 3)
+	err := Run(func(g *Group) {
+		a, r := g.New("a"), g.New("r")
+		// !a(x,r).r<x>
+		g.Replicate(func(rep *Replica) {
+			v := rep.Recv(a)
+			v[1].(*Chan).Send(v[0])
+		})
+		for i := 0; i < 3; i++ {
+			a.Send(i, r)
+		}
+		for i := 0; i < 3; i++ {
+			count <- r.Recv()[0].(int)
+		}
+	})
+	if err != nil {
+		t.Fatalf("expects no error but got %v", err)
+	}
+	close(count)
+	sum := 0
+	for i := range count {
+		sum += i
+	}
+	if sum != 3 {
+		t.Errorf("expects replies 0, 1, 2 but got sum %d", sum)
+	}
+}
+
+// Replicated outputs are only sent when received.
+func TestReplicateOutput(t *testing.T) {
+	err := Run(func(g *Group) {
+		a := g.New("a")
+		// !a<> | a().a().a().0
+		g.Replicate(func(rep *Replica) { rep.Send(a) })
+		a.Recv()
+		a.Recv()
+		a.Recv()
+	})
+	if err != nil {
+		t.Errorf("expects no error but got %v", err)
+	}
+}
+
+func TestReplicateDeadlock(t *testing.T) {
+	err := Run(func(g *Group) {
+		a, b := g.New("a"), g.New("b")
+		// !a(x).x().0 | a<b>
+		g.Replicate(func(rep *Replica) {
+			v := rep.Recv(a)
+			v[0].(*Chan).Recv()
+		})
+		a.Send(b)
+	})
+	deadlock, ok := err.(*DeadlockError)
+	if !ok {
+		t.Fatalf("expects DeadlockError but got %v", err)
+	}
+	if deadlock.Blocked != 1 {
+		t.Errorf("expects 1 blocked process but got %d", deadlock.Blocked)
+	}
+}
+
+// Runs are independent, so they can be run concurrently.
+func TestRunConcurrent(t *testing.T) {
+	errs := make(chan error, 20)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(deadlock bool) {
+			defer wg.Done()
+			errs <- Run(func(g *Group) {
+				a, b := g.New("a"), g.New("b")
+				g.Spawn(func() { a.Send(b) })
+				x := a.Recv()[0].(*Chan)
+				if !deadlock {
+					x.Send()
+				}
+				b.Recv()
+			})
+		}(i%2 == 0)
+	}
+	wg.Wait()
+	close(errs)
+	var deadlocks int
+	for err := range errs {
+		if err == nil {
+			continue
+		}
+		if deadlock, ok := err.(*DeadlockError); !ok || deadlock.Blocked != 1 {
+			t.Fatalf("expects DeadlockError of 1 blocked process but got %v", err)
+		}
+		deadlocks++
+	}
+	if deadlocks != cap(errs)/2 {
+		t.Errorf("expects %d deadlocks but got %d", cap(errs)/2, deadlocks)
+	}
+}
+
+// Processes left are stopped when the Run ends.
+func TestRunStop(t *testing.T) {
+	stopErr := errors.New("stop")
+	var stopped int32
+	err := Run(func(g *Group) {
+		a := g.New("a")
+		for i := 0; i < 3; i++ {
+			g.Spawn(func() {
+				defer atomic.AddInt32(&stopped, 1)
+				a.Recv()
+			})
+		}
+		g.Stop(stopErr)
+		a.Send()
+	})
+	if err != stopErr {
+		t.Errorf("expects %v but got %v", stopErr, err)
+	}
+	if stopped != 3 {
+		t.Errorf("expects 3 stopped processes but got %d", stopped)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ready := make(chan struct{})
+	go func() {
+		<-ready
+		cancel()
+	}()
+	err := Config{}.Run(ctx, func(g *Group) {
+		close(ready)
+		<-g.Done()
+	})
+	if err != context.Canceled {
+		t.Errorf("expects %v but got %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,358 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"bytes"
+	"fmt"
+
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/internal/errors"
+	"go.nickng.io/asyncpi/internal/name"
+	"go.nickng.io/asyncpi/resolve"
+	"go.nickng.io/asyncpi/types"
+)
+
+func errCheck(err error) error {
+	return errors.Wrap(err, "cannot check sessions")
+}
+
+// Session is a restricted channel with the session types of its endpoints.
+type Session struct {
+	Name      string
+	Pos       asyncpi.Pos // Position of the declaration.
+	Endpoints [2]Endpoint
+}
+
+func (s Session) String() string {
+	return fmt.Sprintf("%s: %s: %s | %s", s.Pos, s.Name, s.Endpoints[0].Type, s.Endpoints[1].Type)
+}
+
+// Endpoint is a process using a session channel.
+type Endpoint struct {
+	Pos  asyncpi.Pos // Position of the first use of the channel.
+	Type Type
+}
+
+// EndpointError is the type of error when a session channel
+// is not used by exactly two processes.
+type EndpointError struct {
+	Name  string
+	Pos   asyncpi.Pos
+	Count int
+}
+
+func (e *EndpointError) Error() string {
+	return fmt.Sprintf("%s: session %s has %d endpoint(s) but expects 2", e.Pos, e.Name, e.Count)
+}
+
+// ParallelError is the type of error when an endpoint uses
+// a session channel in parallel, so the order is not known.
+type ParallelError struct {
+	Name string
+	Pos  asyncpi.Pos // Position of the parallel use.
+}
+
+func (e *ParallelError) Error() string {
+	return fmt.Sprintf("%s: session %s is used in parallel by the same endpoint", e.Pos, e.Name)
+}
+
+// DualityError is the type of error when the endpoints
+// of a session channel do not follow dual protocols.
+type DualityError struct {
+	Name      string
+	Pos       asyncpi.Pos
+	Endpoints [2]Endpoint
+}
+
+func (e *DualityError) Error() string {
+	return fmt.Sprintf("%s: endpoints of session %s are not dual: %s at %s and %s at %s",
+		e.Pos, e.Name, e.Endpoints[0].Type, e.Endpoints[0].Pos, e.Endpoints[1].Type, e.Endpoints[1].Pos)
+}
+
+// CheckError is the type of error when more than one session
+// channel is not used correctly.
+type CheckError struct {
+	Errs []error
+}
+
+func (e *CheckError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d session error(s)", len(e.Errs))
+	for _, err := range e.Errs {
+		fmt.Fprintf(&buf, "\n\t%s", err)
+	}
+	return buf.String()
+}
+
+// Check checks that the two endpoints of each restricted channel
+// in Process p follow dual protocols, and returns the Sessions in
+// order of declaration.
+//
+// The endpoints of a channel are the two parallel processes in the
+// scope of the channel which use the channel. The session type of an
+// endpoint is the sequence of sends and receives on the channel, where
+// a receive is followed by its continuation, and a send in parallel
+// with the rest of the endpoint is followed by the rest, e.g. in
+//
+//     (new s)(s(x).(s<x> | s(y).0) | b(z).(s<z> | s(w).s<w>))
+//
+// the endpoints of s are ?_.!_.?_.end and !_.?_.!_.end. The replication
+// of an endpoint is a recursive session, e.g. !s(x).0 is μt0.?_.t0.
+//
+// Payload types are the types of the names if Process p is typed, or
+// their type hints, and _ if not known. Free names are not checked as
+// an endpoint is in the environment, nor are names sent as values as
+// the endpoint is delegated to another process.
+//
+// If a session channel is not used correctly, the error is an
+// *EndpointError, a *ParallelError or a *DualityError, and if there
+// are more than one, a *CheckError with all of them.
+func Check(p asyncpi.Process) ([]Session, error) {
+	info, err := resolve.Resolve(p)
+	if err != nil {
+		return nil, errCheck(err)
+	}
+	delegated := make(map[*resolve.Object]bool)
+	for o, obj := range info.Uses {
+		if _, isSend := o.Proc.(*asyncpi.Send); isSend && o.Index > 0 {
+			delegated[obj] = true
+		}
+	}
+	var sessions []Session
+	var errs []error
+	for _, o := range info.Occurrences() {
+		obj := info.Defs[o]
+		if obj == nil || !obj.IsRestricted() || delegated[obj] || len(info.UsesOf(obj)) == 0 {
+			continue
+		}
+		c := &checker{info: info, chans: map[*resolve.Object]string{obj: ""}}
+		s, err := c.check(obj, o.Proc.(*asyncpi.Restrict))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+	if len(errs) > 0 {
+		return sessions, errCheck(checkError(errs))
+	}
+	return sessions, nil
+}
+
+// checker finds the session types of processes on session channels.
+type checker struct {
+	info  *resolve.Info
+	chans map[*resolve.Object]string // Session channels to the roles they are with.
+	vars  int                        // Number of session variables.
+}
+
+func (c *checker) check(obj *resolve.Object, decl *asyncpi.Restrict) (Session, error) {
+	s := Session{Name: obj.Ident, Pos: asyncpi.NamePos(decl.Name)}
+	var endpoints []asyncpi.Process
+	for _, p := range components(decl.Proc) {
+		if c.uses(p) {
+			endpoints = append(endpoints, p)
+		}
+	}
+	if len(endpoints) != 2 {
+		return s, &EndpointError{Name: s.Name, Pos: s.Pos, Count: len(endpoints)}
+	}
+	for i, p := range endpoints {
+		c.vars = 0
+		t, err := c.session(p)
+		if err != nil {
+			return s, err
+		}
+		s.Endpoints[i] = Endpoint{Pos: asyncpi.NamePos(c.firstUse(p)), Type: t}
+	}
+	if !IsDual(s.Endpoints[0].Type, s.Endpoints[1].Type) {
+		return s, &DualityError{Name: s.Name, Pos: s.Pos, Endpoints: s.Endpoints}
+	}
+	return s, nil
+}
+
+// components returns the parallel processes of p.
+func components(p asyncpi.Process) []asyncpi.Process {
+	switch p := p.(type) {
+	case *asyncpi.Par:
+		var procs []asyncpi.Process
+		for _, proc := range p.Procs {
+			procs = append(procs, components(proc)...)
+		}
+		return procs
+	case *asyncpi.Restrict:
+		return components(p.Proc)
+	}
+	return []asyncpi.Process{p}
+}
+
+// chanOf returns the session channel used by Process p, or nil.
+func (c *checker) chanOf(p asyncpi.Process) *resolve.Object {
+	switch p.(type) {
+	case *asyncpi.Send, *asyncpi.Recv:
+		obj := c.info.Uses[resolve.Occurrence{Proc: p, Index: 0}]
+		if _, ok := c.chans[obj]; ok {
+			return obj
+		}
+	}
+	return nil
+}
+
+// isUse returns true if Process p uses a session channel.
+func (c *checker) isUse(p asyncpi.Process) bool {
+	return c.chanOf(p) != nil
+}
+
+// uses returns true if Process p or its children use a session channel.
+func (c *checker) uses(p asyncpi.Process) bool {
+	found := false
+	asyncpi.Inspect(p, func(p asyncpi.Process) bool {
+		if c.isUse(p) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// firstUse returns the first session channel used in p.
+func (c *checker) firstUse(p asyncpi.Process) asyncpi.Name {
+	var first asyncpi.Name
+	asyncpi.Inspect(p, func(p asyncpi.Process) bool {
+		if first == nil && c.isUse(p) {
+			first = resolve.Occurrence{Proc: p, Index: 0}.Name()
+		}
+		return first == nil
+	})
+	return first
+}
+
+// session returns the session type of Process p on the session channels.
+func (c *checker) session(p asyncpi.Process) (Type, error) {
+	switch p := p.(type) {
+	case *asyncpi.NilProcess:
+		return End{}, nil
+	case *asyncpi.Send:
+		if !c.isUse(p) {
+			return End{}, nil
+		}
+		return &Send{Role: c.chans[c.chanOf(p)], Payload: c.payload(p), Cont: End{}}, nil
+	case *asyncpi.Recv:
+		cont, err := c.session(p.Cont)
+		if err != nil || !c.isUse(p) {
+			return cont, err
+		}
+		return &Recv{Role: c.chans[c.chanOf(p)], Payload: c.payload(p), Cont: cont}, nil
+	case *asyncpi.Restrict:
+		return c.session(p.Proc)
+	case *asyncpi.Repeat:
+		body, err := c.session(p.Proc)
+		if _, isEnd := body.(End); err != nil || isEnd {
+			return body, err
+		}
+		v := fmt.Sprintf("t%d", c.vars)
+		c.vars++
+		return &Rec{Var: v, Body: loop(body, &Var{Name: v})}, nil
+	case *asyncpi.Par:
+		return c.parSession(p)
+	}
+	return nil, asyncpi.UnknownProcessError{Proc: p}
+}
+
+// loop returns t with v in place of the end of the session.
+func loop(t Type, v Type) Type {
+	switch t := t.(type) {
+	case End:
+		return v
+	case *Send:
+		return &Send{Role: t.Role, Payload: t.Payload, Cont: loop(t.Cont, v)}
+	case *Recv:
+		return &Recv{Role: t.Role, Payload: t.Payload, Cont: loop(t.Cont, v)}
+	case *Rec:
+		return &Rec{Var: t.Var, Body: loop(t.Body, v)}
+	}
+	return t
+}
+
+// parSession returns the session type of Par p, where at most one
+// process uses a session channel other than a send on the channel.
+func (c *checker) parSession(p *asyncpi.Par) (Type, error) {
+	var send *asyncpi.Send
+	var rest asyncpi.Process
+	for _, proc := range p.Procs {
+		if !c.uses(proc) {
+			continue
+		}
+		if s, isSend := proc.(*asyncpi.Send); isSend && send == nil {
+			send = s
+			continue
+		}
+		if rest != nil {
+			return nil, c.parallelError(proc)
+		}
+		rest = proc
+	}
+	if _, isSend := rest.(*asyncpi.Send); isSend {
+		// Parallel sends are not ordered.
+		return nil, c.parallelError(rest)
+	}
+	var t Type = End{}
+	if rest != nil {
+		cont, err := c.session(rest)
+		if err != nil {
+			return nil, err
+		}
+		t = cont
+	}
+	if send != nil {
+		t = &Send{Role: c.chans[c.chanOf(send)], Payload: c.payload(send), Cont: t}
+	}
+	return t, nil
+}
+
+func (c *checker) parallelError(p asyncpi.Process) error {
+	n := c.firstUse(p)
+	return &ParallelError{Name: n.Ident(), Pos: asyncpi.NamePos(n)}
+}
+
+// payload returns the types of the values of a Send or the variables
+// of a Recv, or nil if not known.
+func (c *checker) payload(p asyncpi.Process) []types.Type {
+	var ts []types.Type
+	for i := 1; ; i++ {
+		o := resolve.Occurrence{Proc: p, Index: i}
+		obj := c.info.ObjectOf(o)
+		if obj == nil {
+			return ts
+		}
+		ts = append(ts, typeOf(o.Name(), obj))
+	}
+}
+
+// typeOf returns the type of Name n of object obj if n is typed,
+// or the type hint of its declaration.
+func typeOf(n asyncpi.Name, obj *resolve.Object) types.Type {
+	if tn, ok := n.(types.TypedName); ok {
+		return tn.Type()
+	}
+	if !obj.IsFree() {
+		n = obj.Decl.Name()
+	}
+	if th, ok := n.(name.TypeHinter); ok {
+		return types.NewBase(th.TypeHint())
+	}
+	return nil
+}
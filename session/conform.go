@@ -0,0 +1,203 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/internal/errors"
+	"go.nickng.io/asyncpi/resolve"
+)
+
+func errConform(err error) error {
+	return errors.Wrap(err, "cannot check conformance")
+}
+
+// RoleError is the type of error when the roles of a global type
+// and the components of a Process do not match.
+type RoleError struct {
+	Role string
+	Msg  string
+}
+
+func (e *RoleError) Error() string {
+	return fmt.Sprintf("role %s %s", e.Role, e.Msg)
+}
+
+// ChannelError is the type of error when a channel is used by more
+// than two roles, so the roles it is between are not known.
+type ChannelError struct {
+	Name  string
+	Pos   asyncpi.Pos
+	Roles []string
+}
+
+func (e *ChannelError) Error() string {
+	return fmt.Sprintf("%s: channel %s is used by roles %s but expects 2",
+		e.Pos, e.Name, strings.Join(e.Roles, ", "))
+}
+
+// ConformanceError is the type of error when a component does not
+// conform to the local type of its role.
+type ConformanceError struct {
+	Role     string
+	Pos      asyncpi.Pos // Position of the first use of a session channel.
+	Local    Type        // Local type of the component.
+	Expected Type        // Projection of the global type.
+}
+
+func (e *ConformanceError) Error() string {
+	return fmt.Sprintf("%s: role %s has local type %s but expects %s", e.Pos, e.Role, e.Local, e.Expected)
+}
+
+// Conform checks that each component of Process p in roles conforms
+// to the projection of global type g to its role.
+//
+// The channels between roles are the channels used by the components
+// of exactly two roles, and the local type of a component is its
+// session type on these channels (see Check), where a send or receive
+// is with the role at the other end of the channel, e.g.
+//
+//     (new ab,bc)(ab<1> | ab(x).bc<x> | bc(y).0)
+//
+// with the components ab<1>, ab(x).bc<x> and bc(y).0 as roles A, B
+// and C conforms to A->B:<int>.B->C:<int>.end. Channels used by only
+// one role are internal to the component and are not checked.
+//
+// If a role does not conform, the error is a *RoleError, a
+// *ChannelError or a *ConformanceError, and if there are more than
+// one, a *CheckError with all of them.
+func Conform(p asyncpi.Process, g Type, roles map[string]asyncpi.Process) error {
+	info, err := resolve.Resolve(p)
+	if err != nil {
+		return errConform(err)
+	}
+	procs := make(map[asyncpi.Process]bool)
+	asyncpi.Inspect(p, func(p asyncpi.Process) bool {
+		procs[p] = true
+		return true
+	})
+	var errs []error
+	globalRoles := Roles(g)
+	inGlobal := make(map[string]bool)
+	for _, r := range globalRoles {
+		inGlobal[r] = true
+		switch comp, ok := roles[r]; {
+		case !ok:
+			errs = append(errs, &RoleError{Role: r, Msg: "has no component"})
+		case !procs[comp]:
+			errs = append(errs, &RoleError{Role: r, Msg: "has a component not in the process"})
+		}
+	}
+	var extra []string
+	for r := range roles {
+		if !inGlobal[r] {
+			extra = append(extra, r)
+		}
+	}
+	sort.Strings(extra)
+	for _, r := range extra {
+		errs = append(errs, &RoleError{Role: r, Msg: "is not in the global type"})
+	}
+	if len(errs) > 0 {
+		return errConform(checkError(errs))
+	}
+
+	// Roles using each channel, in order of the roles in g.
+	users := make(map[*resolve.Object][]string)
+	var chans []*resolve.Object
+	for _, r := range globalRoles {
+		asyncpi.Inspect(roles[r], func(p asyncpi.Process) bool {
+			switch p.(type) {
+			case *asyncpi.Send, *asyncpi.Recv:
+				obj := info.Uses[resolve.Occurrence{Proc: p, Index: 0}]
+				if obj == nil {
+					break
+				}
+				if _, seen := users[obj]; !seen {
+					chans = append(chans, obj)
+				}
+				if rs := users[obj]; len(rs) == 0 || rs[len(rs)-1] != r {
+					users[obj] = append(rs, r)
+				}
+			}
+			return true
+		})
+	}
+	peers := make(map[string]map[*resolve.Object]string)
+	for _, r := range globalRoles {
+		peers[r] = make(map[*resolve.Object]string)
+	}
+	for _, obj := range chans {
+		switch rs := users[obj]; len(rs) {
+		case 1:
+		case 2:
+			peers[rs[0]][obj] = rs[1]
+			peers[rs[1]][obj] = rs[0]
+		default:
+			pos := asyncpi.NamePos(info.UsesOf(obj)[0].Name())
+			errs = append(errs, &ChannelError{Name: obj.Ident, Pos: pos, Roles: rs})
+		}
+	}
+	if len(errs) > 0 {
+		return errConform(checkError(errs))
+	}
+
+	for _, r := range globalRoles {
+		c := &checker{info: info, chans: peers[r]}
+		local, err := c.session(roles[r])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if expected := Project(g, r); !IsEqual(local, expected) {
+			pos := procPos(roles[r])
+			if n := c.firstUse(roles[r]); n != nil {
+				pos = asyncpi.NamePos(n)
+			}
+			errs = append(errs, &ConformanceError{Role: r, Pos: pos, Local: local, Expected: expected})
+		}
+	}
+	if len(errs) > 0 {
+		return errConform(checkError(errs))
+	}
+	return nil
+}
+
+// procPos returns the position of the first channel used in Process p.
+func procPos(p asyncpi.Process) asyncpi.Pos {
+	var pos asyncpi.Pos
+	asyncpi.Inspect(p, func(p asyncpi.Process) bool {
+		switch p := p.(type) {
+		case *asyncpi.Send:
+			pos = asyncpi.NamePos(p.Chan)
+		case *asyncpi.Recv:
+			pos = asyncpi.NamePos(p.Chan)
+		}
+		return !pos.IsValid()
+	})
+	return pos
+}
+
+// checkError returns the only error of errs, or a *CheckError.
+func checkError(errs []error) error {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return &CheckError{Errs: errs}
+}
@@ -0,0 +1,322 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"unicode"
+
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/types"
+)
+
+// Global types.
+// This file contains the global types of multiparty sessions:
+//
+//   G ::= end               end of the session
+//       | A->B:<T..>.G      role A sends values of types T.. to role B
+//       | μt.G              recursive session, also written as mu t.G
+//       | t                 session variable
+//
+// A global type is a session Type built from Interaction, End, Rec and Var.
+
+// Interaction is a global type where role From sends values to role To
+// then continues as Cont.
+type Interaction struct {
+	From, To string
+	Payload  []types.Type
+	Cont     Type
+}
+
+func (i *Interaction) String() string {
+	var buf bytes.Buffer
+	buf.WriteRune('<')
+	for j, t := range i.Payload {
+		if j > 0 {
+			buf.WriteRune(',')
+		}
+		buf.WriteString(t.String())
+	}
+	buf.WriteRune('>')
+	return fmt.Sprintf("%s->%s:%s.%s", i.From, i.To, buf.String(), i.Cont)
+}
+
+// Roles returns the roles of global type g in order of appearance.
+func Roles(g Type) []string {
+	var roles []string
+	seen := make(map[string]bool)
+	for {
+		switch t := g.(type) {
+		case *Interaction:
+			for _, r := range []string{t.From, t.To} {
+				if !seen[r] {
+					seen[r] = true
+					roles = append(roles, r)
+				}
+			}
+			g = t.Cont
+			continue
+		case *Rec:
+			g = t.Body
+			continue
+		}
+		return roles
+	}
+}
+
+// Project returns the local type of role in global type g, i.e. the
+// interactions of g which role sends or receives. A recursive session
+// without interactions of role is end.
+func Project(g Type, role string) Type {
+	switch t := g.(type) {
+	case *Interaction:
+		cont := Project(t.Cont, role)
+		switch role {
+		case t.From:
+			return &Send{Role: t.To, Payload: t.Payload, Cont: cont}
+		case t.To:
+			return &Recv{Role: t.From, Payload: t.Payload, Cont: cont}
+		}
+		return cont
+	case *Rec:
+		body := Project(t.Body, role)
+		if !hasAction(body) {
+			return End{}
+		}
+		return &Rec{Var: t.Var, Body: body}
+	}
+	return g
+}
+
+// hasAction returns true if session type t sends or receives.
+func hasAction(t Type) bool {
+	switch t := t.(type) {
+	case *Send, *Recv:
+		return true
+	case *Rec:
+		return hasAction(t.Body)
+	}
+	return false
+}
+
+// ParseError is the type of error when parsing a global type.
+type ParseError struct {
+	Pos asyncpi.Pos
+	Err string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Parse failed at %s: %s", e.Pos, e.Err)
+}
+
+// ParseGlobal parses a global type from r, e.g.
+//
+//     A->B:<int>.B->C:<>.end
+//
+func ParseGlobal(r io.Reader) (Type, error) {
+	p := &globalParser{r: bufio.NewReader(r), pos: asyncpi.Pos{Line: 1}}
+	p.next()
+	g, err := p.global(nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != "" {
+		return nil, p.errorf("unexpected %q after end of global type", p.tok)
+	}
+	return g, nil
+}
+
+// globalParser is a recursive descent parser of global types.
+type globalParser struct {
+	r      *bufio.Reader
+	pos    asyncpi.Pos // Position of the next rune.
+	tok    string      // Current token, empty at the end of input.
+	tokPos asyncpi.Pos // Position of the current token.
+	ident  bool        // Current token is an identifier.
+}
+
+func (p *globalParser) read() rune {
+	ch, _, err := p.r.ReadRune()
+	if err != nil {
+		return 0
+	}
+	if ch == '\n' {
+		p.pos.Line++
+		p.pos.Col = 0
+	} else {
+		p.pos.Col++
+	}
+	return ch
+}
+
+func (p *globalParser) peek() rune {
+	ch, _, err := p.r.ReadRune()
+	if err != nil {
+		return 0
+	}
+	p.r.UnreadRune()
+	return ch
+}
+
+// next reads the next token.
+func (p *globalParser) next() {
+	for unicode.IsSpace(p.peek()) {
+		p.read()
+	}
+	ch := p.read()
+	p.tokPos, p.ident = p.pos, false
+	switch {
+	case ch == 0:
+		p.tok = ""
+		p.tokPos.Col++
+	case ch == 'μ':
+		p.tok = "μ"
+	case ch == '-' && p.peek() == '>':
+		p.read()
+		p.tok = "->"
+	case unicode.IsLetter(ch) || ch == '_':
+		var buf bytes.Buffer
+		buf.WriteRune(ch)
+		for next := p.peek(); unicode.IsLetter(next) || unicode.IsDigit(next) || next == '_'; next = p.peek() {
+			buf.WriteRune(p.read())
+		}
+		p.tok, p.ident = buf.String(), true
+	default:
+		p.tok = string(ch)
+	}
+}
+
+func (p *globalParser) errorf(format string, a ...interface{}) error {
+	return p.errorAt(p.tokPos, format, a...)
+}
+
+func (p *globalParser) errorAt(pos asyncpi.Pos, format string, a ...interface{}) error {
+	return &ParseError{Pos: pos, Err: fmt.Sprintf(format, a...)}
+}
+
+func (p *globalParser) expect(tok string) error {
+	if p.tok != tok {
+		return p.errorf("expects %q but got %q", tok, p.tok)
+	}
+	p.next()
+	return nil
+}
+
+func (p *globalParser) name() (string, error) {
+	if !p.ident {
+		return "", p.errorf("expects a name but got %q", p.tok)
+	}
+	name := p.tok
+	p.next()
+	return name, nil
+}
+
+// global parses a global type, where vars are the bound variables
+// and whether each is guarded by an interaction.
+func (p *globalParser) global(vars map[string]bool) (Type, error) {
+	switch {
+	case p.tok == "end":
+		p.next()
+		return End{}, nil
+	case p.tok == "μ" || p.tok == "mu":
+		p.next()
+		v, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("."); err != nil {
+			return nil, err
+		}
+		inner := map[string]bool{v: false}
+		for u := range vars {
+			if u != v {
+				inner[u] = vars[u]
+			}
+		}
+		body, err := p.global(inner)
+		if err != nil {
+			return nil, err
+		}
+		return &Rec{Var: v, Body: body}, nil
+	}
+	fromPos := p.tokPos
+	from, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != "->" {
+		guarded, bound := vars[from]
+		switch {
+		case !bound:
+			return nil, p.errorAt(fromPos, "unbound variable %s", from)
+		case !guarded:
+			return nil, p.errorAt(fromPos, "unguarded variable %s", from)
+		}
+		return &Var{Name: from}, nil
+	}
+	p.next()
+	toPos := p.tokPos
+	to, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if from == to {
+		return nil, p.errorAt(toPos, "role %s interacts with itself", from)
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	payload, err := p.payload()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("."); err != nil {
+		return nil, err
+	}
+	guarded := make(map[string]bool)
+	for v := range vars {
+		guarded[v] = true
+	}
+	cont, err := p.global(guarded)
+	if err != nil {
+		return nil, err
+	}
+	return &Interaction{From: from, To: to, Payload: payload, Cont: cont}, nil
+}
+
+// payload parses <T1,T2..> where each T is the name of a base type.
+func (p *globalParser) payload() ([]types.Type, error) {
+	if err := p.expect("<"); err != nil {
+		return nil, err
+	}
+	var ts []types.Type
+	for p.tok != ">" {
+		if len(ts) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		t, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, types.NewBase(t))
+	}
+	p.next()
+	return ts, nil
+}
@@ -0,0 +1,141 @@
+package session
+
+import (
+	"strings"
+	"testing"
+
+	"go.nickng.io/asyncpi"
+)
+
+func TestParseGlobal(t *testing.T) {
+	tests := []struct {
+		Global, Want string
+	}{
+		{"A->B:<int>.B->C:<>.end", "A->B:<int>.B->C:<>.end"},
+		{"mu t.A->B:<int,string>.t", "μt.A->B:<int,string>.t"},
+		{"μt. A -> B : <> . μu.B->A:<>.t", "μt.A->B:<>.μu.B->A:<>.t"},
+	}
+	for _, test := range tests {
+		g, err := ParseGlobal(strings.NewReader(test.Global))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, got := test.Want, g.String(); want != got {
+			t.Errorf("expects %s but got %s", want, got)
+		}
+	}
+}
+
+func TestParseGlobalError(t *testing.T) {
+	tests := []struct {
+		Global, Err string
+	}{
+		{"A->B:<int>", `Parse failed at 1:11: expects "." but got ""`},
+		{"A->A:<>.end", "Parse failed at 1:4: role A interacts with itself"},
+		{"A->B:<>.t", "Parse failed at 1:9: unbound variable t"},
+		{"μt.t", "Parse failed at 1:4: unguarded variable t"},
+		{"end end", `Parse failed at 1:5: unexpected "end" after end of global type`},
+	}
+	for _, test := range tests {
+		_, err := ParseGlobal(strings.NewReader(test.Global))
+		if err == nil {
+			t.Errorf("expects error %s but got nil", test.Err)
+			continue
+		}
+		if want, got := test.Err, err.Error(); want != got {
+			t.Errorf("expects error %s but got %s", want, got)
+		}
+	}
+}
+
+func TestProject(t *testing.T) {
+	g, err := ParseGlobal(strings.NewReader("A->B:<int>.μt.B->C:<>.C->B:<bool>.t"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "A B C", strings.Join(Roles(g), " "); want != got {
+		t.Errorf("expects roles %s but got %s", want, got)
+	}
+	for role, want := range map[string]string{
+		"A": "B!int.end",
+		"B": "A?int.μt.C!<>.C?bool.t",
+		"C": "μt.B?<>.B!bool.t",
+		"D": "end",
+	} {
+		if got := Project(g, role).String(); want != got {
+			t.Errorf("expects projection to %s %s but got %s", role, want, got)
+		}
+	}
+}
+
+// parseRoles parses a Process of parallel components, one for each role.
+func parseRoles(t *testing.T, proc string, roles ...string) (asyncpi.Process, map[string]asyncpi.Process) {
+	p, err := asyncpi.Parse(strings.NewReader(proc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	comps := components(p)
+	if len(comps) != len(roles) {
+		t.Fatalf("expects %d components but got %d", len(roles), len(comps))
+	}
+	m := make(map[string]asyncpi.Process)
+	for i, r := range roles {
+		m[r] = comps[i]
+	}
+	return p, m
+}
+
+func TestConform(t *testing.T) {
+	tests := []struct {
+		Global, Proc string
+	}{
+		{"A->B:<int>.B->C:<int>.end", "(new ab,bc,n:int)(ab<n> | ab(x).bc<x> | bc(y).0)"},
+		{"A->B:<>.B->A:<>.end", "(new s)(b().(s<> | s().0) | s().s<>)"},
+		{"μt.A->B:<>.t", "(new s)(!s<> | !s().0)"},
+		// Channel internal to C.
+		{"A->C:<>.end", "(new ac,c)(ac<> | ac().(c<> | c().0))"},
+	}
+	for _, test := range tests {
+		g, err := ParseGlobal(strings.NewReader(test.Global))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, roles := parseRoles(t, test.Proc, Roles(g)...)
+		if err := Conform(p, g, roles); err != nil {
+			t.Errorf("expects %s to conform to %s but got %v", test.Proc, test.Global, err)
+		}
+	}
+}
+
+func TestConformError(t *testing.T) {
+	tests := []struct {
+		Global, Proc string
+		Roles        []string
+		Err          string
+	}{
+		{"A->B:<int>.B->C:<int>.end", "(new ab,bc,n:string)(ab<n> | ab(x).bc<x> | bc(y).0)", []string{"A", "B", "C"},
+			"cannot check conformance: 1:22: role A has local type B!string.end but expects B!int.end"},
+		{"A->B:<>.B->C:<>.end", "(new ab,bc)(ab<> | ab().0 | bc().0)", []string{"A", "B", "C"}, "cannot check conformance: 2 session error(s)\n" +
+			"\t1:20: role B has local type A?<>.end but expects A?<>.C!<>.end\n" +
+			"\t1:29: role C has local type end but expects B?<>.end"},
+		{"A->B:<>.end", "(new s)(s<> | s().0 | s().0)", []string{"A", "B", "C"},
+			"cannot check conformance: role C is not in the global type"},
+		{"A->B:<>.B->C:<>.end", "(new s)(s<> | s().s<> | s().0)", []string{"A", "B", "C"},
+			"cannot check conformance: 1:9: channel s is used by roles A, B, C but expects 2"},
+	}
+	for _, test := range tests {
+		g, err := ParseGlobal(strings.NewReader(test.Global))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, roles := parseRoles(t, test.Proc, test.Roles...)
+		err = Conform(p, g, roles)
+		if err == nil {
+			t.Errorf("expects error %s but got nil", test.Err)
+			continue
+		}
+		if want, got := test.Err, err.Error(); want != got {
+			t.Errorf("expects error %s but got %s", want, got)
+		}
+	}
+}
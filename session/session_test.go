@@ -0,0 +1,100 @@
+package session
+
+import (
+	"strings"
+	"testing"
+
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/types"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		Proc     string
+		Sessions []string
+	}{
+		{`(new s)(s(x).(s<x> | s(y).0) | b(z).(s<z> | s(w).s<w>))`, []string{
+			"1:6: s: ?_.!_.?_.end | !_.?_.!_.end",
+		}},
+		{`(new s,a:int)(!s(x).0 | !s<a>)`, []string{
+			"1:6: s: μt0.?_.t0 | μt0.!int.t0",
+		}},
+		{`(new s)(s().s<a,a> | b().(s<> | s(x,y).0))`, []string{
+			"1:6: s: ?<>.!<_,_>.end | !<>.?<_,_>.end",
+		}},
+		{`(new r)(a<r> | r().0)`, nil},
+		{`(new s)(new t)(s(x).t<x> | b().(s<a> | t(y).0))`, []string{
+			"1:6: s: ?_.end | !_.end",
+			"1:13: t: !_.end | ?_.end",
+		}},
+	}
+	for _, test := range tests {
+		p, err := asyncpi.Parse(strings.NewReader(test.Proc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions, err := Check(p)
+		if err != nil {
+			t.Fatalf("expects %s to check but got %v", test.Proc, err)
+		}
+		var got []string
+		for _, s := range sessions {
+			got = append(got, s.String())
+		}
+		if want, got := strings.Join(test.Sessions, "\n"), strings.Join(got, "\n"); want != got {
+			t.Errorf("expects sessions of %s:\n%s\nbut got\n%s", test.Proc, want, got)
+		}
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	tests := []struct {
+		Proc string
+		Err  string
+	}{
+		{`(new s)(s(x).0 | b().(s<b> | s(y).0))`,
+			"cannot check sessions: 1:6: endpoints of session s are not dual: ?_.end at 1:9 and !_.?_.end at 1:23"},
+		{`(new s)(s(x).0 | b().s<b,b>)`,
+			"cannot check sessions: 1:6: endpoints of session s are not dual: ?_.end at 1:9 and !<_,_>.end at 1:22"},
+		{`(new s)(s(x).0 | s<a> | s<b>)`,
+			"cannot check sessions: 1:6: session s has 3 endpoint(s) but expects 2"},
+		{`(new s)(s(x).0 | b().(s<a> | s<b>))`,
+			"cannot check sessions: 1:30: session s is used in parallel by the same endpoint"},
+		{`(new s,t)(s(x).0 | t<>)`, "cannot check sessions: 2 session error(s)\n" +
+			"\t1:6: session s has 1 endpoint(s) but expects 2\n" +
+			"\t1:8: session t has 1 endpoint(s) but expects 2"},
+	}
+	for _, test := range tests {
+		p, err := asyncpi.Parse(strings.NewReader(test.Proc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = Check(p)
+		if err == nil {
+			t.Errorf("expects error %s but got nil", test.Err)
+			continue
+		}
+		if want, got := test.Err, err.Error(); want != got {
+			t.Errorf("expects error %s but got %s", want, got)
+		}
+	}
+}
+
+func TestDual(t *testing.T) {
+	T := types.NewBase("T")
+	s := &Rec{Var: "t", Body: &Send{Payload: []types.Type{T}, Cont: &Recv{Cont: &Var{Name: "t"}}}}
+	if want, got := "μt.?T.!<>.t", Dual(s).String(); want != got {
+		t.Errorf("expects dual %s but got %s", want, got)
+	}
+	// Unfolding once is equal.
+	u := &Recv{Payload: []types.Type{T}, Cont: &Send{Cont: Dual(s)}}
+	if !IsDual(s, u) {
+		t.Errorf("expects %s dual of %s", s, u)
+	}
+	if IsDual(s, Dual(Dual(s))) {
+		t.Errorf("expects %s not dual of itself", s)
+	}
+	if !IsDual(&Send{Payload: []types.Type{nil}, Cont: End{}}, &Recv{Payload: []types.Type{T}, Cont: End{}}) {
+		t.Errorf("expects unknown payload dual of any payload")
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package session provides session types for the channels of a Process,
+// a checker that the two endpoints of each restricted channel follow
+// dual protocols, and multiparty session types of roles in a Process.
+//
+// A session type is the protocol of an endpoint of a channel:
+//
+//   S ::= end        end of the session
+//       | !<T..>.S   send values of types T.. then continue as S
+//       | ?<T..>.S   receive values of types T.. then continue as S
+//       | μt.S       recursive session
+//       | t          session variable
+//
+// The calculus has no labelled choice, so there are no branching
+// session types.
+//
+// For more than two parties, a global type describes the interactions
+// between roles, which is projected to the local type of each role.
+// A local type is a session type where each send and receive is
+// with a role, e.g. B!int.C?<>.end.
+package session // import "go.nickng.io/asyncpi/session"
+
+import (
+	"bytes"
+	"fmt"
+
+	"go.nickng.io/asyncpi/types"
+)
+
+// Type is a session type.
+type Type interface {
+	String() string
+}
+
+// End is the end of a session.
+type End struct{}
+
+func (End) String() string { return "end" }
+
+// Send is a session which sends values then continues as Cont.
+type Send struct {
+	Role    string       // Role sent to in a local type, empty otherwise.
+	Payload []types.Type // Types of the values, nil if not known.
+	Cont    Type
+}
+
+func (s *Send) String() string {
+	return fmt.Sprintf("%s!%s.%s", s.Role, payloadString(s.Payload), s.Cont)
+}
+
+// Recv is a session which receives values then continues as Cont.
+type Recv struct {
+	Role    string       // Role received from in a local type, empty otherwise.
+	Payload []types.Type // Types of the values, nil if not known.
+	Cont    Type
+}
+
+func (r *Recv) String() string {
+	return fmt.Sprintf("%s?%s.%s", r.Role, payloadString(r.Payload), r.Cont)
+}
+
+// Rec is a recursive session μVar.Body.
+type Rec struct {
+	Var  string
+	Body Type
+}
+
+func (r *Rec) String() string {
+	return fmt.Sprintf("μ%s.%s", r.Var, r.Body)
+}
+
+// Var is a session variable bound by a Rec.
+type Var struct {
+	Name string
+}
+
+func (v *Var) String() string { return v.Name }
+
+// payloadString writes a payload as T for a single value,
+// or <T1,T2..> otherwise. Types not known are written as _.
+func payloadString(ts []types.Type) string {
+	str := func(t types.Type) string {
+		if t == nil {
+			return "_"
+		}
+		return t.String()
+	}
+	if len(ts) == 1 {
+		return str(ts[0])
+	}
+	var buf bytes.Buffer
+	buf.WriteRune('<')
+	for i, t := range ts {
+		if i > 0 {
+			buf.WriteRune(',')
+		}
+		buf.WriteString(str(t))
+	}
+	buf.WriteRune('>')
+	return buf.String()
+}
+
+// Dual returns the dual of session type t, i.e. the protocol of the
+// other endpoint, where sends and receives are swapped.
+func Dual(t Type) Type {
+	switch t := t.(type) {
+	case *Send:
+		return &Recv{Role: t.Role, Payload: t.Payload, Cont: Dual(t.Cont)}
+	case *Recv:
+		return &Send{Role: t.Role, Payload: t.Payload, Cont: Dual(t.Cont)}
+	case *Rec:
+		return &Rec{Var: t.Var, Body: Dual(t.Body)}
+	}
+	return t
+}
+
+// IsDual returns true if session types t and u are dual.
+func IsDual(t, u Type) bool {
+	return IsEqual(t, Dual(u))
+}
+
+// IsEqual returns true if session types t and u are equal up to the
+// unfolding of recursive sessions. Payload types that are not known
+// are equal to any type.
+func IsEqual(t, u Type) bool {
+	return isEqual(t, u, make(map[[2]string]bool))
+}
+
+// isEqual compares t and u coinductively, where assumed are the pairs
+// of types already being compared.
+func isEqual(t, u Type, assumed map[[2]string]bool) bool {
+	key := [2]string{t.String(), u.String()}
+	if assumed[key] {
+		return true
+	}
+	assumed[key] = true
+	if rec, ok := t.(*Rec); ok {
+		return isEqual(unfold(rec), u, assumed)
+	}
+	if rec, ok := u.(*Rec); ok {
+		return isEqual(t, unfold(rec), assumed)
+	}
+	switch t := t.(type) {
+	case End:
+		_, ok := u.(End)
+		return ok
+	case *Send:
+		s, ok := u.(*Send)
+		return ok && t.Role == s.Role && payloadEqual(t.Payload, s.Payload) && isEqual(t.Cont, s.Cont, assumed)
+	case *Recv:
+		r, ok := u.(*Recv)
+		return ok && t.Role == r.Role && payloadEqual(t.Payload, r.Payload) && isEqual(t.Cont, r.Cont, assumed)
+	case *Var:
+		v, ok := u.(*Var)
+		return ok && t.Name == v.Name
+	}
+	return false
+}
+
+func payloadEqual(ts, us []types.Type) bool {
+	if len(ts) != len(us) {
+		return false
+	}
+	for i := range ts {
+		if ts[i] != nil && us[i] != nil && !types.IsEqual(ts[i], us[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// unfold returns the body of the recursive session r
+// with r in place of its variable.
+func unfold(r *Rec) Type {
+	return subst(r.Body, r.Var, r)
+}
+
+// subst returns t with u in place of the free variable v.
+func subst(t Type, v string, u Type) Type {
+	switch t := t.(type) {
+	case *Send:
+		return &Send{Role: t.Role, Payload: t.Payload, Cont: subst(t.Cont, v, u)}
+	case *Recv:
+		return &Recv{Role: t.Role, Payload: t.Payload, Cont: subst(t.Cont, v, u)}
+	case *Rec:
+		if t.Var == v {
+			return t
+		}
+		return &Rec{Var: t.Var, Body: subst(t.Body, v, u)}
+	case *Var:
+		if t.Name == v {
+			return u
+		}
+	}
+	return t
+}
@@ -0,0 +1,274 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asyncpi
+
+import (
+	"fmt"
+
+	"go.nickng.io/asyncpi/internal/name"
+)
+
+// Substitution.
+// This file contains capture-avoiding substitution and alpha-equivalence.
+
+// Substitute returns a copy of Process p where every free occurrence of
+// a Name x in the domain of s is replaced by s[x].
+//
+// Names are matched by their Ident, so the keys of s do not need to be
+// the same Name instances as the occurrences in p. Bound names that
+// would capture a substituted Name are renamed to fresh names, i.e.
+//
+//     ((νy)x<y>){y/x} = (νy_1)y<y_1>
+//
+// The input Process p is not modified, but unchanged Names in p may be
+// shared with the returned Process. Unknown Process implementations are
+// returned as-is.
+func Substitute(p Process, s map[Name]Name) Process {
+	sub := make(map[string]Name, len(s))
+	used := make(map[string]bool)
+	for x, v := range s {
+		sub[x.Ident()] = v
+		used[x.Ident()] = true
+		used[v.Ident()] = true
+	}
+	collectIdents(p, used)
+	return substitute(p, sub, &freshNamer{used: used})
+}
+
+func substitute(p Process, sub map[string]Name, f *freshNamer) Process {
+	switch p := p.(type) {
+	case *NilProcess:
+		return NewNilProcess()
+	case *Par:
+		procs := make([]Process, len(p.Procs))
+		for i := range p.Procs {
+			procs[i] = substitute(p.Procs[i], sub, f)
+		}
+		return &Par{Procs: procs}
+	case *Recv:
+		inner, vars := avoidCapture(p.Vars, p.Cont, sub, f)
+		recv := NewRecv(substName(p.Chan, sub), substitute(p.Cont, inner, f))
+		recv.SetVars(vars)
+		return recv
+	case *Repeat:
+		return NewRepeat(substitute(p.Proc, sub, f))
+	case *Restrict:
+		inner, names := avoidCapture([]Name{p.Name}, p.Proc, sub, f)
+		return NewRestrict(names[0], substitute(p.Proc, inner, f))
+	case *Send:
+		send := NewSend(substName(p.Chan, sub))
+		vals := make([]Name, len(p.Vals))
+		for i := range p.Vals {
+			vals[i] = substName(p.Vals[i], sub)
+		}
+		send.SetVals(vals)
+		return send
+	default:
+		return p
+	}
+}
+
+// substName returns the replacement of n in sub, or n if n is not replaced.
+func substName(n Name, sub map[string]Name) Name {
+	if v, replaced := sub[n.Ident()]; replaced {
+		return v
+	}
+	return n
+}
+
+// avoidCapture returns the substitution sub to apply under the binders,
+// and the (possibly renamed) binders.
+//
+// Binders shadow the substitution of the same Ident, and binders that
+// would capture the replacement of a free name in body are renamed.
+func avoidCapture(binders []Name, body Process, sub map[string]Name, f *freshNamer) (map[string]Name, []Name) {
+	inner := make(map[string]Name, len(sub))
+	for x, v := range sub {
+		inner[x] = v
+	}
+	for _, b := range binders {
+		delete(inner, b.Ident())
+	}
+	if len(inner) == 0 {
+		return inner, binders
+	}
+	fn := freeIdents(body)
+	replacing := make(map[string]bool)
+	for x, v := range inner {
+		if fn[x] {
+			replacing[v.Ident()] = true
+		}
+	}
+	renamed := make([]Name, len(binders))
+	for i, b := range binders {
+		renamed[i] = b
+		if replacing[b.Ident()] {
+			renamed[i] = renameName(b, f.fresh(b.Ident()))
+			inner[b.Ident()] = renamed[i]
+		}
+	}
+	return inner, renamed
+}
+
+// renameName returns a new Name with the given ident, preserving the
+// type hint, position and annotations of n if there are any.
+func renameName(n Name, ident string) Name {
+	if clone, ok := name.Copy(n); ok {
+		clone.(name.Setter).SetName(ident)
+		return clone.(Name)
+	}
+	if th, hasHint := n.(name.TypeHinter); hasHint {
+		return newHintedName(ident, th.TypeHint(), NamePos(n))
+	}
+	return newName(ident, NamePos(n))
+}
+
+// freshNamer generates names that are not already used.
+type freshNamer struct {
+	used map[string]bool
+}
+
+// fresh returns a new ident based on ident that is not used.
+func (f *freshNamer) fresh(ident string) string {
+	for i := 1; ; i++ {
+		s := fmt.Sprintf("%s_%d", ident, i)
+		if !f.used[s] {
+			f.used[s] = true
+			return s
+		}
+	}
+}
+
+// collectIdents adds the Ident of all Names (free or bound) in p to idents.
+func collectIdents(p Process, idents map[string]bool) {
+	InspectNames(p, func(n Name, _ *Scope) {
+		idents[n.Ident()] = true
+	})
+}
+
+// freeIdents returns the Idents of the syntactically free Names in p.
+//
+// Unlike FreeNames, the result does not depend on the Name implementation
+// (e.g. sorts), only on the binders in p.
+func freeIdents(p Process) map[string]bool {
+	fn := make(map[string]bool)
+	use := func(n Name, s *Scope) {
+		if bn, _ := s.Lookup(n.Ident()); bn == nil {
+			fn[n.Ident()] = true
+		}
+	}
+	Apply(p, func(c *Cursor) bool {
+		switch p := c.Node().(type) {
+		case *Recv:
+			use(p.Chan, c.Scope())
+		case *Send:
+			use(p.Chan, c.Scope())
+			for _, v := range p.Vals {
+				use(v, c.Scope())
+			}
+		}
+		return true
+	}, nil)
+	return fn
+}
+
+// AlphaEquivalent returns true if Process p and q are equal up to
+// renaming of bound names.
+//
+// Free names are compared by their Ident, and the components of
+// parallel compositions are compared in order.
+func AlphaEquivalent(p, q Process) bool {
+	return alphaEq(p, q, alphaEnv{})
+}
+
+// alphaEnv keeps track of the binders of both sides of an
+// alpha-equivalence comparison, innermost last.
+type alphaEnv struct {
+	p, q []string
+}
+
+// bind returns a new alphaEnv extended with binders x and y.
+func (e alphaEnv) bind(x, y Name) alphaEnv {
+	return alphaEnv{
+		p: append(e.p[:len(e.p):len(e.p)], x.Ident()),
+		q: append(e.q[:len(e.q):len(e.q)], y.Ident()),
+	}
+}
+
+// same returns true if x and y are the same free name,
+// or are bound by corresponding binders.
+func (e alphaEnv) same(x, y Name) bool {
+	i, j := lastIndex(e.p, x.Ident()), lastIndex(e.q, y.Ident())
+	if i < 0 && j < 0 {
+		return x.Ident() == y.Ident()
+	}
+	return i == j
+}
+
+func lastIndex(idents []string, ident string) int {
+	for i := len(idents) - 1; i >= 0; i-- {
+		if idents[i] == ident {
+			return i
+		}
+	}
+	return -1
+}
+
+func alphaEq(p, q Process, env alphaEnv) bool {
+	switch p := p.(type) {
+	case *NilProcess:
+		_, ok := q.(*NilProcess)
+		return ok
+	case *Par:
+		q, ok := q.(*Par)
+		if !ok || len(p.Procs) != len(q.Procs) {
+			return false
+		}
+		for i := range p.Procs {
+			if !alphaEq(p.Procs[i], q.Procs[i], env) {
+				return false
+			}
+		}
+		return true
+	case *Recv:
+		q, ok := q.(*Recv)
+		if !ok || len(p.Vars) != len(q.Vars) || !env.same(p.Chan, q.Chan) {
+			return false
+		}
+		for i := range p.Vars {
+			env = env.bind(p.Vars[i], q.Vars[i])
+		}
+		return alphaEq(p.Cont, q.Cont, env)
+	case *Repeat:
+		q, ok := q.(*Repeat)
+		return ok && alphaEq(p.Proc, q.Proc, env)
+	case *Restrict:
+		q, ok := q.(*Restrict)
+		return ok && alphaEq(p.Proc, q.Proc, env.bind(p.Name, q.Name))
+	case *Send:
+		q, ok := q.(*Send)
+		if !ok || len(p.Vals) != len(q.Vals) || !env.same(p.Chan, q.Chan) {
+			return false
+		}
+		for i := range p.Vals {
+			if !env.same(p.Vals[i], q.Vals[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
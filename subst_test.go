@@ -0,0 +1,100 @@
+package asyncpi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSubstituteFree(t *testing.T) {
+	p, err := Parse(strings.NewReader(`x<y> | a(y).x<y>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := p.Calculi()
+	q := Substitute(p, map[Name]Name{newNames("x")[0]: newNames("b")[0]})
+	if want, got := `(b<y> | a(y).b<y>)`, q.Calculi(); want != got {
+		t.Fatalf("expects %s but got %s", want, got)
+	}
+	if orig != p.Calculi() {
+		t.Fatalf("expects substitution to not modify %s but got %s", orig, p.Calculi())
+	}
+}
+
+func TestSubstituteShadowed(t *testing.T) {
+	p, err := Parse(strings.NewReader(`(new x)x<x>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := Substitute(p, map[Name]Name{newNames("x")[0]: newNames("b")[0]})
+	if want, got := `(new x)x<x>`, q.Calculi(); want != got {
+		t.Fatalf("expects %s but got %s", want, got)
+	}
+}
+
+func TestSubstituteCaptureAvoiding(t *testing.T) {
+	p, err := Parse(strings.NewReader(`(new y)x<y> | a(y).x<y>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := Substitute(p, map[Name]Name{newNames("x")[0]: newNames("y")[0]})
+	want, err := Parse(strings.NewReader(`(new z)y<z> | a(w).y<w>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !AlphaEquivalent(want, q) {
+		t.Fatalf("expects %s to be alpha-equivalent to %s", q.Calculi(), want.Calculi())
+	}
+}
+
+func TestReduceCaptureAvoiding(t *testing.T) {
+	const proc = `a<y> | a(x).(new y)x<y>`
+	p, err := Parse(strings.NewReader(proc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := reduceOnce(p); err != nil {
+		t.Fatalf("cannot reduce: %v", err)
+	} else if !changed {
+		t.Fatalf("expects %s to reduce but unchanged", p.Calculi())
+	}
+	p, err = SimplifyBySC(p)
+	if err != nil {
+		t.Fatalf("cannot simplify process: %v", err)
+	}
+	want, err := Parse(strings.NewReader(`(new z)y<z>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !AlphaEquivalent(want, p) {
+		t.Fatalf("expects %s to reduce to %s but got %s", proc, want.Calculi(), p.Calculi())
+	}
+}
+
+func TestAlphaEquivalent(t *testing.T) {
+	tests := []struct {
+		P, Q  string
+		Equiv bool
+	}{
+		{`(new a)a<b>`, `(new c)c<b>`, true},
+		{`(new a)a<b>`, `(new b)b<b>`, false},
+		{`a(x,y).x<y>`, `a(u,v).u<v>`, true},
+		{`a(x,y).x<y>`, `a(u,v).v<u>`, false},
+		{`a(x).x<>`, `b(x).x<>`, false},
+		{`!(new a)(a<> | a().0)`, `!(new b)(b<> | b().0)`, true},
+		{`a<> | b<>`, `b<> | a<>`, false},
+		{`(new a)a(a).a<>`, `(new b)b(c).c<>`, true},
+	}
+	for _, test := range tests {
+		p, err := Parse(strings.NewReader(test.P))
+		if err != nil {
+			t.Fatal(err)
+		}
+		q, err := Parse(strings.NewReader(test.Q))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, got := test.Equiv, AlphaEquivalent(p, q); want != got {
+			t.Errorf("expects AlphaEquivalent(%s, %s) to be %t but got %t", test.P, test.Q, want, got)
+		}
+	}
+}
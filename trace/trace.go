@@ -0,0 +1,136 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package trace records the execution of Go code generated from
+// asynchronous π-calculus Processes with tracing enabled.
+//
+// The generated code emits an Event at every send, receive, spawn and
+// replica creation to the Sink set by SetSink, which writes each Event as
+// a line of JSON to standard error by default, e.g.
+//
+//     {"goroutine":1,"kind":"send","chan":"a","payload":["x"],"pos":"1:9"}
+//
+// Goroutines are numbered by the trace package in the order they are
+// spawned, starting from 0.
+package trace // import "go.nickng.io/asyncpi/trace"
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// Kind is the kind of an Event.
+type Kind string
+
+// Kinds of Event.
+const (
+	KindSend      Kind = "send"      // Payload sent on Chan.
+	KindRecv      Kind = "recv"      // Payload received on Chan.
+	KindSpawn     Kind = "spawn"     // Goroutine Child spawned.
+	KindReplicate Kind = "replicate" // Replica Child of a replicated Process created.
+)
+
+// Event is an operation of the generated code.
+type Event struct {
+	Goroutine int64    `json:"goroutine"`         // Goroutine of the operation.
+	Kind      Kind     `json:"kind"`              // Kind of the operation.
+	Chan      string   `json:"chan,omitempty"`    // Name of the channel of a send or receive.
+	Payload   []string `json:"payload,omitempty"` // Names sent or received.
+	Child     int64    `json:"child,omitempty"`   // Goroutine spawned or replica created.
+	Pos       string   `json:"pos,omitempty"`     // Position of the Process in its source.
+}
+
+// Sink receives the Events of the generated code.
+// Emit may be called concurrently.
+type Sink interface {
+	Emit(e Event)
+}
+
+// JSONSink is a Sink writing each Event as a line of JSON.
+type JSONSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONSink returns a JSONSink writing to w.
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{enc: json.NewEncoder(w)}
+}
+
+// Emit writes the Event e as a line of JSON.
+func (s *JSONSink) Emit(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enc.Encode(e)
+}
+
+var (
+	mu         sync.RWMutex
+	sink       Sink = NewJSONSink(os.Stderr)
+	goroutines int64
+)
+
+// SetSink sets the Sink of the Events emitted afterwards.
+// A nil Sink discards the Events.
+func SetSink(s Sink) {
+	mu.Lock()
+	defer mu.Unlock()
+	sink = s
+}
+
+func emit(e Event) {
+	mu.RLock()
+	s := sink
+	mu.RUnlock()
+	if s != nil {
+		s.Emit(e)
+	}
+}
+
+// Go returns the number of a new goroutine which is not spawned by a
+// traced goroutine, e.g. the goroutine running the generated code.
+func Go() int64 {
+	return atomic.AddInt64(&goroutines, 1) - 1
+}
+
+// Spawn emits the spawn by goroutine g of the Process at pos, and
+// returns the number of the new goroutine.
+func Spawn(g int64, pos string) int64 {
+	child := Go()
+	emit(Event{Goroutine: g, Kind: KindSpawn, Child: child, Pos: pos})
+	return child
+}
+
+// Replicate emits the creation by goroutine g of a replica of the
+// replicated Process at pos, and returns the number of the replica.
+func Replicate(g int64, pos string) int64 {
+	child := Go()
+	emit(Event{Goroutine: g, Kind: KindReplicate, Child: child, Pos: pos})
+	return child
+}
+
+// Sent emits the output of the names payload on the channel named ch
+// by goroutine g, where pos is the position of the output.
+func Sent(g int64, ch, pos string, payload ...string) {
+	emit(Event{Goroutine: g, Kind: KindSend, Chan: ch, Payload: payload, Pos: pos})
+}
+
+// Received emits the input of the names payload on the channel named ch
+// by goroutine g, where pos is the position of the input.
+func Received(g int64, ch, pos string, payload ...string) {
+	emit(Event{Goroutine: g, Kind: KindRecv, Chan: ch, Payload: payload, Pos: pos})
+}
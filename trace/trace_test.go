@@ -0,0 +1,47 @@
+package trace
+
+import (
+	"bytes"
+	"testing"
+)
+
+type recorder []Event
+
+func (r *recorder) Emit(e Event) { *r = append(*r, e) }
+
+func TestJSONSink(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewJSONSink(&buf)
+	s.Emit(Event{Goroutine: 1, Kind: KindSend, Chan: "a", Payload: []string{"x"}, Pos: "1:9"})
+	s.Emit(Event{Goroutine: 0, Kind: KindSpawn, Child: 1})
+	want := `{"goroutine":1,"kind":"send","chan":"a","payload":["x"],"pos":"1:9"}
+{"goroutine":0,"kind":"spawn","child":1}
+`
+	if buf.String() != want {
+		t.Errorf("expects %s but got %s", want, buf.String())
+	}
+}
+
+func TestSetSink(t *testing.T) {
+	var r recorder
+	SetSink(&r)
+	defer SetSink(NewJSONSink(nil))
+	g := Go()
+	child := Spawn(g, "1:1")
+	Sent(child, "a", "1:1", "x", "y")
+	Received(g, "a", "1:8")
+	SetSink(nil)
+	Replicate(g, "1:8") // Discarded.
+	if len(r) != 3 {
+		t.Fatalf("expects 3 events but got %d", len(r))
+	}
+	if r[0].Kind != KindSpawn || r[0].Goroutine != g || r[0].Child != child || child == g {
+		t.Errorf("expects spawn of %d by %d but got %+v", child, g, r[0])
+	}
+	if r[1].Kind != KindSend || r[1].Goroutine != child || len(r[1].Payload) != 2 {
+		t.Errorf("expects send of x,y by %d but got %+v", child, r[1])
+	}
+	if r[2].Kind != KindRecv || r[2].Payload != nil {
+		t.Errorf("expects receive without payload but got %+v", r[2])
+	}
+}
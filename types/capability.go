@@ -0,0 +1,209 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/internal/errors"
+	"go.nickng.io/asyncpi/internal/name"
+	"go.nickng.io/asyncpi/resolve"
+)
+
+// Capabilities.
+// This file contains the inference of the minimal channel capabilities.
+
+func errInferCap(err error) error {
+	return errors.Wrap(err, "cannot infer capabilities")
+}
+
+// InferCapabilities sets the capability of the channel type of each Name
+// in Process p to the minimal capability the Name needs, i.e. input-only
+// if it is only received on, output-only if it is only sent on.
+//
+// Names which may be transmitted through the same channel must have the
+// same type, so their capabilities are combined, e.g. in
+//
+//     a<b> | a(x).x<> | b().0
+//
+// both b and x are bidirectional.
+//
+// The element types are the types of the names transmitted, so they are
+// narrowed as well, e.g. a is typed chan chan<- struct{} in
+//
+//     a<b> | a(x).x<>
+//
+// where b and x are typed chan<- struct{}, so b can be sent on a.
+// Recursive types are narrowed at every unfolding, e.g. the type of b
+// in b<b> is μt0.chan<- t0.
+//
+// InferCapabilities should be called after Infer. Names which are never
+// used as a channel keep their types.
+func InferCapabilities(p asyncpi.Process) error {
+	info, err := resolve.Resolve(p)
+	if err != nil {
+		return errInferCap(err)
+	}
+	c := newNameClasses(info)
+	for _, o := range info.Occurrences() {
+		tn, ok := o.Name().(TypedName)
+		if !ok {
+			return errInferCap(InferUntypedError{Name: o.Name().Ident()})
+		}
+		if class := c.find(info.ObjectOf(o)); c.types[class] == nil {
+			c.types[class] = deref(tn.Type())
+		}
+	}
+	narrowed := make(map[*resolve.Object]Type)
+	for _, o := range info.Occurrences() {
+		class := c.find(info.ObjectOf(o))
+		if _, isChan := c.types[class].(*Chan); isChan && c.dir[class] != 0 {
+			o.Name().(TypedName).setType(c.typeOf(class, narrowed))
+		}
+	}
+	return nil
+}
+
+// nameClasses partitions names into the classes of names which must
+// have the same type, i.e. names transmitted through the same channel,
+// similar to sortedname.InferSorting.
+type nameClasses struct {
+	parent map[*resolve.Object]*resolve.Object
+	args   map[*resolve.Object][]*resolve.Object // Payload names of a class.
+	dir    map[*resolve.Object]ChanDir           // Capability of a class.
+	types  map[*resolve.Object]Type              // Unified type of a class.
+	reps   map[*resolve.Object]TypedName         // Names referred to by the element types.
+}
+
+func newNameClasses(info *resolve.Info) *nameClasses {
+	c := &nameClasses{
+		parent: make(map[*resolve.Object]*resolve.Object),
+		args:   make(map[*resolve.Object][]*resolve.Object),
+		dir:    make(map[*resolve.Object]ChanDir),
+		types:  make(map[*resolve.Object]Type),
+		reps:   make(map[*resolve.Object]TypedName),
+	}
+	for _, o := range info.Occurrences() {
+		if o.Index != 0 || o.IsBinder() {
+			continue
+		}
+		var dir ChanDir
+		switch o.Proc.(type) {
+		case *asyncpi.Send:
+			dir = SendOnly
+		case *asyncpi.Recv:
+			dir = RecvOnly
+		}
+		payload := payloadObjects(info, o.Proc)
+		ch := c.find(info.ObjectOf(o))
+		c.dir[ch] |= dir
+		if args, ok := c.args[ch]; ok {
+			for i := range args {
+				if i < len(payload) {
+					c.union(args[i], payload[i])
+				}
+			}
+			continue
+		}
+		c.args[ch] = payload
+	}
+	return c
+}
+
+// payloadObjects returns the objects of the values of a Send,
+// or the variables of a Recv.
+func payloadObjects(info *resolve.Info, p asyncpi.Process) []*resolve.Object {
+	var n int
+	switch p := p.(type) {
+	case *asyncpi.Send:
+		n = len(p.Vals)
+	case *asyncpi.Recv:
+		n = len(p.Vars)
+	}
+	objs := make([]*resolve.Object, n)
+	for i := range objs {
+		objs[i] = info.ObjectOf(resolve.Occurrence{Proc: p, Index: i + 1})
+	}
+	return objs
+}
+
+func (c *nameClasses) find(obj *resolve.Object) *resolve.Object {
+	for c.parent[obj] != nil {
+		obj = c.parent[obj]
+	}
+	return obj
+}
+
+// union merges the classes of x and y, and the classes of their payloads.
+func (c *nameClasses) union(x, y *resolve.Object) {
+	type pair struct{ x, y *resolve.Object }
+	work := []pair{{x, y}}
+	for len(work) > 0 {
+		x, y := c.find(work[0].x), c.find(work[0].y)
+		work = work[1:]
+		if x == y {
+			continue
+		}
+		c.parent[y] = x
+		c.dir[x] |= c.dir[y]
+		xargs, xok := c.args[x]
+		yargs, yok := c.args[y]
+		switch {
+		case !xok && yok:
+			c.args[x] = yargs
+		case xok && yok:
+			for i := range xargs {
+				if i < len(yargs) {
+					work = append(work, pair{xargs[i], yargs[i]})
+				}
+			}
+		}
+		delete(c.args, y)
+		delete(c.dir, y)
+	}
+}
+
+// typeOf returns the type of the names of class with the capabilities
+// of the class, where narrowed are the types returned so far. The names
+// of a class which is never used as a channel keep their type.
+func (c *nameClasses) typeOf(class *resolve.Object, narrowed map[*resolve.Object]Type) Type {
+	if t, ok := narrowed[class]; ok {
+		return t
+	}
+	ch, isChan := c.types[class].(*Chan)
+	if !isChan || c.dir[class] == 0 {
+		narrowed[class] = c.types[class]
+		return narrowed[class]
+	}
+	nch := NewChanDir(c.dir[class], ch.elem)
+	narrowed[class] = nch // Set before the element types for recursive types.
+	if args := c.args[class]; len(args) > 0 {
+		ts := make([]Type, len(args))
+		for i, arg := range args {
+			ts[i] = &Reference{ref: c.repOf(c.find(arg), narrowed)}
+		}
+		nch.elem = chanOf(ts).elem
+	}
+	return nch
+}
+
+// repOf returns a name of the type of class for the element types to
+// refer to, similar to the names of the payload types of Infer.
+func (c *nameClasses) repOf(class *resolve.Object, narrowed map[*resolve.Object]Type) TypedName {
+	if _, ok := c.reps[class]; !ok {
+		c.reps[class] = newTypedName(name.New("_"))
+		c.reps[class].setType(c.typeOf(class, narrowed))
+	}
+	return c.reps[class]
+}
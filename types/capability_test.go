@@ -0,0 +1,113 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"go.nickng.io/asyncpi"
+)
+
+func TestInferCapabilities(t *testing.T) {
+	input := "(new a)(new b)(new c)(a<b> | a(x).x<> | c<> | c().0)"
+	proc, err := asyncpi.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Infer(proc); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unify(proc); err != nil {
+		t.Fatal(err)
+	}
+	if err := InferCapabilities(proc); err != nil {
+		t.Fatal(err)
+	}
+	resa := proc.(*asyncpi.Restrict)
+	resb := resa.Proc.(*asyncpi.Restrict)
+	resc := resb.Proc.(*asyncpi.Restrict)
+	if want, got := "chan chan<- struct{}", resa.Name.(TypedName).Type().String(); want != got {
+		t.Errorf("InferCapabilities: expected a typed `%s` but got `%s`", want, got)
+	}
+	if want, got := "chan<- struct{}", resb.Name.(TypedName).Type().String(); want != got {
+		t.Errorf("InferCapabilities: expected b typed `%s` but got `%s`", want, got)
+	}
+	if want, got := "chan struct{}", resc.Name.(TypedName).Type().String(); want != got {
+		t.Errorf("InferCapabilities: expected c typed `%s` but got `%s`", want, got)
+	}
+	// b can be sent on a.
+	elem := resa.Name.(TypedName).Type().(*Chan).Elem()
+	if b := resb.Name.(TypedName).Type(); !IsSubtype(b, elem) {
+		t.Errorf("InferCapabilities: expected b typed `%s` to be a subtype of `%s`", b, elem)
+	}
+}
+
+// Element types are narrowed to the capabilities of the names they carry.
+func TestInferCapabilitiesShared(t *testing.T) {
+	tests := []struct {
+		Proc  string
+		Types map[string]string // Types of the Restrict names.
+	}{
+		{"(new b)b<b>", map[string]string{"b": "μt0.chan<- t0"}},
+		{"(new a,x)(a(x).x<a> | x<b>)", map[string]string{
+			"a": "μt0.<-chan chan<- t0",
+			"x": "chan<- interface{}",
+		}},
+		{"(new r)(new b)(a(x).x<a> | x<b> | r<a> | r(y).y(z).0)", map[string]string{
+			"r": "chan μt0.<-chan chan<- t0",
+			"b": "interface{}",
+		}},
+	}
+	for _, test := range tests {
+		proc, err := asyncpi.Parse(strings.NewReader(test.Proc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Infer(proc); err != nil {
+			t.Fatal(err)
+		}
+		if err := Unify(proc); err != nil {
+			t.Fatal(err)
+		}
+		if err := InferCapabilities(proc); err != nil {
+			t.Fatal(err)
+		}
+		asyncpi.Inspect(proc, func(p asyncpi.Process) bool {
+			if res, ok := p.(*asyncpi.Restrict); ok {
+				if want, got := test.Types[res.Name.Ident()], res.Name.(TypedName).Type().String(); want != got {
+					t.Errorf("InferCapabilities: expected %s typed `%s` in %s but got `%s`",
+						res.Name.Ident(), want, test.Proc, got)
+				}
+			}
+			return true
+		})
+	}
+}
+
+func TestIsSubtype(t *testing.T) {
+	T, U := NewBase("T"), NewBase("U")
+	tests := []struct {
+		T, U    Type
+		Subtype bool
+	}{
+		{NewChan(T), NewChanDir(RecvOnly, T), true},
+		{NewChan(T), NewChanDir(SendOnly, T), true},
+		{NewChanDir(RecvOnly, T), NewChan(T), false},
+		{NewChanDir(SendOnly, T), NewChanDir(RecvOnly, T), false},
+		{NewChan(T), NewChan(U), false},
+		// Covariant input.
+		{NewChanDir(RecvOnly, NewChan(T)), NewChanDir(RecvOnly, NewChanDir(RecvOnly, T)), true},
+		{NewChanDir(RecvOnly, NewChanDir(RecvOnly, T)), NewChanDir(RecvOnly, NewChan(T)), false},
+		// Contravariant output.
+		{NewChanDir(SendOnly, NewChanDir(RecvOnly, T)), NewChanDir(SendOnly, NewChan(T)), true},
+		{NewChanDir(SendOnly, NewChan(T)), NewChanDir(SendOnly, NewChanDir(RecvOnly, T)), false},
+		// Invariant bidirectional.
+		{NewChan(NewChan(T)), NewChan(NewChanDir(RecvOnly, T)), false},
+		{NewComposite(NewChan(T), U), NewComposite(NewChanDir(SendOnly, T), U), true},
+		{T, newAnyType(), true},
+	}
+	for _, test := range tests {
+		if want, got := test.Subtype, IsSubtype(test.T, test.U); want != got {
+			t.Errorf("IsSubtype: expected %s <: %s to be %t but got %t", test.T, test.U, want, got)
+		}
+	}
+}
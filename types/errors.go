@@ -15,7 +15,10 @@
 package types
 
 import (
+	"bytes"
 	"fmt"
+
+	"go.nickng.io/asyncpi"
 )
 
 // InferUnTypedError is the type of error when type inference is
@@ -33,6 +36,10 @@ func (e InferUntypedError) Error() string {
 type TypeError struct {
 	T, U Type
 	Msg  string
+
+	// Pos and PrevPos are the positions where the types U and T are
+	// inferred, if known.
+	Pos, PrevPos asyncpi.Pos
 }
 
 func (e TypeError) Error() string {
@@ -46,9 +53,28 @@ type TypeArityError struct {
 	Got      int
 	Expected int
 	Msg      string
+
+	// Pos and PrevPos are the positions where the arities Got and
+	// Expected are inferred, if known.
+	Pos, PrevPos asyncpi.Pos
 }
 
 func (e TypeArityError) Error() string {
 	return fmt.Sprintf("type error: arity mismatch (got=%d, expected=%d) (%s)",
 		e.Got, e.Expected, e.Msg)
 }
+
+// ConflictError is the type of error when unification finds more than one
+// conflicting constraint. Each of Errs is a *TypeError or a *TypeArityError.
+type ConflictError struct {
+	Errs []error
+}
+
+func (e *ConflictError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d type conflict(s)", len(e.Errs))
+	for _, err := range e.Errs {
+		fmt.Fprintf(&buf, "\n\t%s", err)
+	}
+	return buf.String()
+}
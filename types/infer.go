@@ -19,245 +19,358 @@ import (
 
 	"go.nickng.io/asyncpi"
 	"go.nickng.io/asyncpi/internal/errors"
+	"go.nickng.io/asyncpi/internal/name"
+	"go.nickng.io/asyncpi/resolve"
 )
 
+// Inference.
+// This file contains type inference by constraints: each use of a channel
+// u in u<v> or u(x).P constrains the type of u to be a channel of the
+// types of the payload names. The constraints are generated in one
+// traversal of a Process, which also attaches types to its names, and
+// are solved by unification in a union-find structure.
+
 func errInferType(err error) error {
 	return errors.Wrap(err, "cannot infer type")
 }
 
-// BUG(nickng) Inference may fail if type of a name is recursively defined
-// (e.g. a<a> → typed chan of type(a)), printing the type will cause a stack
-// overflow.
-
-func processAttachType(p asyncpi.Process) error {
-	switch p := p.(type) {
-	case *asyncpi.NilProcess:
-	case *asyncpi.Par:
-		for _, p := range p.Procs {
-			if err := processAttachType(p); err != nil {
-				return err
-			}
-		}
-	case *asyncpi.Recv:
-		p.Chan = AttachType(p.Chan)
-		var tvs []asyncpi.Name
-		for _, v := range p.Vars {
-			tvs = append(tvs, AttachType(v))
-		}
-		p.SetVars(tvs)
-		if err := processAttachType(p.Cont); err != nil {
-			return err
-		}
-	case *asyncpi.Repeat:
-		if err := processAttachType(p.Proc); err != nil {
-			return err
-		}
-	case *asyncpi.Restrict:
-		if err := processAttachType(p.Proc); err != nil {
-			return err
-		}
-		p.Name = AttachType(p.Name)
-	case *asyncpi.Send:
-		p.Chan = AttachType(p.Chan)
-		var tvs []asyncpi.Name
-		for _, v := range p.Vals {
-			tvs = append(tvs, AttachType(v))
-		}
-		p.SetVals(tvs)
-	default:
-		return asyncpi.UnknownProcessError{Proc: p}
+// Infer attaches types to the names of Process p, and solves the types
+// of all names from the constraints of all uses of the channels.
+//
+// If constraints are in conflict, the types are solved from the
+// constraints without conflicts, and the conflict is returned as a
+// *TypeError or a *TypeArityError with the positions of both
+// constraints. If there is more than one conflict, all conflicts
+// are returned as a *ConflictError.
+func Infer(p asyncpi.Process) error {
+	u, err := solve(p, true)
+	if err != nil {
+		return errInferType(err)
+	}
+	if err := u.setTypes(); err != nil {
+		return errInferType(err)
 	}
 	return nil
 }
 
-func Infer(p asyncpi.Process) error {
-	if err := processAttachType(p); err != nil {
-		return errInferType(errors.Wrap(err, "cannot attach type to process"))
+// InferCopy returns a copy of the Process p with inferred types.
+// Unlike Infer, the input Process p is not modified.
+func InferCopy(p asyncpi.Process) (asyncpi.Process, error) {
+	p = asyncpi.Clone(p)
+	if err := Infer(p); err != nil {
+		return nil, err
 	}
-	if err := asyncpi.Bind(&p); err != nil {
-		return errInferType(err)
+	return p, nil
+}
+
+// chanOf returns the channel type of the payload types ts,
+// where the payload of multiple types is a Composite.
+func chanOf(ts []Type) *Chan {
+	if len(ts) == 1 {
+		return NewChan(ts[0])
 	}
-	if err := processInferType(p); err != nil {
-		return errInferType(err)
+	return NewChan(NewComposite(ts...))
+}
+
+func errUnify(err error) error {
+	return errors.Wrap(err, "cannot unify types")
+}
+
+// UnifyCopy returns a copy of the Process p with unified types.
+// Unlike Unify, the input Process p is not modified.
+func UnifyCopy(p asyncpi.Process) (asyncpi.Process, error) {
+	p = asyncpi.Clone(p)
+	if err := Unify(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// tnode is a type in the union-find structure of Unify.
+type tnode struct {
+	parent *tnode
+	isChan bool
+	args   []*tnode    // Payload types, valid if isChan.
+	base   string      // Base type name from type hints, if not a channel.
+	pos    asyncpi.Pos // Position where the type is first inferred.
+}
+
+func (n *tnode) find() *tnode {
+	for n.parent != nil {
+		if n.parent.parent != nil {
+			n.parent = n.parent.parent
+		}
+		n = n.parent
+	}
+	return n
+}
+
+// unifier solves the type constraints of a Process.
+type unifier struct {
+	info      *resolve.Info
+	nodes     map[*resolve.Object]*tnode
+	types     map[*tnode]Type // Solved types.
+	conflicts []error
+
+	servers []*scheme // Servers of polymorphic names, innermost first.
+	schemes map[*resolve.Object]*scheme
+	reps    map[*tnode]TypedName // Names referred to by the payload types.
+}
+
+// Unify solves the types of the names of a Process p typed by Infer
+// again, e.g. after p is modified. The types of the names are replaced
+// by the types solved, like Infer.
+//
+// Type hints are ignored if the name is used as a channel. The types of
+// names served by a replicated input are polymorphic (see Monomorphise).
+func Unify(p asyncpi.Process) error {
+	u, err := solve(p, false)
+	if err != nil {
+		return errUnify(err)
+	}
+	if err := u.setTypes(); err != nil {
+		return errUnify(err)
 	}
 	return nil
 }
 
-// processInferType performs inline type inference for channels.
+// setTypes sets the type of each name to its solved type, and returns
+// the conflicts found.
+func (u *unifier) setTypes() error {
+	u.specialise()
+	for _, o := range u.info.Occurrences() {
+		o.Name().(TypedName).setType(u.typeOf(u.nodes[u.info.ObjectOf(o)]))
+	}
+	switch len(u.conflicts) {
+	case 0:
+		return nil
+	case 1:
+		return u.conflicts[0]
+	}
+	return &ConflictError{Errs: u.conflicts}
+}
+
+// solve generates the constraints of the uses of channels in Process p
+// in one traversal, and solves them. If attach is set, types are
+// attached to the names of p, otherwise the names must be typed.
 //
-// processInferType should be called after Bind, so the types of names inferred from
-// channels can be propagated to other references bound to the same name.
-func processInferType(p asyncpi.Process) error {
-	switch p := p.(type) {
-	case *asyncpi.NilProcess:
-	case *asyncpi.Par:
-		for _, p := range p.Procs {
-			if err := Infer(p); err != nil {
-				return err
-			}
-		}
-	case *asyncpi.Recv:
-		if err := Infer(p.Cont); err != nil {
-			return err
-		}
-		if _, isTyped := p.Chan.(TypedName); !isTyped {
-			return InferUntypedError{Name: p.Chan.Ident()}
-		}
-		// But that's all we know right now.
-		if _, ok := p.Chan.(TypedName).Type().(*anyType); ok { // do not overwrite existing type
-			var tvs []Type
-			for i := range p.Vars {
-				tv, isTyped := p.Vars[i].(TypedName)
-				if !isTyped {
-					return InferUntypedError{Name: p.Vars[i].Ident()}
-				}
-				if refType, isRef := tv.Type().(*Reference); isRef { // already a Reference
-					tvs = append(tvs, refType)
-				} else {
-					tvs = append(tvs, NewReference(p.Vars[i]))
-				}
-			}
-			switch len(tvs) { // arity determines if it is a Composite.
-			case 1:
-				p.Chan.(TypedName).setType(NewChan(tvs[0]))
-			default:
-				p.Chan.(TypedName).setType(NewChan(NewComposite(tvs...)))
-			}
-		}
-	case *asyncpi.Repeat:
-		if err := Infer(p.Proc); err != nil {
-			return err
-		}
-	case *asyncpi.Restrict:
-		if err := Infer(p.Proc); err != nil {
-			return err
-		}
-	case *asyncpi.Send: // Send is the only place we can infer channel type.
-		if _, isTyped := p.Chan.(TypedName); !isTyped {
-			return InferUntypedError{Name: p.Chan.Ident()}
+// The servers of polymorphic names are solved and generalised first,
+// then their other uses are solved with an instance of their type.
+func solve(p asyncpi.Process, attach bool) (*unifier, error) {
+	info, err := resolve.Resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	u := &unifier{
+		info:    info,
+		nodes:   make(map[*resolve.Object]*tnode),
+		types:   make(map[*tnode]Type),
+		schemes: make(map[*resolve.Object]*scheme),
+		reps:    make(map[*tnode]TypedName),
+	}
+	g := newServerGraph()
+	asyncpi.Apply(p, func(c *asyncpi.Cursor) bool {
+		if err != nil {
+			return false
 		}
-		var tvs []Type
-		for i := range p.Vals {
-			if _, isTyped := p.Vals[i].(TypedName); !isTyped {
-				return InferUntypedError{Name: p.Vals[i].Ident()}
-			}
-			if refType, isRef := p.Vals[i].(TypedName).Type().(*Reference); isRef { // already a Reference
-				tvs = append(tvs, refType)
-			} else {
-				tvs = append(tvs, NewReference(p.Vals[i]))
+		proc := c.Node()
+		g.enter(proc)
+		for i := 0; i < numNames(proc); i++ {
+			o := resolve.Occurrence{Proc: proc, Index: i}
+			var tn TypedName
+			if attach {
+				tn = AttachType(o.Name())
+				setName(o, tn)
+			} else if tn, _ = o.Name().(TypedName); tn == nil {
+				err = InferUntypedError{Name: o.Name().Ident()}
+				return false
 			}
+			g.occur(o, u.info.ObjectOf(o))
+			u.nodeOf(u.info.ObjectOf(o), tn)
 		}
-		switch len(p.Vals) {
-		case 1:
-			p.Chan.(TypedName).setType(NewChan(tvs[0]))
-		default:
-			p.Chan.(TypedName).setType(NewChan(NewComposite(tvs...)))
+		return true
+	}, func(c *asyncpi.Cursor) bool {
+		g.exit(c.Node())
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	owned, unowned := u.findServers(g)
+	for _, s := range u.servers {
+		for _, o := range owned[s] {
+			u.constrain(o)
 		}
-	default:
-		return asyncpi.UnknownProcessError{Proc: p}
+		u.generalise(s)
 	}
-	return nil
+	for _, o := range unowned {
+		u.constrain(o)
+	}
+	return u, nil
 }
 
-func errUnify(err error) error {
-	return errors.Wrap(err, "cannot unify types")
+// numNames returns the number of names of the Process p,
+// i.e. the number of Occurrences in p.
+func numNames(p asyncpi.Process) int {
+	switch p := p.(type) {
+	case *asyncpi.Send:
+		return 1 + len(p.Vals)
+	case *asyncpi.Recv:
+		return 1 + len(p.Vars)
+	case *asyncpi.Restrict:
+		return 1
+	}
+	return 0
 }
 
-// Unify combines the constraints of sending and receiving channels
-// with best effort.
-//
-// It is assumed that the names are already typed, and an error is returned
-// if the typing constraints are in conflict and cannot be unified.
-// A Process is well-typed if no error is returned.
-func Unify(p asyncpi.Process) error {
-	switch p := p.(type) {
-	case *asyncpi.NilProcess, *asyncpi.Send: // No continuation.
-	case *asyncpi.Par:
-		for _, p := range p.Procs {
-			if err := Unify(p); err != nil {
-				return err
-			}
+// setName replaces the Name at the Occurrence o with n.
+func setName(o resolve.Occurrence, n asyncpi.Name) {
+	switch p := o.Proc.(type) {
+	case *asyncpi.Send:
+		if o.Index == 0 {
+			p.Chan = n
+			return
 		}
+		p.Vals[o.Index-1] = n
 	case *asyncpi.Recv:
-		ch, isTyped := p.Chan.(TypedName)
-		if !isTyped {
-			return errUnify(InferUntypedError{Name: p.Chan.Ident()})
-		}
-		// chType is either
-		// - a compType with refType fields (including struct{})
-		// - a refType (non-tuple)
-		varType := ch.Type().(*Chan).Elem()
-		var tns []TypedName
-		for _, v := range p.Vars {
-			tv, isTyped := v.(TypedName)
-			if !isTyped {
-				return errUnify(InferUntypedError{Name: v.Ident()})
-			}
-			tns = append(tns, tv)
+		if o.Index == 0 {
+			p.Chan = n
+			return
 		}
-		switch len(p.Vars) {
-		case 1:
-			refT, isRef := varType.(*Reference)
-			if !isRef {
-				return &TypeArityError{
-					Got:      len(varType.(*Composite).Elems()),
-					Expected: 1,
-					Msg:      fmt.Sprintf("Types from channel %s and vars have different arity", p.Chan.Ident()),
-				}
-			}
-			if _, ok := tns[0].Type().(*anyType); ok {
-				tns[0].setType(varType)
-			} else {
-				if _, ok := refT.ref.Type().(*anyType); ok {
-					refT.ref.setType(tns[0].Type())
-				} else if IsEqual(varType, tns[0].Type()) {
-					// Type is both set but equal
-				} else {
-					return errUnify(&TypeError{
-						T:   varType,
-						U:   tns[0].Type(),
-						Msg: fmt.Sprintf("Types inferred from channel %s are in conflict", p.Chan.Ident()),
-					})
-				}
-			}
-		default:
-			compT, isComp := varType.(*Composite)
-			if !isComp {
-				return errUnify(&TypeArityError{
-					Got:      1,
-					Expected: len(p.Vars),
-					Msg:      fmt.Sprintf("Types from channel %s and vars have different arity", p.Chan.Ident()),
-				})
-			} else if len(tns) != len(compT.Elems()) {
-				return errUnify(&TypeArityError{
-					Got:      len(compT.Elems()),
-					Expected: len(p.Vars),
-					Msg:      fmt.Sprintf("Types from channel %s and vars have different arity", p.Chan.Ident()),
+		p.Vars[o.Index-1] = n
+	case *asyncpi.Restrict:
+		p.Name = n
+	}
+}
+
+// constrain unifies the type of the channel at Occurrence o with
+// the channel type of its payload.
+func (u *unifier) constrain(o resolve.Occurrence) {
+	ch := &tnode{isChan: true, pos: asyncpi.NamePos(o.Name())}
+	for _, obj := range payloadObjects(u.info, o.Proc) {
+		ch.args = append(ch.args, u.nodes[obj])
+	}
+	obj := u.info.ObjectOf(o)
+	if s := u.schemes[obj]; s != nil && s.generic != nil {
+		inst := u.instantiate(s)
+		s.instances = append(s.instances, instance{occ: o, node: inst})
+		u.unify(o.Name().Ident(), inst, ch)
+		return
+	}
+	u.unify(o.Name().Ident(), u.nodes[obj], ch)
+}
+
+// nodeOf returns the type of the name obj, where n is a name of obj.
+func (u *unifier) nodeOf(obj *resolve.Object, n TypedName) *tnode {
+	if node, ok := u.nodes[obj]; ok {
+		return node
+	}
+	node := &tnode{}
+	if hint, ok := typeHint(n); ok {
+		node.base = hint
+		node.pos = asyncpi.NamePos(n)
+	}
+	u.nodes[obj] = node
+	return node
+}
+
+// typeHint returns the type hint of the Name n.
+func typeHint(n asyncpi.Name) (string, bool) {
+	if tn, ok := n.(*typedName); ok {
+		n = tn.Name
+	}
+	if th, ok := n.(name.TypeHinter); ok {
+		return th.TypeHint(), true
+	}
+	return "", false
+}
+
+// unify unifies the types x and y from a use of the channel named ident,
+// recording conflicts.
+func (u *unifier) unify(ident string, x, y *tnode) {
+	type pair struct{ x, y *tnode }
+	work := []pair{{x, y}}
+	for len(work) > 0 {
+		x, y := work[0].x.find(), work[0].y.find()
+		work = work[1:]
+		switch {
+		case x == y:
+		case !y.isChan && y.base == "":
+			y.link(x)
+		case !x.isChan && x.base == "":
+			x.link(y)
+		case x.isChan && !y.isChan: // Type hint of y is ignored.
+			y.link(x)
+		case !x.isChan && y.isChan: // Type hint of x is ignored.
+			x.link(y)
+		case !x.isChan: // Both are type hints.
+			if x.base != y.base {
+				u.conflicts = append(u.conflicts, &TypeError{
+					T:       NewBase(x.base),
+					U:       NewBase(y.base),
+					Pos:     y.pos,
+					PrevPos: x.pos,
+					Msg: fmt.Sprintf("Types inferred from channel %s at %s and %s are in conflict",
+						ident, y.pos, x.pos),
 				})
+				continue
 			}
-			for i := range tns {
-				if _, ok := tns[i].Type().(*anyType); ok {
-					tns[i].setType(compT.elems[i].(*Reference).ref.Type())
-				} else if _, ok := compT.elems[i].(*Reference).ref.Type().(*anyType); ok {
-					compT.elems[i].(*Reference).ref.setType(tns[i].Type())
-				} else if IsEqual(compT.elems[i], tns[i].Type()) {
-					// Type is both set but equal
-				} else {
-					return errUnify(&TypeError{
-						T:   varType,
-						U:   tns[i].Type(),
-						Msg: fmt.Sprintf("Types inferred from channel %s are in conflict", p.Chan.Ident()),
-					})
-				}
+			y.link(x)
+		case len(x.args) != len(y.args):
+			u.conflicts = append(u.conflicts, &TypeArityError{
+				Got:      len(y.args),
+				Expected: len(x.args),
+				Pos:      y.pos,
+				PrevPos:  x.pos,
+				Msg: fmt.Sprintf("Types from channel %s at %s and %s have different arity",
+					ident, y.pos, x.pos),
+			})
+		default:
+			y.link(x)
+			for i := range x.args {
+				work = append(work, pair{x.args[i], y.args[i]})
 			}
 		}
-		return Unify(p.Cont)
-	case *asyncpi.Repeat:
-		return Unify(p.Proc)
-	case *asyncpi.Restrict:
-		return Unify(p.Proc)
+	}
+}
+
+// link merges the type n into the type root.
+func (n *tnode) link(root *tnode) {
+	n.parent = root
+}
+
+// typeOf returns the solved type of node.
+// All names of the same type share the same Type.
+func (u *unifier) typeOf(node *tnode) Type {
+	node = node.find()
+	if t, ok := u.types[node]; ok {
+		return t
+	}
+	switch {
+	case node.isChan:
+		ch := NewChan(nil)
+		u.types[node] = ch // Set before the payload types for recursive types.
+		var ts []Type
+		for _, arg := range node.args {
+			ts = append(ts, &Reference{ref: u.repOf(arg.find())})
+		}
+		ch.elem = chanOf(ts).elem
+	case node.base != "":
+		u.types[node] = NewBase(node.base)
 	default:
-		return errUnify(asyncpi.UnknownProcessError{Proc: p})
+		u.types[node] = newAnyType()
 	}
-	return nil
+	return u.types[node]
+}
+
+// repOf returns a name of the type node for the payload types to refer
+// to. The name is not a name of the Process, so the payload types are
+// not changed when the type of a name is replaced, e.g. by
+// InferCapabilities.
+func (u *unifier) repOf(node *tnode) TypedName {
+	if _, ok := u.reps[node]; !ok {
+		u.reps[node] = newTypedName(name.New("_"))
+		u.reps[node].setType(u.typeOf(node))
+	}
+	return u.reps[node]
 }
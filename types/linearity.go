@@ -0,0 +1,306 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"fmt"
+
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/internal/errors"
+	"go.nickng.io/asyncpi/resolve"
+)
+
+// Linearity.
+// This file contains the analysis of how many times channels are used.
+
+func errInferLin(err error) error {
+	return errors.Wrap(err, "cannot infer linearity")
+}
+
+// Multiplicity is the number of times a channel is used
+// for input or output: 0, 1 or many (ω).
+type Multiplicity int
+
+const (
+	Zero Multiplicity = iota // Not used.
+	One                      // Used once.
+	Many                     // Used more than once.
+)
+
+func (m Multiplicity) add(n Multiplicity) Multiplicity {
+	if m+n > Many {
+		return Many
+	}
+	return m + n
+}
+
+func (m Multiplicity) String() string {
+	switch m {
+	case Zero:
+		return "0"
+	case One:
+		return "1"
+	}
+	return "ω"
+}
+
+// Usage is the number of times a channel is used for input and output.
+type Usage struct {
+	In, Out Multiplicity
+}
+
+// add returns the usage of using a channel as u and as v.
+func (u Usage) add(v Usage) Usage {
+	return Usage{In: u.In.add(v.In), Out: u.Out.add(v.Out)}
+}
+
+// join returns the usage of using a channel as either u or v.
+func (u Usage) join(v Usage) Usage {
+	if v.In > u.In {
+		u.In = v.In
+	}
+	if v.Out > u.Out {
+		u.Out = v.Out
+	}
+	return u
+}
+
+// replicate returns the usage of using a channel as u repeatedly.
+func (u Usage) replicate() Usage {
+	return u.add(u)
+}
+
+// Linearity returns the Linearity of a channel with usage u.
+func (u Usage) Linearity() Linearity {
+	switch {
+	case u.In == One && u.Out == One:
+		return Linear
+	case u.In <= One && u.Out <= One:
+		return Affine
+	}
+	return Unrestricted
+}
+
+func (u Usage) String() string {
+	return fmt.Sprintf("in=%s,out=%s", u.In, u.Out)
+}
+
+// Linearity classifies channels by their usage.
+type Linearity int
+
+const (
+	// Unrestricted channels are used any number of times.
+	Unrestricted Linearity = iota
+	// Affine channels are used at most once for input and
+	// at most once for output.
+	Affine
+	// Linear channels are used exactly once for input and
+	// exactly once for output.
+	Linear
+)
+
+func (l Linearity) String() string {
+	switch l {
+	case Affine:
+		return "affine"
+	case Linear:
+		return "linear"
+	}
+	return "unrestricted"
+}
+
+// LinearityInfo is the result of linearity analysis.
+type LinearityInfo struct {
+	// Violations are the restricted names annotated linear which
+	// are not used linearly, in order of declaration.
+	Violations []LinearityViolation
+
+	usage map[*asyncpi.Restrict]Usage
+}
+
+// Usage returns the usage of the name restricted by r.
+func (l *LinearityInfo) Usage(r *asyncpi.Restrict) Usage {
+	return l.usage[r]
+}
+
+// Linearity returns the Linearity of the name restricted by r.
+func (l *LinearityInfo) Linearity(r *asyncpi.Restrict) Linearity {
+	if u, ok := l.usage[r]; ok {
+		return u.Linearity()
+	}
+	return Unrestricted
+}
+
+// LinearityViolation is a name annotated linear which is not used linearly.
+type LinearityViolation struct {
+	Name  string
+	Pos   asyncpi.Pos
+	Usage Usage
+}
+
+func (v LinearityViolation) String() string {
+	return fmt.Sprintf("%s: %s is annotated linear but is used %s", v.Pos, v.Name, v.Usage)
+}
+
+// LinearityError is the type of error when names
+// annotated linear are not used linearly.
+type LinearityError struct {
+	Violations []LinearityViolation
+}
+
+func (e *LinearityError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d linearity violation(s)", len(e.Violations))
+	for _, v := range e.Violations {
+		fmt.Fprintf(&buf, "\n\t%s", v)
+	}
+	return buf.String()
+}
+
+// delegation is a name sent as a value, so the name is also
+// used as the receivers of the channel use their variables.
+type delegation struct {
+	val        *resolve.Object
+	ch         *resolve.Object // Class of the channel.
+	index      int
+	replicated bool
+}
+
+// InferLinearity classifies the restricted names in Process p as linear,
+// affine or unrestricted.
+//
+// The usage of a name counts its uses as a channel, where uses under a
+// replication inside the scope of the name are counted as many. A name
+// sent as a value is also used as the receivers of the channel use their
+// variables, e.g. in
+//
+//     (new r)(a<r> | r().0) | !a(x).x<>
+//
+// r is linear. A *LinearityError is returned with the result if names
+// annotated linear are not used linearly.
+//
+// InferLinearity does not need the Process p to be typed.
+func InferLinearity(p asyncpi.Process) (*LinearityInfo, error) {
+	info, err := resolve.Resolve(p)
+	if err != nil {
+		return nil, errInferLin(err)
+	}
+	classes := newNameClasses(info)
+
+	// depth is the number of enclosing replications of each Process.
+	depth := make(map[asyncpi.Process]int)
+	repeats := 0
+	asyncpi.Apply(p, func(c *asyncpi.Cursor) bool {
+		depth[c.Node()] = repeats
+		if _, ok := c.Node().(*asyncpi.Repeat); ok {
+			repeats++
+		}
+		return true
+	}, func(c *asyncpi.Cursor) bool {
+		if _, ok := c.Node().(*asyncpi.Repeat); ok {
+			repeats--
+		}
+		return true
+	})
+	replicated := func(o resolve.Occurrence, obj *resolve.Object) bool {
+		if obj.IsFree() {
+			return depth[o.Proc] > 0
+		}
+		return depth[o.Proc] > depth[obj.Decl.Proc]
+	}
+
+	direct := make(map[*resolve.Object]Usage)
+	recvVars := make(map[*resolve.Object][][]*resolve.Object) // by channel class.
+	var delegations []delegation
+	for _, o := range info.Occurrences() {
+		obj := info.Uses[o]
+		if obj == nil {
+			continue
+		}
+		var u Usage
+		switch o.Proc.(type) {
+		case *asyncpi.Send:
+			if o.Index > 0 {
+				ch := info.ObjectOf(resolve.Occurrence{Proc: o.Proc, Index: 0})
+				delegations = append(delegations, delegation{
+					val:        obj,
+					ch:         ch,
+					index:      o.Index - 1,
+					replicated: replicated(o, obj),
+				})
+				continue
+			}
+			u.Out = One
+		case *asyncpi.Recv:
+			u.In = One
+			ch := classes.find(obj)
+			recvVars[ch] = append(recvVars[ch], payloadObjects(info, o.Proc))
+		}
+		if replicated(o, obj) {
+			u = u.replicate()
+		}
+		direct[obj] = direct[obj].add(u)
+	}
+
+	// Propagate the usage of variables to the names sent, until stable.
+	usage := direct
+	for changed := true; changed; {
+		next := make(map[*resolve.Object]Usage, len(direct))
+		for obj, u := range direct {
+			next[obj] = u
+		}
+		for _, d := range delegations {
+			var u Usage
+			for _, vars := range recvVars[classes.find(d.ch)] {
+				if d.index < len(vars) {
+					u = u.join(usage[vars[d.index]])
+				}
+			}
+			if d.replicated {
+				u = u.replicate()
+			}
+			next[d.val] = next[d.val].add(u)
+		}
+		changed = false
+		for obj, u := range next {
+			if usage[obj] != u {
+				changed = true
+			}
+		}
+		usage = next
+	}
+
+	l := &LinearityInfo{usage: make(map[*asyncpi.Restrict]Usage)}
+	for _, o := range info.Occurrences() {
+		r, isRestrict := o.Proc.(*asyncpi.Restrict)
+		if !isRestrict {
+			continue
+		}
+		u := usage[info.Defs[o]]
+		l.usage[r] = u
+		if asyncpi.IsAnnotatedLinear(r.Name) && u.Linearity() != Linear {
+			l.Violations = append(l.Violations, LinearityViolation{
+				Name:  r.Name.Ident(),
+				Pos:   asyncpi.NamePos(r.Name),
+				Usage: u,
+			})
+		}
+	}
+	if len(l.Violations) > 0 {
+		return l, errInferLin(&LinearityError{Violations: l.Violations})
+	}
+	return l, nil
+}
@@ -0,0 +1,65 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/internal/errors"
+)
+
+func TestInferLinearity(t *testing.T) {
+	tests := []struct {
+		Input     string
+		Linearity Linearity
+	}{
+		{"(new a)(a<> | a().0)", Linear},
+		{"(new a)a<>", Affine},
+		{"(new a)0", Affine},
+		{"(new a)(a<> | a<> | a().0)", Unrestricted},
+		{"(new a)(a().0 | !a<>)", Unrestricted},
+		{"(new a)!(new b)(b<> | b().0 | a<>)", Unrestricted},
+		{"(new r)(s<r> | r().0) | !s(x).x<>", Linear},
+		{"(new r)(s<r> | r().0) | s(x).(x<> | x<>)", Unrestricted},
+		{"(new r)(s<r> | r().0) | s(x).t<x> | t(y).y<>", Linear},
+	}
+	for _, test := range tests {
+		proc, err := asyncpi.Parse(strings.NewReader(test.Input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The first restriction in the process.
+		var r *asyncpi.Restrict
+		asyncpi.Inspect(proc, func(p asyncpi.Process) bool {
+			if res, ok := p.(*asyncpi.Restrict); ok && r == nil {
+				r = res
+			}
+			return r == nil
+		})
+		lin, err := InferLinearity(proc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, got := test.Linearity, lin.Linearity(r); want != got {
+			t.Errorf("InferLinearity: expected %s in %s to be %s but got %s (%s)",
+				r.Name.Ident(), test.Input, want, got, lin.Usage(r))
+		}
+	}
+}
+
+func TestInferLinearityViolation(t *testing.T) {
+	proc, err := asyncpi.Parse(strings.NewReader("(new lin a, lin b)(a<> | a<> | a().0 | b<> | b().0)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lin, err := InferLinearity(proc)
+	if err == nil {
+		t.Fatalf("InferLinearity: expected linearity violation but got none")
+	}
+	if _, ok := err.(errors.Causer).Cause().(*LinearityError); !ok {
+		t.Fatalf("InferLinearity: expected linearity error but got %v", err)
+	}
+	if want, got := "1:10: a is annotated linear but is used in=1,out=ω", lin.Violations[0].String(); len(lin.Violations) != 1 || want != got {
+		t.Fatalf("InferLinearity: expected violation `%s` but got %v", want, lin.Violations)
+	}
+}
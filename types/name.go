@@ -52,6 +52,16 @@ func (n *typedName) Type() Type {
 	return n.t
 }
 
+// Pos returns the source position of the wrapped Name.
+func (n *typedName) Pos() asyncpi.Pos {
+	return asyncpi.NamePos(n.Name)
+}
+
+// IsLinear returns true if the wrapped Name is annotated linear.
+func (n *typedName) IsLinear() bool {
+	return asyncpi.IsAnnotatedLinear(n.Name)
+}
+
 var _ TypedName = (*typedName)(nil)
 
 // setType replaces the type of n with t.
@@ -59,6 +69,16 @@ func (n *typedName) setType(t Type) {
 	n.t = t
 }
 
+// CloneName returns a copy of the typedName n using the Cloner c.
+// Names referenced by the type of n are also copied with c.
+func (n *typedName) CloneName(c *asyncpi.Cloner) asyncpi.Name {
+	clone := &typedName{}
+	c.Set(n, clone)
+	clone.Name = c.Name(n.Name)
+	clone.t = cloneType(n.t, c)
+	return clone
+}
+
 // AttachType wraps the given n with types.
 // The default type is unconstrained.
 func AttachType(n asyncpi.Name) TypedName {
@@ -0,0 +1,383 @@
+// Copyright 2018 Nicholas Ng <nickng@nickng.io>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/internal/errors"
+	"go.nickng.io/asyncpi/internal/name"
+	"go.nickng.io/asyncpi/resolve"
+)
+
+// Polymorphism.
+// This file contains let-polymorphism in the style of Hindley-Milner for
+// names served by a replicated input, e.g. fwd in
+//
+//     (new fwd)(!fwd(x,y).y<x> | fwd<a,b> | fwd<c,d>)
+//
+// The type of fwd is generalised after its server !fwd(x,y).y<x> is
+// typed, i.e. ∀α.chan struct{e0 α;e1 chan α}, and each use of fwd outside
+// of the server is typed by a fresh instance of the type, so a, b and c, d
+// can have different types. Uses of fwd in its server are monomorphic.
+
+// scheme is the polymorphic type of a name served by a replicated input.
+type scheme struct {
+	obj    *resolve.Object
+	decl   *asyncpi.Restrict
+	server *asyncpi.Repeat
+
+	procs  []asyncpi.Process        // Processes in the server.
+	inside map[asyncpi.Process]bool // Set of procs.
+
+	generic   map[*tnode]bool // Generalised types, nil until generalised.
+	instances []instance
+}
+
+// instance is the type of a use of a polymorphic name.
+type instance struct {
+	occ  resolve.Occurrence
+	node *tnode
+}
+
+// serverGraph records the replicated inputs enclosing the inputs,
+// outputs and restrictions of a Process, and the uses of its names,
+// while its constraints are generated (see solve).
+type serverGraph struct {
+	replicated []*asyncpi.Repeat                     // Enclosing replicated inputs.
+	within     map[asyncpi.Process][]*asyncpi.Repeat // Replicated inputs enclosing each Process.
+	procs      []asyncpi.Process                     // Inputs, outputs and restrictions.
+	exited     []*asyncpi.Repeat                     // Replicated inputs, innermost first.
+	servedBy   map[*asyncpi.Recv]*asyncpi.Repeat     // Replicated input of each input.
+
+	uses   []resolve.Occurrence // Uses of channels.
+	decls  []resolve.Occurrence // Restricted names.
+	inputs map[*resolve.Object][]resolve.Occurrence
+	sent   map[*resolve.Object]bool
+}
+
+func newServerGraph() *serverGraph {
+	return &serverGraph{
+		within:   make(map[asyncpi.Process][]*asyncpi.Repeat),
+		servedBy: make(map[*asyncpi.Recv]*asyncpi.Repeat),
+		inputs:   make(map[*resolve.Object][]resolve.Occurrence),
+		sent:     make(map[*resolve.Object]bool),
+	}
+}
+
+// enter records the Process p before its children are traversed.
+func (g *serverGraph) enter(p asyncpi.Process) {
+	switch p := p.(type) {
+	case *asyncpi.Repeat:
+		if recv, ok := p.Proc.(*asyncpi.Recv); ok {
+			g.replicated = append(g.replicated, p)
+			g.servedBy[recv] = p
+		}
+	case *asyncpi.Recv, *asyncpi.Restrict, *asyncpi.Send:
+		g.procs = append(g.procs, p)
+		g.within[p] = append([]*asyncpi.Repeat(nil), g.replicated...)
+	}
+}
+
+// exit records the Process p after its children are traversed.
+func (g *serverGraph) exit(p asyncpi.Process) {
+	if rep, ok := p.(*asyncpi.Repeat); ok {
+		if _, ok := rep.Proc.(*asyncpi.Recv); ok {
+			g.replicated = g.replicated[:len(g.replicated)-1]
+			g.exited = append(g.exited, rep)
+		}
+	}
+}
+
+// occur records the Occurrence o of the name obj.
+func (g *serverGraph) occur(o resolve.Occurrence, obj *resolve.Object) {
+	switch o.Proc.(type) {
+	case *asyncpi.Send:
+		if o.Index > 0 {
+			g.sent[obj] = true
+			return
+		}
+		g.uses = append(g.uses, o)
+	case *asyncpi.Recv:
+		if o.Index == 0 {
+			g.inputs[obj] = append(g.inputs[obj], o)
+			g.uses = append(g.uses, o)
+		}
+	case *asyncpi.Restrict:
+		g.decls = append(g.decls, o)
+	}
+}
+
+// findServers finds the names which can be polymorphic, i.e. restricted
+// names whose only input is a replicated input, and which are never sent
+// as a value. It returns the uses of channels owned by each server, i.e.
+// the uses whose innermost server is the server, and the other uses.
+func (u *unifier) findServers(g *serverGraph) (map[*scheme][]resolve.Occurrence, []resolve.Occurrence) {
+	servers := make(map[*asyncpi.Repeat]*scheme)
+	for _, o := range g.decls {
+		obj := u.info.Defs[o]
+		if g.sent[obj] || len(g.inputs[obj]) != 1 {
+			continue
+		}
+		server := g.servedBy[g.inputs[obj][0].Proc.(*asyncpi.Recv)]
+		if server == nil {
+			continue
+		}
+		s := &scheme{
+			obj:    obj,
+			decl:   o.Proc.(*asyncpi.Restrict),
+			server: server,
+			inside: make(map[asyncpi.Process]bool),
+		}
+		u.schemes[obj] = s
+		servers[server] = s
+	}
+	for _, rep := range g.exited {
+		if s := servers[rep]; s != nil {
+			u.servers = append(u.servers, s)
+		}
+	}
+	owner := make(map[asyncpi.Process]*scheme)
+	for _, p := range g.procs {
+		for _, rep := range g.within[p] {
+			if s := servers[rep]; s != nil {
+				s.procs = append(s.procs, p)
+				s.inside[p] = true
+				owner[p] = s
+			}
+		}
+	}
+	owned := make(map[*scheme][]resolve.Occurrence)
+	var unowned []resolve.Occurrence
+	for _, o := range g.uses {
+		if s := owner[o.Proc]; s != nil {
+			owned[s] = append(owned[s], o)
+			continue
+		}
+		unowned = append(unowned, o)
+	}
+	return owned, unowned
+}
+
+// generalise generalises the type of the polymorphic name of server s,
+// i.e. the types which are not also the types of the names used in the
+// server but declared outside of it.
+func (u *unifier) generalise(s *scheme) {
+	mono := make(map[*tnode]bool)
+	for _, p := range s.procs {
+		for i := 0; i <= len(payloadObjects(u.info, p)); i++ {
+			obj := u.info.ObjectOf(resolve.Occurrence{Proc: p, Index: i})
+			if obj == s.obj || (!obj.IsFree() && s.inside[obj.Decl.Proc]) {
+				continue
+			}
+			reachable(u.nodes[obj], mono)
+		}
+	}
+	s.generic = make(map[*tnode]bool)
+	for n := range reachable(u.nodes[s.obj], make(map[*tnode]bool)) {
+		if !mono[n] {
+			s.generic[n] = true
+		}
+	}
+}
+
+// reachable adds the types reachable from the type n to seen.
+func reachable(n *tnode, seen map[*tnode]bool) map[*tnode]bool {
+	work := []*tnode{n}
+	for len(work) > 0 {
+		n := work[0].find()
+		work = work[1:]
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		work = append(work, n.args...)
+	}
+	return seen
+}
+
+// instantiate returns a fresh instance of the polymorphic type of s.
+func (u *unifier) instantiate(s *scheme) *tnode {
+	copies := make(map[*tnode]*tnode)
+	var inst func(n *tnode) *tnode
+	inst = func(n *tnode) *tnode {
+		n = n.find()
+		if !s.generic[n] {
+			return n
+		}
+		if c, ok := copies[n]; ok {
+			return c
+		}
+		c := &tnode{isChan: n.isChan, base: n.base, pos: n.pos}
+		copies[n] = c
+		for _, arg := range n.args {
+			c.args = append(c.args, inst(arg))
+		}
+		return c
+	}
+	return inst(u.nodes[s.obj])
+}
+
+// instanceGroups returns the instances of s grouped by their types.
+func (u *unifier) instanceGroups(s *scheme) [][]instance {
+	var groups [][]instance
+	for _, inst := range s.instances {
+		t := u.typeOf(inst.node)
+		found := false
+		for i := range groups {
+			if IsEqual(u.typeOf(groups[i][0].node), t) {
+				groups[i] = append(groups[i], inst)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, []instance{inst})
+		}
+	}
+	return groups
+}
+
+// specialise types each polymorphic name which is only used at one type
+// at that type, so its server is typed by its uses.
+func (u *unifier) specialise() {
+	for _, s := range u.servers {
+		if len(s.instances) == 0 || len(u.instanceGroups(s)) != 1 {
+			continue
+		}
+		u.types = make(map[*tnode]Type)
+		u.reps = make(map[*tnode]TypedName)
+		u.unify(s.obj.Ident, u.nodes[s.obj], s.instances[0].node)
+	}
+	u.types = make(map[*tnode]Type)
+	u.reps = make(map[*tnode]TypedName)
+}
+
+// Monomorphise returns a copy of Process p where the server of each
+// polymorphic name used at more than one type is copied for each type,
+// e.g.
+//
+//     (new fwd)(!fwd(x,y).y<x> | fwd<a,b> | fwd<c,d>)
+//
+// where a and c have different types is
+//
+//     (new fwd_1,fwd_2)(!fwd_1(x,y).y<x> | !fwd_2(x,y).y<x> | fwd_1<a,b> | fwd_2<c,d>)
+//
+// so every name in the returned Process has a monomorphic type.
+// The input Process p is not modified.
+func Monomorphise(p asyncpi.Process) (asyncpi.Process, error) {
+	p = asyncpi.Clone(p)
+	for {
+		q := asyncpi.Clone(p)
+		u, err := solve(q, true)
+		if err != nil {
+			return nil, errMonomorphise(err)
+		}
+		s, groups := u.polymorphicServer()
+		if s == nil {
+			return p, nil
+		}
+		copyServer(p, q, s, groups)
+	}
+}
+
+func errMonomorphise(err error) error {
+	return errors.Wrap(err, "cannot monomorphise")
+}
+
+// polymorphicServer returns the first server used at more than one type,
+// and the groups of its instances by type.
+func (u *unifier) polymorphicServer() (*scheme, [][]instance) {
+	for _, s := range u.servers {
+		if groups := u.instanceGroups(s); len(groups) > 1 {
+			return s, groups
+		}
+	}
+	return nil, nil
+}
+
+// copyServer copies the server of s in Process p for each group of
+// instances, where q is an inferred copy of p which s is found in.
+func copyServer(p, q asyncpi.Process, s *scheme, groups [][]instance) {
+	// Processes in p of the Processes in q.
+	procs := make(map[asyncpi.Process]asyncpi.Process)
+	var qs []asyncpi.Process
+	asyncpi.Inspect(q, func(q asyncpi.Process) bool {
+		if q != nil {
+			qs = append(qs, q)
+		}
+		return true
+	})
+	i := 0
+	asyncpi.Inspect(p, func(p asyncpi.Process) bool {
+		if p != nil {
+			procs[qs[i]] = p
+			i++
+		}
+		return true
+	})
+
+	used := make(map[string]bool)
+	asyncpi.InspectNames(p, func(n asyncpi.Name, _ *asyncpi.Scope) {
+		used[n.Ident()] = true
+	})
+	decl := procs[s.decl].(*asyncpi.Restrict)
+	server := procs[s.server]
+	names := make([]asyncpi.Name, len(groups))
+	copies := make([]asyncpi.Process, len(groups))
+	for i, group := range groups {
+		names[i] = renameName(decl.Name, fresh(s.obj.Ident, used))
+		copies[i] = asyncpi.Substitute(asyncpi.Clone(server), map[asyncpi.Name]asyncpi.Name{decl.Name: names[i]})
+		for _, inst := range group {
+			procs[inst.occ.Proc].(*asyncpi.Send).Chan = names[i]
+		}
+	}
+	asyncpi.Apply(p, func(c *asyncpi.Cursor) bool {
+		if c.Node() == server {
+			c.Replace(&asyncpi.Par{Procs: copies})
+			return false
+		}
+		return true
+	}, nil)
+	decl.Name = names[0]
+	if len(names) > 1 {
+		decl.Proc = asyncpi.NewRestricts(names[1:], decl.Proc)
+	}
+}
+
+// fresh returns a new ident based on ident that is not used.
+func fresh(ident string, used map[string]bool) string {
+	for i := 1; ; i++ {
+		s := fmt.Sprintf("%s_%d", ident, i)
+		if !used[s] {
+			used[s] = true
+			return s
+		}
+	}
+}
+
+// renameName returns a copy of the Name n with the given ident,
+// preserving the type hint, position and annotations of n.
+func renameName(n asyncpi.Name, ident string) asyncpi.Name {
+	if tn, ok := n.(*typedName); ok {
+		n = tn.Name
+	}
+	if clone, ok := name.Copy(n); ok {
+		clone.(name.Setter).SetName(ident)
+		return clone.(asyncpi.Name)
+	}
+	return name.New(ident)
+}
@@ -0,0 +1,140 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"go.nickng.io/asyncpi"
+)
+
+// typesOf returns the type of the first occurrence of each name in p.
+func typesOf(p asyncpi.Process) map[string]string {
+	types := make(map[string]string)
+	asyncpi.InspectNames(p, func(n asyncpi.Name, _ *asyncpi.Scope) {
+		if _, ok := types[n.Ident()]; !ok {
+			types[n.Ident()] = n.(TypedName).Type().String()
+		}
+	})
+	return types
+}
+
+func TestUnifyPolymorphic(t *testing.T) {
+	input := "(new fwd)(!fwd(x,y).y<x> | (new a,b,c,d)(fwd<a,b> | b(z).z<> | fwd<c,d> | d(w).w<w,w>))"
+	proc, err := asyncpi.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Infer(proc); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unify(proc); err != nil {
+		t.Fatal(err)
+	}
+	types := typesOf(proc)
+	for name, want := range map[string]string{
+		"fwd": "chan struct{e0 interface{};e1 chan interface{}}",
+		"a":   "chan struct{}",
+		"b":   "chan chan struct{}",
+		"c":   "μt0.chan struct{e0 t0;e1 t0}",
+		"d":   "chan μt0.chan struct{e0 t0;e1 t0}",
+	} {
+		if got := types[name]; want != got {
+			t.Errorf("Unify: expects %s typed `%s` but got `%s`", name, want, got)
+		}
+	}
+}
+
+func TestUnifySpecialise(t *testing.T) {
+	input := "(new fwd)(!fwd(x,y).y<x> | (new a,b)(fwd<a,b> | b(z).z<>))"
+	proc, err := asyncpi.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Infer(proc); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unify(proc); err != nil {
+		t.Fatal(err)
+	}
+	types := typesOf(proc)
+	if want, got := "chan struct{e0 chan struct{};e1 chan chan struct{}}", types["fwd"]; want != got {
+		t.Errorf("Unify: expects fwd typed `%s` but got `%s`", want, got)
+	}
+	if want, got := "chan struct{}", types["x"]; want != got {
+		t.Errorf("Unify: expects x typed `%s` but got `%s`", want, got)
+	}
+}
+
+// Uses of a polymorphic name in its server are monomorphic.
+func TestUnifyPolymorphicServerUse(t *testing.T) {
+	input := "(new f)(!f(x).f<x> | (new a:int,b)(f<a> | f<b> | b<>))"
+	proc, err := asyncpi.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Infer(proc); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unify(proc); err != nil {
+		t.Fatal(err)
+	}
+	types := typesOf(proc)
+	if want, got := "int", types["a"]; want != got {
+		t.Errorf("Unify: expects a typed `%s` but got `%s`", want, got)
+	}
+	if want, got := "chan struct{}", types["b"]; want != got {
+		t.Errorf("Unify: expects b typed `%s` but got `%s`", want, got)
+	}
+}
+
+// A name sent as a value is not polymorphic.
+func TestUnifyMonomorphicSent(t *testing.T) {
+	input := "(new f)(!f(x).0 | (new a:int,b:string)(f<a> | f<b> | c<f>))"
+	proc, err := asyncpi.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Infer(proc); err == nil {
+		t.Errorf("Infer: expects type error but got nil")
+	}
+	if err := Unify(proc); err == nil {
+		t.Errorf("Unify: expects type error but got nil")
+	}
+}
+
+func TestMonomorphise(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{
+			"(new id)(!id(x,r).r<x> | (new r1,r2,u:int)(id<u,r1> | id<r1,r2>))",
+			"(new id_1)(new id_2)((!id_1(x,r).r<x> | !id_2(x,r).r<x>) | (new r1)(new r2)(new u)(id_1<u,r1> | id_2<r1,r2>))",
+		},
+		{
+			"(new id)(!id(x,r).r<x> | (new r1,r2,u:int)(id<u,r1> | id<u,r2>))",
+			"(new id)(!id(x,r).r<x> | (new r1)(new r2)(new u)(id<u,r1> | id<u,r2>))",
+		},
+	}
+	for _, test := range tests {
+		proc, err := asyncpi.Parse(strings.NewReader(test.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mono, err := Monomorphise(proc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, got := test.want, mono.Calculi(); want != got {
+			t.Errorf("Monomorphise: expects %s but got %s", want, got)
+		}
+		if want, got := test.input, proc.Calculi(); strings.Replace(want, "r1,r2,u:int", "r1)(new r2)(new u", 1) != got {
+			t.Errorf("Monomorphise: expects input unmodified %s but got %s", want, got)
+		}
+		if err := Infer(mono); err != nil {
+			t.Fatal(err)
+		}
+		if err := Unify(mono); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
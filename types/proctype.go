@@ -19,47 +19,272 @@ import (
 	"fmt"
 
 	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/internal/errors"
 )
 
-// ProcType returns the process type of the Process p.
-func ProcType(p asyncpi.Process) (string, error) {
+// Process types.
+// This file contains the behavioural types of Processes, i.e. the
+// Process with each channel use annotated by the type of the channel:
+//
+//   B ::= 0            inaction
+//       | a!T          output on channel a of type T
+//       | a?T; B       input on channel a of type T, then B
+//       | B|B          parallel composition
+//       | *B           replication
+//       | (νa:T) B     restriction of a of type T
+
+func errProcType(err error) error {
+	return errors.Wrap(err, "cannot extract process type")
+}
+
+// Behaviour is the behavioural type of a Process.
+type Behaviour interface {
+	String() string
+}
+
+// NilBehaviour is the behaviour of the nil Process.
+type NilBehaviour struct{}
+
+func (NilBehaviour) String() string { return "0" }
+
+// OutBehaviour is an output of Vals on channel Chan of type Type.
+type OutBehaviour struct {
+	Chan string
+	Type Type
+	Vals []string
+}
+
+func (b *OutBehaviour) String() string {
+	return fmt.Sprintf("%s!%s", b.Chan, b.Type)
+}
+
+// InBehaviour is an input of Vars on channel Chan of type Type,
+// followed by Cont.
+type InBehaviour struct {
+	Chan string
+	Type Type
+	Vars []string
+	Cont Behaviour
+}
+
+func (b *InBehaviour) String() string {
+	return fmt.Sprintf("%s?%s; %s", b.Chan, b.Type, prefixed(b.Cont))
+}
+
+// ParBehaviour is the parallel composition of Procs.
+type ParBehaviour struct {
+	Procs []Behaviour
+}
+
+func (b *ParBehaviour) String() string {
+	var buf bytes.Buffer
+	for i, p := range b.Procs {
+		if i > 0 {
+			buf.WriteRune('|')
+		}
+		buf.WriteString(p.String())
+	}
+	return buf.String()
+}
+
+// RepeatBehaviour is the replication of Body.
+type RepeatBehaviour struct {
+	Body Behaviour
+}
+
+func (b *RepeatBehaviour) String() string {
+	return "*" + prefixed(b.Body)
+}
+
+// RestrictBehaviour is the restriction of Name of type Type in Body.
+type RestrictBehaviour struct {
+	Name string
+	Type Type
+	Body Behaviour
+}
+
+func (b *RestrictBehaviour) String() string {
+	return fmt.Sprintf("(ν%s:%s) %s", b.Name, b.Type, prefixed(b.Body))
+}
+
+// prefixed returns the string of b after a prefix,
+// parenthesised if b is a parallel composition.
+func prefixed(b Behaviour) string {
+	if _, isPar := b.(*ParBehaviour); isPar {
+		return "(" + b.String() + ")"
+	}
+	return b.String()
+}
+
+// ProcType returns the behavioural type of the Process p typed by Infer.
+// An InferUntypedError is returned if a name is not typed.
+func ProcType(p asyncpi.Process) (Behaviour, error) {
+	b, err := procType(p)
+	if err != nil {
+		return nil, errProcType(err)
+	}
+	return b, nil
+}
+
+func procType(p asyncpi.Process) (Behaviour, error) {
 	switch p := p.(type) {
 	case *asyncpi.NilProcess:
-		return "0", nil
+		return NilBehaviour{}, nil
 	case *asyncpi.Send:
-		return fmt.Sprintf("%s!%s", p.Chan.Ident(), p.Chan.(TypedName).Type()), nil
+		t, err := chanType(p.Chan)
+		if err != nil {
+			return nil, err
+		}
+		vals, err := identsOf(p.Vals)
+		if err != nil {
+			return nil, err
+		}
+		return &OutBehaviour{Chan: p.Chan.Ident(), Type: t, Vals: vals}, nil
 	case *asyncpi.Recv:
-		proc, err := ProcType(p.Cont)
+		t, err := chanType(p.Chan)
+		if err != nil {
+			return nil, err
+		}
+		vars, err := identsOf(p.Vars)
+		if err != nil {
+			return nil, err
+		}
+		cont, err := procType(p.Cont)
 		if err != nil {
-			return proc, err
+			return nil, err
 		}
-		return fmt.Sprintf("%s?%s; %s", p.Chan.Ident(), p.Chan.(TypedName).Type(), proc), nil
+		return &InBehaviour{Chan: p.Chan.Ident(), Type: t, Vars: vars, Cont: cont}, nil
 	case *asyncpi.Par:
-		var buf bytes.Buffer
-		for i, ps := range p.Procs {
-			if i != 0 {
-				buf.WriteRune('|')
+		b := &ParBehaviour{Procs: make([]Behaviour, len(p.Procs))}
+		for i, proc := range p.Procs {
+			var err error
+			if b.Procs[i], err = procType(proc); err != nil {
+				return nil, err
 			}
-			proc, err := ProcType(ps)
-			if err != nil {
-				return proc, err
-			}
-			buf.WriteString(proc)
 		}
-		return buf.String(), nil
+		return b, nil
 	case *asyncpi.Repeat:
-		proc, err := ProcType(p.Proc)
+		body, err := procType(p.Proc)
 		if err != nil {
-			return proc, err
+			return nil, err
 		}
-		return "*" + proc, nil
+		return &RepeatBehaviour{Body: body}, nil
 	case *asyncpi.Restrict:
-		proc, err := ProcType(p.Proc)
+		t, err := chanType(p.Name)
+		if err != nil {
+			return nil, err
+		}
+		body, err := procType(p.Proc)
 		if err != nil {
-			return proc, err
+			return nil, err
+		}
+		return &RestrictBehaviour{Name: p.Name.Ident(), Type: t, Body: body}, nil
+	}
+	return nil, asyncpi.UnknownProcessError{Proc: p}
+}
+
+// chanType returns the type of the typed Name n.
+func chanType(n asyncpi.Name) (Type, error) {
+	if n == nil {
+		return nil, errors.Wrap(asyncpi.ErrInvalid, "nil name")
+	}
+	tn, ok := n.(TypedName)
+	if !ok {
+		return nil, InferUntypedError{Name: n.Ident()}
+	}
+	return tn.Type(), nil
+}
+
+func identsOf(names []asyncpi.Name) ([]string, error) {
+	idents := make([]string, len(names))
+	for i, n := range names {
+		if n == nil {
+			return nil, errors.Wrap(asyncpi.ErrInvalid, "nil name")
+		}
+		idents[i] = n.Ident()
+	}
+	return idents, nil
+}
+
+// IsEqualBehaviour returns true if behaviours b and c are equal up to
+// the renaming of bound names and the order of parallel compositions,
+// where the types are compared by IsEqual.
+func IsEqualBehaviour(b, c Behaviour) bool {
+	return equalBehaviour(b, c, nil)
+}
+
+// renaming maps the bound names of one behaviour to the other.
+type renaming struct {
+	left, right string
+	next        *renaming
+}
+
+// bind returns r with left renamed to right.
+func (r *renaming) bind(left, right string) *renaming {
+	return &renaming{left: left, right: right, next: r}
+}
+
+// same returns true if the names left and right are the same under r.
+func (r *renaming) same(left, right string) bool {
+	for ; r != nil; r = r.next {
+		if r.left == left || r.right == right {
+			return r.left == left && r.right == right
+		}
+	}
+	return left == right
+}
+
+func equalBehaviour(b, c Behaviour, r *renaming) bool {
+	switch b := b.(type) {
+	case NilBehaviour:
+		_, ok := c.(NilBehaviour)
+		return ok
+	case *OutBehaviour:
+		c, ok := c.(*OutBehaviour)
+		if !ok || !r.same(b.Chan, c.Chan) || !IsEqual(b.Type, c.Type) || len(b.Vals) != len(c.Vals) {
+			return false
+		}
+		for i := range b.Vals {
+			if !r.same(b.Vals[i], c.Vals[i]) {
+				return false
+			}
+		}
+		return true
+	case *InBehaviour:
+		c, ok := c.(*InBehaviour)
+		if !ok || !r.same(b.Chan, c.Chan) || !IsEqual(b.Type, c.Type) || len(b.Vars) != len(c.Vars) {
+			return false
+		}
+		for i := range b.Vars {
+			r = r.bind(b.Vars[i], c.Vars[i])
+		}
+		return equalBehaviour(b.Cont, c.Cont, r)
+	case *ParBehaviour:
+		c, ok := c.(*ParBehaviour)
+		if !ok || len(b.Procs) != len(c.Procs) {
+			return false
+		}
+		// Equality is an equivalence, so matching greedily is complete.
+		matched := make([]bool, len(c.Procs))
+		for _, p := range b.Procs {
+			found := false
+			for j, q := range c.Procs {
+				if !matched[j] && equalBehaviour(p, q, r) {
+					matched[j], found = true, true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
 		}
-		return fmt.Sprintf("(ν%s:%s) %s", p.Name.Ident(), p.Name.(TypedName).Type(), proc), nil
-	default:
-		return "", asyncpi.UnknownProcessError{Proc: p}
+		return true
+	case *RepeatBehaviour:
+		c, ok := c.(*RepeatBehaviour)
+		return ok && equalBehaviour(b.Body, c.Body, r)
+	case *RestrictBehaviour:
+		c, ok := c.(*RestrictBehaviour)
+		return ok && IsEqual(b.Type, c.Type) && equalBehaviour(b.Body, c.Body, r.bind(b.Name, c.Name))
 	}
+	return false
 }
@@ -0,0 +1,87 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"go.nickng.io/asyncpi"
+	"go.nickng.io/asyncpi/internal/errors"
+)
+
+func procTypeOf(t *testing.T, s string) Behaviour {
+	proc, err := asyncpi.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Infer(proc); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unify(proc); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ProcType(proc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestProcType(t *testing.T) {
+	tests := []struct {
+		Proc, Want string
+	}{
+		{"(new a)(a<> | a().0)", "(νa:chan struct{}) (a!chan struct{}|a?chan struct{}; 0)"},
+		{"!a(x).(x<> | x().0)", "*a?chan chan struct{}; (x!chan struct{}|x?chan struct{}; 0)"},
+	}
+	for _, test := range tests {
+		if want, got := test.Want, procTypeOf(t, test.Proc).String(); want != got {
+			t.Errorf("ProcType: expects %s but got %s", want, got)
+		}
+	}
+	b := procTypeOf(t, "(new a)a(x).x<a>")
+	res, ok := b.(*RestrictBehaviour)
+	if !ok {
+		t.Fatalf("ProcType: expects *RestrictBehaviour but got %T", b)
+	}
+	in, ok := res.Body.(*InBehaviour)
+	if !ok {
+		t.Fatalf("ProcType: expects *InBehaviour but got %T", res.Body)
+	}
+	if want, got := "x", in.Vars[0]; want != got {
+		t.Errorf("ProcType: expects input of %s but got %s", want, got)
+	}
+}
+
+func TestProcTypeUntyped(t *testing.T) {
+	proc, err := asyncpi.Parse(strings.NewReader("(new a)(a<> | a().0)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ProcType(proc)
+	if err == nil {
+		t.Fatal("ProcType: expects InferUntypedError but got nil")
+	}
+	if _, ok := err.(errors.Causer).Cause().(InferUntypedError); !ok {
+		t.Errorf("ProcType: expects InferUntypedError but got %v", err)
+	}
+}
+
+func TestIsEqualBehaviour(t *testing.T) {
+	tests := []struct {
+		P, Q  string
+		Equal bool
+	}{
+		{"(new a)(a<> | a().0)", "(new b)(b().0 | b<>)", true},
+		{"c(x).x<>", "c(y).y<>", true},
+		{"c(x).x<c>", "c(y).y<y>", false},
+		{"(new a)(a<> | a().0)", "(new a)(a<> | a<>)", false},
+		{"(new a:int)(b<a> | b(x).0)", "(new a)(b<a> | b(x).x<>)", false},
+		{"!c(x).0", "c(x).0", false},
+	}
+	for _, test := range tests {
+		p, q := procTypeOf(t, test.P), procTypeOf(t, test.Q)
+		if want, got := test.Equal, IsEqualBehaviour(p, q); want != got {
+			t.Errorf("IsEqualBehaviour: expects %v for %s and %s but got %v", want, p, q, got)
+		}
+	}
+}
@@ -73,16 +73,54 @@ func (b *Base) String() string {
 	return b.name
 }
 
+// ChanDir is the capability of a channel type, i.e. the direction
+// a channel can be used in.
+type ChanDir int
+
+const (
+	// RecvOnly is the input-only capability.
+	RecvOnly ChanDir = 1 << iota
+	// SendOnly is the output-only capability.
+	SendOnly
+	// SendRecv is the bidirectional capability.
+	SendRecv = RecvOnly | SendOnly
+)
+
+// Has returns true if the capability d includes capability e.
+func (d ChanDir) Has(e ChanDir) bool {
+	return d&e == e
+}
+
+func (d ChanDir) String() string {
+	switch d {
+	case RecvOnly:
+		return "<-chan"
+	case SendOnly:
+		return "chan<-"
+	}
+	return "chan"
+}
+
 // Chan represents a channel type.
 type Chan struct {
 	// elem is the type of the elements that
 	// can be transmitted through the channel.
 	elem Type
+
+	// dir is the capability of the channel.
+	dir ChanDir
 }
 
-// NewChan returns a new channel type for the given element type.
+// NewChan returns a new bidirectional channel type
+// for the given element type.
 func NewChan(elem Type) *Chan {
-	return &Chan{elem}
+	return &Chan{elem: elem, dir: SendRecv}
+}
+
+// NewChanDir returns a new channel type for the given
+// capability and element type.
+func NewChanDir(dir ChanDir, elem Type) *Chan {
+	return &Chan{elem: elem, dir: dir}
 }
 
 // Elem returns the element type of channel c.
@@ -90,13 +128,18 @@ func (c *Chan) Elem() Type {
 	return c.elem
 }
 
+// Dir returns the capability of channel c.
+func (c *Chan) Dir() ChanDir {
+	return c.dir
+}
+
 // Underlying returns itself as the underlying type of c.
 func (c *Chan) Underlying() Type {
 	return c
 }
 
 func (c *Chan) String() string {
-	return fmt.Sprintf("chan %s", c.Elem().String())
+	return typeString(c)
 }
 
 // Composite represents a composite type of multiple types.
@@ -125,16 +168,7 @@ func (c *Composite) Underlying() Type {
 
 // String returns a struct of the composed types.
 func (c *Composite) String() string {
-	var buf bytes.Buffer
-	buf.WriteString("struct{")
-	for i, e := range c.elems {
-		if i != 0 {
-			buf.WriteRune(';')
-		}
-		buf.WriteString(fmt.Sprintf("e%d %s", i, e.String()))
-	}
-	buf.WriteString("}")
-	return buf.String()
+	return typeString(c)
 }
 
 // Reference is a reference to the type of a given Name.
@@ -147,29 +181,155 @@ func NewReference(n asyncpi.Name) *Reference {
 	return &Reference{AttachType(n)}
 }
 
-// Underlying returns the type of the referenced Name as the underlying type of r.
+// Underlying returns the type of the referenced Name as the underlying type of r,
+// with all References resolved.
 func (r *Reference) Underlying() Type {
-	return r.ref.Type()
+	return deref(r)
 }
 
 func (r *Reference) String() string {
-	return r.ref.Type().String()
+	return typeString(r)
+}
+
+// typeString returns the string representation of type t.
+// Recursive types are written with μ binders, e.g.
+//
+//     μt0.chan t0
+//
+// is the type of a channel which carries channels of its own type.
+func typeString(t Type) string {
+	names := make(map[Type]string) // Names of the types bound by μ.
+	var visit func(t Type, visiting []Type) string
+	visit = func(t Type, visiting []Type) string {
+		t = deref(t)
+		for _, v := range visiting {
+			if v == t {
+				if _, ok := names[t]; !ok {
+					names[t] = fmt.Sprintf("t%d", len(names))
+				}
+				return names[t]
+			}
+		}
+		visiting = append(visiting, t)
+		var buf bytes.Buffer
+		switch t := t.(type) {
+		case *Chan:
+			fmt.Fprintf(&buf, "%s %s", t.dir, visit(t.elem, visiting))
+		case *Composite:
+			buf.WriteString("struct{")
+			for i, e := range t.elems {
+				if i != 0 {
+					buf.WriteRune(';')
+				}
+				fmt.Fprintf(&buf, "e%d %s", i, visit(e, visiting))
+			}
+			buf.WriteString("}")
+		default:
+			buf.WriteString(t.String())
+		}
+		if name, ok := names[t]; ok {
+			return fmt.Sprintf("μ%s.%s", name, buf.String())
+		}
+		return buf.String()
+	}
+	return visit(t, nil)
+}
+
+// components returns the types directly contained in type t.
+func components(t Type) []Type {
+	switch t := deref(t).(type) {
+	case *Chan:
+		return []Type{t.elem}
+	case *Composite:
+		return t.elems
+	}
+	return nil
+}
+
+// occurs returns true if type t occurs in the components of type u.
+func occurs(t, u Type) bool {
+	t = deref(t)
+	seen := make(map[Type]bool)
+	work := append([]Type(nil), components(u)...)
+	for len(work) > 0 {
+		c := deref(work[0])
+		work = work[1:]
+		if c == t {
+			return true
+		}
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		work = append(work, components(c)...)
+	}
+	return false
+}
+
+// IsRecursive returns true if type t contains itself,
+// e.g. the type of a in a<a>.
+func IsRecursive(t Type) bool {
+	return occurs(t, t)
+}
+
+// cloneType returns a copy of type t where the
+// referenced names are copied with the Cloner c.
+func cloneType(t Type, c *asyncpi.Cloner) Type {
+	switch t := t.(type) {
+	case *Chan:
+		return NewChanDir(t.dir, cloneType(t.elem, c))
+	case *Composite:
+		elems := make([]Type, len(t.elems))
+		for i := range t.elems {
+			elems[i] = cloneType(t.elems[i], c)
+		}
+		return NewComposite(elems...)
+	case *Reference:
+		return &Reference{ref: c.Name(t.ref).(TypedName)}
+	default: // anyType and Base are immutable.
+		return t
+	}
 }
 
 // deref peels off layers of Reference from a given type
 // and returns the underlying type.
+// A cycle of References does not constrain the type.
 func deref(t Type) Type {
-	if refType, ok := t.(*Reference); ok {
-		return deref(refType.ref.Type())
+	seen := make(map[TypedName]bool)
+	for {
+		refType, ok := t.(*Reference)
+		if !ok {
+			return t
+		}
+		if seen[refType.ref] {
+			return newAnyType()
+		}
+		seen[refType.ref] = true
+		t = refType.ref.Type()
 	}
-	return t
 }
 
+// typePair is a pair of types assumed to be related
+// when comparing recursive types.
+type typePair struct{ t, u Type }
+
 // IsEqual compare types.
+//
+// Recursive types are equal if their unfoldings are equal,
+// e.g. μt0.chan t0 and μt0.chan chan t0 are equal.
 func IsEqual(t, u Type) bool {
+	return isEqual(t, u, make(map[typePair]bool))
+}
+
+func isEqual(t, u Type, assumed map[typePair]bool) bool {
 	if t == u {
 		return true
 	}
+	t, u = deref(t), deref(u)
+	if t == u || assumed[typePair{t, u}] {
+		return true
+	}
+	assumed[typePair{t, u}] = true
 	if baseT, tok := deref(t).(*Base); tok {
 		if baseU, uok := deref(u).(*Base); uok {
 			return baseT.name == baseU.name
@@ -182,14 +342,71 @@ func IsEqual(t, u Type) bool {
 			}
 			compEqual := len(compT.elems) == len(compU.elems)
 			for i := range compT.elems {
-				compEqual = compEqual && IsEqual(compT.elems[i], compU.elems[i])
+				compEqual = compEqual && isEqual(compT.elems[i], compU.elems[i], assumed)
 			}
 			return compEqual
 		}
 	}
 	if chanT, tok := deref(t).(*Chan); tok {
 		if chanU, uok := deref(u).(*Chan); uok {
-			return IsEqual(chanT.elem, chanU.elem)
+			return chanT.dir == chanU.dir && isEqual(chanT.elem, chanU.elem, assumed)
+		}
+	}
+	return false
+}
+
+// IsSubtype returns true if type t is a subtype of type u,
+// i.e. a name of type t can be used where type u is expected.
+//
+// A channel type is a subtype of another channel type with fewer
+// capabilities. Element types are covariant for input-only channels,
+// contravariant for output-only channels and invariant for
+// bidirectional channels, i.e.
+//
+//     chan T <: <-chan T     chan T <: chan<- T
+//     <-chan T <: <-chan U   if T <: U
+//     chan<- T <: chan<- U   if U <: T
+//
+// All types are subtypes of the unconstrained type.
+func IsSubtype(t, u Type) bool {
+	return isSubtype(t, u, make(map[typePair]bool))
+}
+
+func isSubtype(t, u Type, assumed map[typePair]bool) bool {
+	if t == u {
+		return true
+	}
+	t, u = deref(t), deref(u)
+	if t == u || assumed[typePair{t, u}] {
+		return true
+	}
+	assumed[typePair{t, u}] = true
+	if _, uAny := deref(u).(*anyType); uAny {
+		return true
+	}
+	switch tt := deref(t).(type) {
+	case *Base:
+		if uu, ok := deref(u).(*Base); ok {
+			return tt.name == uu.name
+		}
+	case *Composite:
+		if uu, ok := deref(u).(*Composite); ok && len(tt.elems) == len(uu.elems) {
+			for i := range tt.elems {
+				if !isSubtype(tt.elems[i], uu.elems[i], assumed) {
+					return false
+				}
+			}
+			return true
+		}
+	case *Chan:
+		if uu, ok := deref(u).(*Chan); ok && tt.dir.Has(uu.dir) {
+			if uu.dir.Has(RecvOnly) && !isSubtype(tt.elem, uu.elem, assumed) {
+				return false
+			}
+			if uu.dir.Has(SendOnly) && !isSubtype(uu.elem, tt.elem, assumed) {
+				return false
+			}
+			return true
 		}
 	}
 	return false
@@ -1,21 +1,23 @@
 package types
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
 	"go.nickng.io/asyncpi"
 	"go.nickng.io/asyncpi/internal/errors"
+	"go.nickng.io/asyncpi/internal/name"
 )
 
-// Tests type inference only.
+// Tests type inference only, which solves the types of all names.
 func TestBasicInferOnly(t *testing.T) {
 	input := "(new a)(new b)(new c:T)(a<b,c>|a(y,z).b<z>)"
-	atype := "chan struct{e0 chan interface{};e1 T}"
-	btype := "chan interface{}" // chan type(c) if unified.
-	ctype := "T"                // From type hint.
-	ytype := "interface{}"      // type(b) if unified.
-	ztype := "interface{}"      // type(c) if unified.
+	atype := "chan struct{e0 chan T;e1 T}"
+	btype := "chan T" // chan type(c).
+	ctype := "T"      // From type hint.
+	ytype := "chan T" // type(b).
+	ztype := "T"      // type(c).
 	proc, err := asyncpi.Parse(strings.NewReader(input))
 	if err != nil {
 		t.Fatal(err)
@@ -146,13 +148,14 @@ func TestBasicInferUnify(t *testing.T) {
 //
 // a sends b and c so a is a 2-elem struct chan.
 // b is T by type hint, so a is struct{e0 T;e1 interface{}}
+// x and y are determined by inference.
 func TestWrongHintInferOnly(t *testing.T) {
 	simple := "(new a:TA)(new b:T)(new c)(a<b,c>|a(x,y).0)"
 	atype := "chan struct{e0 T;e1 interface{}}"
 	btype := "T" // From type hint.
 	ctype := "interface{}"
-	xtype := "interface{}" // type(b) if unified.
-	ytype := "interface{}" // type(c) if unified.
+	xtype := "T"           // type(b).
+	ytype := "interface{}" // type(c).
 	proc, err := asyncpi.Parse(strings.NewReader(simple))
 	if err != nil {
 		t.Fatal(err)
@@ -272,8 +275,8 @@ func TestWrongHintInferUnify(t *testing.T) {
 // Tests type inference on higher order names.
 func TestHigherOrderInferOnly(t *testing.T) {
 	input := "(new a)(new b)(a<b>|a(x).x().0)"
-	atype := "chan interface{}" // chan type(x) if unified.
-	btype := "interface{}"      // chan type(x) if unified.
+	atype := "chan chan struct{}" // chan type(x).
+	btype := "chan struct{}"      // chan type(x).
 	xtype := "chan struct{}"
 	proc, err := asyncpi.Parse(strings.NewReader(input))
 	if err != nil {
@@ -373,11 +376,11 @@ func TestInferUnifyNested(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	processAttachType(proc)
 	resa, ok := proc.(*asyncpi.Restrict)
 	if !ok {
-		t.Errorf("Parse: `%s` does not begin with restriction", nested)
+		t.Fatalf("Parse: `%s` does not begin with restriction", nested)
 	}
+	resa.Name = AttachType(resa.Name)
 	if _, ok := resa.Name.(TypedName).Type().(*anyType); !ok {
 		t.Errorf("Infer: Type of `a` is not %s\n got: %s",
 			atype, resa.Name.(TypedName).Type())
@@ -465,11 +468,11 @@ func TestInferNested(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	processAttachType(proc)
 	resa, ok := proc.(*asyncpi.Restrict)
 	if !ok {
-		t.Errorf("Parse: `%s` does not begin with restriction", nested)
+		t.Fatalf("Parse: `%s` does not begin with restriction", nested)
 	}
+	resa.Name = AttachType(resa.Name)
 	if _, ok := resa.Name.(TypedName).Type().(*anyType); !ok {
 		t.Errorf("Infer: Type of `a` is not %s\n got: %s",
 			atype, resa.Name.(TypedName).Type())
@@ -508,8 +511,8 @@ func TestMismatchCompType(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err := Infer(proc); err != nil {
-		t.Fatal(err)
+	if err := Infer(proc); err == nil {
+		t.Fatalf("Infer: expects type error but got nil")
 	}
 	err = Unify(proc)
 	if err, ok := err.(errors.Causer); ok {
@@ -533,3 +536,183 @@ func TestMultipleSender(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestInferCopy(t *testing.T) {
+	input := "(new a)(new b)(a<b>|a(x).x().0)"
+	proc, err := asyncpi.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inferred, err := InferCopy(proc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := proc.(*asyncpi.Restrict).Name.(TypedName); ok {
+		t.Errorf("InferCopy: expected input to be untyped but got `%s`", proc)
+	}
+	unified, err := UnifyCopy(inferred)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "chan chan struct{}", inferred.(*asyncpi.Restrict).Name.(TypedName).Type().String(); want != got {
+		t.Errorf("UnifyCopy: expected input a typed `%s` but got `%s`", want, got)
+	}
+	if want, got := "chan chan struct{}", unified.(*asyncpi.Restrict).Name.(TypedName).Type().String(); want != got {
+		t.Errorf("UnifyCopy: expected a typed `%s` but got `%s`", want, got)
+	}
+}
+
+// Tests inference of recursive types.
+// a sends a so a is a channel of its own type.
+func TestInferUnifyRecursive(t *testing.T) {
+	tests := []struct {
+		Proc string
+		Type string // Type of the first restricted name.
+	}{
+		{"(new a)(a<a> | a(x).x<x>)", "μt0.chan t0"},
+		{"(new a)(a<a> | a(x).x(y).y<y>)", "μt0.chan t0"},
+		{"(new b)(new c)(b<c> | c<b>)", "μt0.chan chan t0"},
+		{"(new a,b)(a<a,b> | a(x,y).y<>)", "μt0.chan struct{e0 t0;e1 chan struct{}}"},
+	}
+	for _, test := range tests {
+		proc, err := asyncpi.Parse(strings.NewReader(test.Proc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Infer(proc); err != nil {
+			t.Fatal(err)
+		}
+		if err := Unify(proc); err != nil {
+			t.Fatal(err)
+		}
+		if want, got := test.Type, proc.(*asyncpi.Restrict).Name.(TypedName).Type().String(); want != got {
+			t.Errorf("Infer: expects %s but got %s", want, got)
+		}
+	}
+}
+
+// Tests equality of recursive types by their unfoldings.
+func TestIsEqualRecursive(t *testing.T) {
+	typeOf := func(s string) Type {
+		proc, err := asyncpi.Parse(strings.NewReader(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Infer(proc); err != nil {
+			t.Fatal(err)
+		}
+		return proc.(*asyncpi.Restrict).Name.(TypedName).Type()
+	}
+	a, b := typeOf("(new a)a<a>"), typeOf("(new b)(new c)(b<c> | c<b>)")
+	if !IsRecursive(a) || !IsRecursive(b) {
+		t.Errorf("IsRecursive: expects %s and %s to be recursive", a, b)
+	}
+	if !IsEqual(a, b) {
+		t.Errorf("IsEqual: expects %s and %s to be equal", a, b)
+	}
+	if !IsSubtype(a, b) {
+		t.Errorf("IsSubtype: expects %s <: %s", a, b)
+	}
+	if c := typeOf("(new a)a<>"); IsEqual(a, c) {
+		t.Errorf("IsEqual: expects %s and %s to be different", a, c)
+	}
+}
+
+// Tests unification uses the constraints of all sends and receives.
+func TestUnifySendSites(t *testing.T) {
+	tests := []struct {
+		Proc  string
+		Types string // Types of the restricted names.
+	}{
+		{"(new a,b,c)(a<b> | a<c> | c<>)", "chan chan struct{} chan struct{} chan struct{}"},
+		{"(new b)(a(x).x<> | a<b>)", "chan struct{}"},
+		{"(new b:T)(new c)(a<b> | a<c>)", "T T"},
+	}
+	for _, test := range tests {
+		proc, err := asyncpi.Parse(strings.NewReader(test.Proc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Infer(proc); err != nil {
+			t.Fatal(err)
+		}
+		if err := Unify(proc); err != nil {
+			t.Fatal(err)
+		}
+		var types []string
+		asyncpi.Inspect(proc, func(p asyncpi.Process) bool {
+			if r, ok := p.(*asyncpi.Restrict); ok {
+				types = append(types, r.Name.(TypedName).Type().String())
+			}
+			return true
+		})
+		if want, got := test.Types, strings.Join(types, " "); want != got {
+			t.Errorf("Unify: expects %s but got %s", want, got)
+		}
+	}
+}
+
+// Tests all conflicts are reported with the positions of both constraints.
+func TestUnifyConflicts(t *testing.T) {
+	input := "(new a,b:T,c:U)(a<b> | a<> | a<c> | b(x,y).0 | b<>)"
+	proc, err := asyncpi.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Infer(proc); err == nil {
+		t.Fatalf("Infer: expects type error but got nil")
+	}
+	err = Unify(proc)
+	causer, ok := err.(errors.Causer)
+	if !ok {
+		t.Fatalf("Unify: expects conflicts but got %v", err)
+	}
+	conflicts, ok := causer.Cause().(*ConflictError)
+	if !ok {
+		t.Fatalf("Unify: expects *ConflictError but got %v", causer.Cause())
+	}
+	want := []string{
+		"1:24 1:17", // a<> and a<b>
+		"1:12 1:8",  // c:U and b:T
+		"1:48 1:37", // b<> and b(x,y)
+	}
+	if len(conflicts.Errs) != len(want) {
+		t.Fatalf("Unify: expects %d conflicts but got %d: %v", len(want), len(conflicts.Errs), conflicts)
+	}
+	for i, err := range conflicts.Errs {
+		var got string
+		switch err := err.(type) {
+		case *TypeArityError:
+			got = fmt.Sprintf("%s %s", err.Pos, err.PrevPos)
+		case *TypeError:
+			got = fmt.Sprintf("%s %s", err.Pos, err.PrevPos)
+		}
+		if got != want[i] {
+			t.Errorf("Unify: expects conflict at %s but got %s (%v)", want[i], got, err)
+		}
+	}
+}
+
+// Tests inference returns errors instead of panicking on ill-formed input.
+func TestInferIllFormed(t *testing.T) {
+	untyped, err := asyncpi.Parse(strings.NewReader("a(x).x<>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Unify(untyped); err == nil {
+		t.Errorf("Unify: expects error on untyped process but got nil")
+	}
+	tests := []asyncpi.Process{
+		&asyncpi.Send{Chan: name.New("a"), Vals: []asyncpi.Name{nil}},
+		&asyncpi.Recv{Chan: name.New("a"), Cont: nil},
+		&asyncpi.Par{Procs: []asyncpi.Process{asyncpi.NewNilProcess(), nil}},
+		&asyncpi.Restrict{Name: nil, Proc: asyncpi.NewNilProcess()},
+	}
+	for _, p := range tests {
+		if err := Infer(p); err == nil {
+			if err := Unify(p); err == nil {
+				t.Errorf("Infer: expects error on ill-formed process %#v but got nil", p)
+			}
+		}
+	}
+}